@@ -0,0 +1,52 @@
+// lighthouse-agent runs an organization's checks from its own region. It
+// authenticates with an agent token created in the app, pulls due checks
+// from the server and pushes their results back.
+//
+// Configuration (environment or .env):
+//
+//	LIGHTHOUSE_URL          server base URL, e.g. https://lighthouse.example.com
+//	LIGHTHOUSE_AGENT_TOKEN  agent token (lha_...)
+//	AGENT_CONCURRENCY       checks run at once (default 10)
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/oFuterman/light-house/internal/agent"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+func main() {
+	_ = godotenv.Load()
+
+	baseURL := os.Getenv("LIGHTHOUSE_URL")
+	token := os.Getenv("LIGHTHOUSE_AGENT_TOKEN")
+	if baseURL == "" || token == "" {
+		log.Fatal("LIGHTHOUSE_URL and LIGHTHOUSE_AGENT_TOKEN must be set")
+	}
+	concurrency := 10
+	if n, err := strconv.Atoi(os.Getenv("AGENT_CONCURRENCY")); err == nil && n > 0 {
+		concurrency = n
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := &agent.Runner{
+		Client:      agent.NewClient(baseURL, token),
+		Concurrency: concurrency,
+		Version:     version,
+	}
+	log.Printf("lighthouse-agent %s starting (concurrency %d)", version, concurrency)
+	if err := runner.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Fatalf("Agent stopped: %v", err)
+	}
+}
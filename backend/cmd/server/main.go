@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 
 	"github.com/oFuterman/light-house/internal/config"
 	"github.com/oFuterman/light-house/internal/database"
 	"github.com/oFuterman/light-house/internal/notifier"
 	"github.com/oFuterman/light-house/internal/router"
+	"github.com/oFuterman/light-house/internal/secrets"
 	"github.com/oFuterman/light-house/internal/worker"
 )
 
@@ -23,12 +29,25 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+
+	// `server migrate ...` manages the schema without starting the API
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(cfg, os.Args[2:])
+		return
+	}
+
 	notifier.Init(cfg)
 
 	// Validate critical config
 	if cfg.JWTSecret == "change-me-in-production" && cfg.Environment == "production" {
 		log.Fatal("JWT_SECRET must be set in production")
 	}
+	if cfg.SecretsKey == "change-me-in-production" && cfg.Environment == "production" {
+		log.Fatal("SECRETS_KEY must be set in production")
+	}
+	if err := secrets.Init(cfg.SecretsKey); err != nil {
+		log.Fatalf("Failed to initialize secrets: %v", err)
+	}
 
 	// Connect to database
 	db, err := database.Connect(cfg)
@@ -36,7 +55,7 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// Run migrations
+	// Run migrations (AutoMigrate baseline plus pending versioned migrations)
 	if err := database.Migrate(db); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
@@ -59,9 +78,18 @@ func main() {
 	// Setup routes (pass config for JWT secret)
 	router.Setup(app, db, cfg)
 
-	// Start background workers
-	go worker.StartCheckRunner(db)
-	go worker.StartTrialExpiryWorker(db)
+	// Start background workers. They stop on SIGINT/SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var workers worker.Supervisor
+	workers.Go(ctx, "check runner", func(ctx context.Context) {
+		worker.StartCheckRunner(ctx, db, cfg.CheckConcurrency)
+	})
+	workers.Go(ctx, "trial expiry worker", func(ctx context.Context) { worker.StartTrialExpiryWorker(ctx, db) })
+	workers.Go(ctx, "service map worker", func(ctx context.Context) { worker.StartServiceMapWorker(ctx, db) })
+	workers.Go(ctx, "span rollup worker", func(ctx context.Context) { worker.StartSpanRollupWorker(ctx, db) })
+	workers.Go(ctx, "retention worker", func(ctx context.Context) { worker.StartRetentionWorker(ctx, db) })
+	workers.Go(ctx, "partition manager", func(ctx context.Context) { worker.StartPartitionManager(ctx, db) })
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -70,9 +98,42 @@ func main() {
 	}
 
 	log.Printf("Server starting on port %s", port)
-	if err := app.Listen(":" + port); err != nil {
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(":" + port)
+	}()
+	select {
+	case err := <-listenErr:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // A second signal kills the process immediately
+
+	shutdown(app, db, &workers, cfg.ShutdownTimeout)
+}
+
+// shutdown stops accepting requests and waits for in-flight ones, then for
+// the workers to stop and for the checks and notifications they started,
+// all within timeout, and finally closes the database
+func shutdown(app *fiber.App, db *gorm.DB, workers *worker.Supervisor, timeout time.Duration) {
+	log.Printf("Shutting down (waiting up to %s)...", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if err := workers.Wait(ctx); err != nil {
+		log.Printf("Workers did not stop in time: %v", err)
+	}
+	if err := worker.Drain(ctx); err != nil {
+		// Leases on unfinished checks expire and other instances rerun them
+		log.Printf("Checks or notifications still running at shutdown: %v", err)
+	}
+	if err := database.Close(db); err != nil {
+		log.Printf("Error closing database: %v", err)
 	}
+	log.Println("Server stopped")
 }
 
 func customErrorHandler(c *fiber.Ctx, err error) error {
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/oFuterman/light-house/internal/config"
+	"github.com/oFuterman/light-house/internal/database"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  status      list migrations and whether each is applied
+  up [n]      apply the next n pending migrations (default: all)
+  down [n]    roll back the last n applied migrations (default: 1)`
+
+// runMigrateCommand handles `server migrate ...` and exits
+func runMigrateCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	n := 0
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			fmt.Fprintln(os.Stderr, "n must be a positive integer")
+			os.Exit(2)
+		}
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	switch args[0] {
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	case "up":
+		count, err := database.MigrateUp(db, n)
+		if err != nil {
+			log.Fatalf("Migration failed after applying %d: %v", count, err)
+		}
+		log.Printf("Applied %d migration(s)", count)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		count, err := database.MigrateDown(db, n)
+		if err != nil {
+			log.Fatalf("Rollback failed after reverting %d: %v", count, err)
+		}
+		log.Printf("Rolled back %d migration(s)", count)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
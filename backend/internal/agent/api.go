@@ -0,0 +1,64 @@
+// Package agent is the lighthouse-agent side of remote probing: the wire
+// types of the agent API, a client for it and the loop that claims checks,
+// probes them locally and reports the results.
+package agent
+
+import (
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/probe"
+)
+
+// RegisterRequest is sent by an agent when it starts
+// POST /api/v1/agent/register
+type RegisterRequest struct {
+	Version  string `json:"version"`
+	Hostname string `json:"hostname"`
+}
+
+// RegisterResponse tells an agent who it is and how often to poll
+type RegisterResponse struct {
+	AgentID             uint   `json:"agent_id"`
+	Name                string `json:"name"`
+	Region              string `json:"region"`
+	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+}
+
+// ClaimRequest asks for up to Limit due checks
+// POST /api/v1/agent/claim
+type ClaimRequest struct {
+	Limit int `json:"limit"`
+}
+
+// Job is a claimed check with the credentials the agent needs to run it
+type Job struct {
+	Check   models.Check  `json:"check"`
+	Secrets probe.Secrets `json:"secrets"`
+}
+
+type ClaimResponse struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// Result reports one claimed check's outcome
+type Result struct {
+	CheckID uint          `json:"check_id"`
+	Outcome probe.Outcome `json:"outcome"`
+}
+
+// ResultsRequest pushes results back
+// POST /api/v1/agent/results
+type ResultsRequest struct {
+	Results []Result `json:"results"`
+}
+
+// RejectedResult is a result the server didn't record, usually because the
+// agent's lease on the run expired
+type RejectedResult struct {
+	CheckID uint   `json:"check_id"`
+	Error   string `json:"error"`
+}
+
+type ResultsResponse struct {
+	Accepted int              `json:"accepted"`
+	Rejected []RejectedResult `json:"rejected,omitempty"`
+}
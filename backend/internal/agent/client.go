@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client calls the agent API with an agent token
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for the server at baseURL (scheme and host,
+// e.g. https://lighthouse.example.com)
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Register announces the agent and returns its identity and poll interval
+func (c *Client) Register(req RegisterRequest) (RegisterResponse, error) {
+	var resp RegisterResponse
+	err := c.post("/api/v1/agent/register", req, &resp)
+	return resp, err
+}
+
+// Claim leases up to limit due checks in the agent's region
+func (c *Client) Claim(limit int) ([]Job, error) {
+	var resp ClaimResponse
+	err := c.post("/api/v1/agent/claim", ClaimRequest{Limit: limit}, &resp)
+	return resp.Jobs, err
+}
+
+// PushResults reports finished runs
+func (c *Client) PushResults(results []Result) (ResultsResponse, error) {
+	var resp ResultsResponse
+	err := c.post("/api/v1/agent/results", ResultsRequest{Results: results}, &resp)
+	return resp, err
+}
+
+func (c *Client) post(path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %d %s", path, resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("%s: %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/probe"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	maxRegisterBackoff  = time.Minute
+)
+
+// Runner registers with the server, then claims due checks, probes them
+// locally with at most Concurrency in flight and pushes each result back
+type Runner struct {
+	Client      *Client
+	Concurrency int
+	Version     string
+}
+
+// Run works until ctx is cancelled, then waits for in-flight checks to
+// finish and report. Unreported runs are handed out again once their
+// lease expires.
+func (r *Runner) Run(ctx context.Context) error {
+	reg, err := r.register(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Registered as agent %d (%s) in region %s", reg.AgentID, reg.Name, reg.Region)
+
+	poll := time.Duration(reg.PollIntervalSeconds) * time.Second
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	slots := make(chan struct{}, r.Concurrency)
+	var wg sync.WaitGroup
+	for {
+		r.claimAndRun(slots, &wg)
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping, waiting for running checks...")
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// register retries with backoff, so agents can start before the server
+func (r *Runner) register(ctx context.Context) (RegisterResponse, error) {
+	hostname, _ := os.Hostname()
+	backoff := time.Second
+	for {
+		reg, err := r.Client.Register(RegisterRequest{Version: r.Version, Hostname: hostname})
+		if err == nil {
+			return reg, nil
+		}
+		log.Printf("Register failed, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return reg, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRegisterBackoff {
+			backoff = maxRegisterBackoff
+		}
+	}
+}
+
+// claimAndRun claims as many checks as there are free slots and runs each
+// in its own goroutine
+func (r *Runner) claimAndRun(slots chan struct{}, wg *sync.WaitGroup) {
+	free := cap(slots) - len(slots)
+	if free == 0 {
+		return
+	}
+	jobs, err := r.Client.Claim(free)
+	if err != nil {
+		log.Printf("Claim failed: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		// Only this loop fills slots, so a slot counted free is still free
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			r.runJob(job)
+		}(job)
+	}
+}
+
+func (r *Runner) runJob(job Job) {
+	outcome := probe.RunWithRetries(job.Check, &job.Secrets)
+	resp, err := r.Client.PushResults([]Result{{CheckID: job.Check.ID, Outcome: outcome}})
+	if err != nil {
+		log.Printf("Pushing result for check %d failed: %v", job.Check.ID, err)
+		return
+	}
+	for _, rejected := range resp.Rejected {
+		log.Printf("Result for check %d rejected: %s", rejected.CheckID, rejected.Error)
+	}
+}
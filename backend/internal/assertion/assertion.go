@@ -0,0 +1,231 @@
+// Package assertion validates and evaluates the response assertions of HTTP checks
+package assertion
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+// Limits on assertion definitions
+const (
+	MaxAssertions  = 20
+	maxValueLength = 1024
+)
+
+// MaxBodyBytes is how much of a response body assertions can see
+const MaxBodyBytes = 1 << 20
+
+// Response is the part of an HTTP response assertions inspect
+type Response struct {
+	StatusCode     int
+	Header         http.Header
+	Body           []byte
+	BodyTruncated  bool // Body stopped at MaxBodyBytes
+	ResponseTimeMs int64
+}
+
+// Failure is an assertion that did not hold
+type Failure struct {
+	Index     int // Position in the check's list
+	Assertion models.Assertion
+	Reason    string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("assertion %d (%s) failed: %s", f.Index+1, f.Assertion.Type, f.Reason)
+}
+
+// Validate checks that an assertion is well formed
+func Validate(a models.Assertion) error {
+	if len(a.Value) > maxValueLength || len(a.Target) > maxValueLength {
+		return fmt.Errorf("%s assertion target and value are limited to %d characters", a.Type, maxValueLength)
+	}
+	switch a.Type {
+	case models.AssertionStatusCode:
+		if len(a.StatusCodes) == 0 {
+			return errors.New("status_code assertion needs status_codes")
+		}
+		for _, code := range a.StatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("invalid status code %d", code)
+			}
+		}
+	case models.AssertionBodyContains, models.AssertionBodyNotContains:
+		if a.Value == "" {
+			return fmt.Errorf("%s assertion needs a value", a.Type)
+		}
+	case models.AssertionBodyRegex:
+		if a.Value == "" {
+			return errors.New("body_regex assertion needs a value")
+		}
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return fmt.Errorf("invalid body_regex: %v", err)
+		}
+	case models.AssertionJSONPathEquals, models.AssertionJSONPathExists:
+		if _, err := parsePath(a.Target); err != nil {
+			return err
+		}
+	case models.AssertionHeaderEquals:
+		if strings.TrimSpace(a.Target) == "" || strings.ContainsAny(a.Target, ": \t") {
+			return errors.New("header_equals assertion needs a header name as target")
+		}
+	case models.AssertionResponseTime:
+		if a.MaxMs <= 0 {
+			return errors.New("response_time assertion needs a positive max_ms")
+		}
+	default:
+		return fmt.Errorf("unknown assertion type %q", a.Type)
+	}
+	return nil
+}
+
+// HasStatusAssertion reports whether the list sets its own accepted status codes
+func HasStatusAssertion(assertions []models.Assertion) bool {
+	for _, a := range assertions {
+		if a.Type == models.AssertionStatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsBody reports whether any assertion inspects the response body
+func NeedsBody(assertions []models.Assertion) bool {
+	for _, a := range assertions {
+		switch a.Type {
+		case models.AssertionBodyContains, models.AssertionBodyNotContains, models.AssertionBodyRegex,
+			models.AssertionJSONPathEquals, models.AssertionJSONPathExists:
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate runs every assertion against the response and returns those
+// that failed, in list order
+func Evaluate(assertions []models.Assertion, resp Response) []Failure {
+	var failures []Failure
+	var doc interface{}
+	var docErr error
+	decoded := false
+
+	for i, a := range assertions {
+		var reason string
+		switch a.Type {
+		case models.AssertionStatusCode:
+			if !containsInt(a.StatusCodes, resp.StatusCode) {
+				reason = fmt.Sprintf("status %d not in %v", resp.StatusCode, a.StatusCodes)
+			}
+		case models.AssertionBodyContains:
+			if !bytes.Contains(resp.Body, []byte(a.Value)) {
+				reason = fmt.Sprintf("body does not contain %q", a.Value)
+			}
+		case models.AssertionBodyNotContains:
+			if bytes.Contains(resp.Body, []byte(a.Value)) {
+				reason = fmt.Sprintf("body contains %q", a.Value)
+			}
+		case models.AssertionBodyRegex:
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				reason = fmt.Sprintf("invalid regex: %v", err)
+			} else if !re.Match(resp.Body) {
+				reason = fmt.Sprintf("body does not match /%s/", a.Value)
+			}
+		case models.AssertionJSONPathEquals, models.AssertionJSONPathExists:
+			if !decoded {
+				doc, docErr = decodeBody(resp)
+				decoded = true
+			}
+			reason = checkJSONPath(a, doc, docErr)
+		case models.AssertionHeaderEquals:
+			if got := resp.Header.Get(a.Target); got != a.Value {
+				reason = fmt.Sprintf("header %s is %q, want %q", a.Target, got, a.Value)
+			}
+		case models.AssertionResponseTime:
+			if resp.ResponseTimeMs >= a.MaxMs {
+				reason = fmt.Sprintf("response took %dms, limit %dms", resp.ResponseTimeMs, a.MaxMs)
+			}
+		default:
+			reason = fmt.Sprintf("unknown assertion type %q", a.Type)
+		}
+		if reason != "" {
+			failures = append(failures, Failure{Index: i, Assertion: a, Reason: reason})
+		}
+	}
+	return failures
+}
+
+// decodeBody parses the body as JSON, keeping numbers exact
+func decodeBody(resp Response) (interface{}, error) {
+	if resp.BodyTruncated {
+		return nil, fmt.Errorf("body exceeds %d bytes", MaxBodyBytes)
+	}
+	dec := json.NewDecoder(bytes.NewReader(resp.Body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errors.New("body is not valid JSON")
+	}
+	return doc, nil
+}
+
+func checkJSONPath(a models.Assertion, doc interface{}, docErr error) string {
+	if docErr != nil {
+		return docErr.Error()
+	}
+	segments, err := parsePath(a.Target)
+	if err != nil {
+		return err.Error()
+	}
+	value, ok := lookup(doc, segments)
+	if !ok {
+		return fmt.Sprintf("%s not found", a.Target)
+	}
+	if a.Type == models.AssertionJSONPathEquals && !jsonValueEquals(value, a.Value) {
+		return fmt.Sprintf("%s is %s, want %q", a.Target, formatJSONValue(value), a.Value)
+	}
+	return ""
+}
+
+// jsonValueEquals compares a decoded JSON value with the assertion's string
+// form: strings compare as-is, numbers numerically, everything else by its
+// compact JSON encoding (true, null, {"a":1})
+func jsonValueEquals(value interface{}, want string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == want
+	case json.Number:
+		got, err1 := v.Float64()
+		expected, err2 := strconv.ParseFloat(strings.TrimSpace(want), 64)
+		if err1 == nil && err2 == nil {
+			return got == expected
+		}
+		return v.String() == want
+	}
+	return formatJSONValue(value) == strings.TrimSpace(want)
+}
+
+func formatJSONValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
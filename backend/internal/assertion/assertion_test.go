@@ -0,0 +1,97 @@
+package assertion
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+func TestEvaluate(t *testing.T) {
+	resp := Response{
+		StatusCode:     200,
+		Header:         http.Header{"Content-Type": []string{"application/json"}},
+		Body:           []byte(`{"status":"ok","version":2,"items":[{"name":"db","healthy":true}],"odd key":null}`),
+		ResponseTimeMs: 120,
+	}
+
+	tests := []struct {
+		name      string
+		assertion models.Assertion
+		pass      bool
+	}{
+		{"status in set", models.Assertion{Type: models.AssertionStatusCode, StatusCodes: []int{200, 204}}, true},
+		{"status not in set", models.Assertion{Type: models.AssertionStatusCode, StatusCodes: []int{201}}, false},
+		{"contains", models.Assertion{Type: models.AssertionBodyContains, Value: `"ok"`}, true},
+		{"not contains", models.Assertion{Type: models.AssertionBodyNotContains, Value: "error"}, true},
+		{"not contains hit", models.Assertion{Type: models.AssertionBodyNotContains, Value: "healthy"}, false},
+		{"regex", models.Assertion{Type: models.AssertionBodyRegex, Value: `"version":\d+`}, true},
+		{"path equals string", models.Assertion{Type: models.AssertionJSONPathEquals, Target: "$.status", Value: "ok"}, true},
+		{"path equals number", models.Assertion{Type: models.AssertionJSONPathEquals, Target: "$.version", Value: "2.0"}, true},
+		{"path equals bool in array", models.Assertion{Type: models.AssertionJSONPathEquals, Target: "$.items[0].healthy", Value: "true"}, true},
+		{"path equals mismatch", models.Assertion{Type: models.AssertionJSONPathEquals, Target: "$.items[0]['name']", Value: "cache"}, false},
+		{"path exists null", models.Assertion{Type: models.AssertionJSONPathExists, Target: "$['odd key']"}, true},
+		{"path missing index", models.Assertion{Type: models.AssertionJSONPathExists, Target: "$.items[3]"}, false},
+		{"header", models.Assertion{Type: models.AssertionHeaderEquals, Target: "content-type", Value: "application/json"}, true},
+		{"response time", models.Assertion{Type: models.AssertionResponseTime, MaxMs: 100}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.assertion); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			failures := Evaluate([]models.Assertion{tt.assertion}, resp)
+			if pass := len(failures) == 0; pass != tt.pass {
+				t.Errorf("pass = %v, want %v (failures %v)", pass, tt.pass, failures)
+			}
+		})
+	}
+}
+
+func TestEvaluate_NonJSONBody(t *testing.T) {
+	resp := Response{StatusCode: 200, Body: []byte("<html>Service Unavailable</html>")}
+	failures := Evaluate([]models.Assertion{
+		{Type: models.AssertionBodyContains, Value: "Service"},
+		{Type: models.AssertionJSONPathExists, Target: "$.status"},
+	}, resp)
+	if len(failures) != 1 || failures[0].Index != 1 {
+		t.Fatalf("failures = %v, want only the json path", failures)
+	}
+	if msg := failures[0].String(); !strings.Contains(msg, "assertion 2 (json_path_exists)") || !strings.Contains(msg, "not valid JSON") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestValidate_Rejects(t *testing.T) {
+	for _, a := range []models.Assertion{
+		{Type: "body_length"},
+		{Type: models.AssertionStatusCode},
+		{Type: models.AssertionStatusCode, StatusCodes: []int{999}},
+		{Type: models.AssertionBodyRegex, Value: "("},
+		{Type: models.AssertionJSONPathEquals, Target: "status"},
+		{Type: models.AssertionJSONPathExists, Target: "$.items[*]"},
+		{Type: models.AssertionHeaderEquals, Target: "X-Bad: header"},
+		{Type: models.AssertionResponseTime},
+	} {
+		if err := Validate(a); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", a)
+		}
+	}
+}
+
+func TestExtractJSONPath(t *testing.T) {
+	body := []byte(`{"data":{"token":"abc123","expires_in":3600,"scopes":["read"]}}`)
+	for path, want := range map[string]string{
+		"$.data.token":      "abc123",
+		"$.data.expires_in": "3600",
+		"$.data.scopes":     `["read"]`,
+	} {
+		if got, err := ExtractJSONPath(body, path); err != nil || got != want {
+			t.Errorf("ExtractJSONPath(%s) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if _, err := ExtractJSONPath(body, "$.data.refresh"); err == nil {
+		t.Error("missing path extracted without error")
+	}
+}
@@ -0,0 +1,112 @@
+package assertion
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is one step of a parsed JSONPath: an object key or an array index
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses the JSONPath subset checks support: a leading $, then
+// any mix of .key, ['key'] / ["key"] and [n] steps. Wildcards, filters,
+// slices and recursive descent are not supported.
+func parsePath(path string) ([]pathSegment, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+
+	var segments []pathSegment
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := 1
+			for end < len(rest) && rest[end] != '.' && rest[end] != '[' {
+				end++
+			}
+			key := rest[1:end]
+			if key == "" || key == "*" || key == "." {
+				return nil, fmt.Errorf("json path %q has an empty or unsupported key", path)
+			}
+			segments = append(segments, pathSegment{key: key})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q has an unclosed [", path)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("json path %q has an unsupported selector [%s]", path, inner)
+				}
+				segments = append(segments, pathSegment{index: n, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("json path %q is malformed near %q", path, rest)
+		}
+	}
+	return segments, nil
+}
+
+// lookup walks a decoded JSON document; ok is false when any step is missing
+func lookup(doc interface{}, segments []pathSegment) (interface{}, bool) {
+	current := doc
+	for _, seg := range segments {
+		if seg.isIndex {
+			arr, ok := current.([]interface{})
+			if !ok || seg.index >= len(arr) {
+				return nil, false
+			}
+			current = arr[seg.index]
+			continue
+		}
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[seg.key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// ValidatePath checks that a JSONPath is within the supported subset
+func ValidatePath(path string) error {
+	_, err := parsePath(path)
+	return err
+}
+
+// ExtractJSONPath returns the value at path in a JSON body: strings as-is,
+// anything else in its compact JSON form
+func ExtractJSONPath(body []byte, path string) (string, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+	doc, err := decodeBody(Response{Body: body})
+	if err != nil {
+		return "", err
+	}
+	value, ok := lookup(doc, segments)
+	if !ok {
+		return "", fmt.Errorf("%s not found", path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return formatJSONValue(value), nil
+}
@@ -27,8 +27,29 @@ func Connect(cfg *config.Config) (*gorm.DB, error) {
     return db, nil
 }
 
+// Close closes the connection pool once nothing is using it anymore
+func Close(db *gorm.DB) error {
+    sqlDB, err := db.DB()
+    if err != nil {
+        return err
+    }
+    return sqlDB.Close()
+}
+
+// Migrate brings the schema up to date: AutoMigrate creates tables and
+// columns from the models (the baseline), then every pending versioned
+// migration in migrations/ and goMigrations runs in order. Failures are
+// returned so the server refuses to start on a half-migrated schema.
 func Migrate(db *gorm.DB) error {
-    err := db.AutoMigrate(
+    count, err := MigrateUp(db, 0)
+    if count > 0 {
+        log.Printf("Applied %d migration(s)", count)
+    }
+    return err
+}
+
+func autoMigrateModels(db *gorm.DB) error {
+    return db.AutoMigrate(
         &models.Organization{},
         &models.User{},
         &models.Check{},
@@ -42,74 +63,18 @@ func Migrate(db *gorm.DB) error {
         &models.Invite{},
         &models.AuditLog{},
         &models.MonthlyUsage{},
+        &models.ServiceEdge{},
+        &models.SpanRollup{},
+        &models.RetentionRun{},
+        &models.MaintenanceWindow{},
+        &models.Incident{},
+        &models.IncidentEvent{},
+        &models.EscalationPolicy{},
+        &models.OnCallSchedule{},
+        &models.OnCallOverride{},
+        &models.ProbeAgent{},
+        &models.CheckRegionRun{},
     )
-    if err != nil {
-        return err
-    }
-    // Run custom index migrations
-    if err := createObservabilityIndexes(db); err != nil {
-        log.Printf("Warning: some indexes may not have been created: %v", err)
-    }
-    // Run data migrations
-    if err := migrateExistingUsers(db); err != nil {
-        log.Printf("Warning: user migration may have failed: %v", err)
-    }
-    if err := migrateExistingOrgsToFreePlan(db); err != nil {
-        log.Printf("Warning: org plan migration may have failed: %v", err)
-    }
-    if err := migrateOrganizationSlugs(db); err != nil {
-        log.Printf("Warning: org slug migration may have failed: %v", err)
-    }
-    if err := migrateOrgNameUniqueness(db); err != nil {
-        log.Printf("Warning: org name uniqueness migration may have failed: %v", err)
-    }
-    return nil
-}
-
-// migrateExistingUsers sets default role for existing users without one
-func migrateExistingUsers(db *gorm.DB) error {
-    // Set existing users without a role to 'owner' (they created the org)
-    return db.Exec(`
-        UPDATE users
-        SET role = 'owner'
-        WHERE role IS NULL OR role = ''
-    `).Error
-}
-
-// migrateExistingOrgsToFreePlan ensures all existing orgs have a plan set
-func migrateExistingOrgsToFreePlan(db *gorm.DB) error {
-    // Set existing orgs without a plan to 'free'
-    return db.Exec(`
-        UPDATE organizations
-        SET plan = 'free'
-        WHERE plan IS NULL OR plan = ''
-    `).Error
-}
-
-func createObservabilityIndexes(db *gorm.DB) error {
-    indexes := []string{
-        // Check Results indexes
-        `CREATE INDEX IF NOT EXISTS idx_check_results_org_svc_env_created ON check_results (org_id, service_name, environment, created_at DESC)`,
-        `CREATE INDEX IF NOT EXISTS idx_check_results_org_status_created ON check_results (org_id, status_code, created_at DESC)`,
-        `CREATE INDEX IF NOT EXISTS idx_check_results_tags_gin ON check_results USING GIN (tags)`,
-        // Log Entries indexes
-        `CREATE INDEX IF NOT EXISTS idx_log_entries_org_env_ts ON log_entries (org_id, environment, timestamp DESC)`,
-        `CREATE INDEX IF NOT EXISTS idx_log_entries_tags_gin ON log_entries USING GIN (tags)`,
-        `CREATE INDEX IF NOT EXISTS idx_log_entries_trace ON log_entries (trace_id) WHERE trace_id IS NOT NULL AND trace_id != ''`,
-        // Trace Spans indexes
-        `CREATE INDEX IF NOT EXISTS idx_trace_spans_org_trace_start ON trace_spans (org_id, trace_id, start_time DESC)`,
-        `CREATE INDEX IF NOT EXISTS idx_trace_spans_tags_gin ON trace_spans USING GIN (tags)`,
-        `CREATE INDEX IF NOT EXISTS idx_trace_spans_org_duration ON trace_spans (org_id, duration_ms DESC, start_time DESC)`,
-        // Checks indexes
-        `CREATE INDEX IF NOT EXISTS idx_checks_org_svc_env ON checks (org_id, service_name, environment) WHERE deleted_at IS NULL`,
-        `CREATE INDEX IF NOT EXISTS idx_checks_tags_gin ON checks USING GIN (tags)`,
-    }
-    for _, idx := range indexes {
-        if err := db.Exec(idx).Error; err != nil {
-            log.Printf("Index creation warning: %v", err)
-        }
-    }
-    return nil
 }
 
 // migrateOrgNameUniqueness deduplicates existing org names and creates a
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SQL migrations live in migrations/ as NNN_name.up.sql and NNN_name.down.sql.
+// Changes that need Go (slug generation, partition conversion) are
+// registered in goMigrations instead. Versions share one sequence.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Advisory lock key shared by every instance; only one may migrate at a time
+const migrationLockKey = 827_314_001
+
+// ErrIrreversible is returned when rolling back a migration that has no down step
+var ErrIrreversible = errors.New("migration is irreversible")
+
+// Migration is one versioned schema or data change
+type Migration struct {
+	Version int
+	Name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus is a known migration and when it was applied, if ever
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// schemaMigration is a row of the schema_migrations table
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+var goMigrations = []Migration{
+	{Version: 4, Name: "organization_slugs", up: migrateOrganizationSlugs},
+	{Version: 5, Name: "org_name_uniqueness", up: migrateOrgNameUniqueness, down: func(tx *gorm.DB) error {
+		return tx.Exec(`DROP INDEX IF EXISTS idx_organizations_name_unique`).Error
+	}},
+	{Version: 6, Name: "partition_tables", up: setupPartitioning},
+}
+
+// loadMigrations merges embedded SQL files and Go migrations, sorted by version
+func loadMigrations() ([]Migration, error) {
+	byVersion := make(map[int]*Migration)
+	for i := range goMigrations {
+		m := goMigrations[i]
+		if _, dup := byVersion[m.Version]; dup {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+		byVersion[m.Version] = &m
+	}
+
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		file := entry.Name()
+		base, direction, ok := splitMigrationFile(file)
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+		versionStr, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 || name == "" {
+			return nil, fmt.Errorf("invalid migration file name %q", file)
+		}
+
+		contents, err := migrationFiles.ReadFile(path.Join("migrations", file))
+		if err != nil {
+			return nil, err
+		}
+		sql := string(contents)
+		step := func(tx *gorm.DB) error { return tx.Exec(sql).Error }
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration version %d used by both %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			if m.up != nil {
+				return nil, fmt.Errorf("duplicate up step for migration %d", version)
+			}
+			m.up = step
+		} else {
+			if m.down != nil {
+				return nil, fmt.Errorf("duplicate down step for migration %d", version)
+			}
+			m.down = step
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == nil {
+			return nil, fmt.Errorf("migration %d (%s) has no up step", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitMigrationFile splits "001_name.up.sql" into ("001_name", "up")
+func splitMigrationFile(file string) (string, string, bool) {
+	for _, direction := range []string{"up", "down"} {
+		if base, ok := strings.CutSuffix(file, "."+direction+".sql"); ok {
+			return base, direction, true
+		}
+	}
+	return "", "", false
+}
+
+// withMigrationLock runs fn on a single pinned connection holding a
+// session-level advisory lock, so concurrent deploys migrate one at a time
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec(`SELECT pg_advisory_lock(?)`, migrationLockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec(`SELECT pg_advisory_unlock(?)`, migrationLockKey).Error; err != nil {
+				log.Printf("Warning: failed to release migration lock: %v", err)
+			}
+		}()
+
+		if err := ensureMigrationsTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func ensureMigrationsTable(conn *gorm.DB) error {
+	if err := conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func appliedMigrations(conn *gorm.DB) (map[int]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := conn.Table("schema_migrations").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]schemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// applyPending runs up to limit pending migrations in version order (all
+// when limit <= 0). Each migration and its schema_migrations row commit in
+// one transaction; the first failure stops the run.
+func applyPending(conn *gorm.DB, limit int) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+		if limit > 0 && count >= limit {
+			break
+		}
+		log.Printf("Applying migration %03d_%s...", m.Version, m.Name)
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %03d_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateUp runs the AutoMigrate baseline, then applies up to n pending
+// migrations (all when n <= 0)
+func MigrateUp(db *gorm.DB, n int) (int, error) {
+	var count int
+	err := withMigrationLock(db, func(conn *gorm.DB) error {
+		if err := autoMigrateModels(conn); err != nil {
+			return fmt.Errorf("auto-migrate failed: %w", err)
+		}
+		var err error
+		count, err = applyPending(conn, n)
+		return err
+	})
+	return count, err
+}
+
+// MigrateDown rolls back the n most recently applied migrations
+func MigrateDown(db *gorm.DB, n int) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	count := 0
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+		var rows []schemaMigration
+		if err := conn.Table("schema_migrations").Order("version DESC").Limit(n).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			m, ok := known[row.Version]
+			if !ok {
+				return fmt.Errorf("migration %d (%s) is applied but not known to this binary", row.Version, row.Name)
+			}
+			if m.down == nil {
+				return fmt.Errorf("migration %03d_%s: %w", m.Version, m.Name, ErrIrreversible)
+			}
+			log.Printf("Rolling back migration %03d_%s...", m.Version, m.Name)
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.down(tx); err != nil {
+					return err
+				}
+				return tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %03d_%s failed: %w", m.Version, m.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// MigrationStatuses lists every known migration plus any applied migration
+// this binary doesn't know about, in version order
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read-only, so no lock: status works while another instance migrates
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			s.AppliedAt = &appliedAt
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name + " (unknown)", AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
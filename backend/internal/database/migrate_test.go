@@ -0,0 +1,34 @@
+package database
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations loaded")
+	}
+	for i, m := range migrations {
+		if m.up == nil {
+			t.Errorf("migration %d has no up step", m.Version)
+		}
+		if i > 0 && migrations[i-1].Version >= m.Version {
+			t.Errorf("migrations out of order at %d", m.Version)
+		}
+	}
+	if migrations[0].Name != "observability_indexes" || migrations[0].down == nil {
+		t.Errorf("first migration = %+v, want observability_indexes with a down step", migrations[0])
+	}
+}
+
+func TestSplitMigrationFile(t *testing.T) {
+	base, dir, ok := splitMigrationFile("007_add_things.down.sql")
+	if !ok || base != "007_add_things" || dir != "down" {
+		t.Errorf("got %q %q %v", base, dir, ok)
+	}
+	if _, _, ok := splitMigrationFile("007_add_things.sql"); ok {
+		t.Error("file without direction should be rejected")
+	}
+}
@@ -0,0 +1,19 @@
+-- Revert: drop observability indexes
+
+DROP INDEX IF EXISTS idx_check_results_org_svc_env_created;
+DROP INDEX IF EXISTS idx_check_results_org_status_created;
+DROP INDEX IF EXISTS idx_check_results_tags_gin;
+DROP INDEX IF EXISTS idx_check_results_trace;
+
+DROP INDEX IF EXISTS idx_log_entries_org_svc_level_ts;
+DROP INDEX IF EXISTS idx_log_entries_org_env_ts;
+DROP INDEX IF EXISTS idx_log_entries_tags_gin;
+DROP INDEX IF EXISTS idx_log_entries_trace;
+
+DROP INDEX IF EXISTS idx_trace_spans_org_svc_status_start;
+DROP INDEX IF EXISTS idx_trace_spans_org_trace_start;
+DROP INDEX IF EXISTS idx_trace_spans_tags_gin;
+DROP INDEX IF EXISTS idx_trace_spans_org_duration;
+
+DROP INDEX IF EXISTS idx_checks_org_svc_env;
+DROP INDEX IF EXISTS idx_checks_tags_gin;
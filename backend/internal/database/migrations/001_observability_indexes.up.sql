@@ -1,5 +1,5 @@
 -- Migration: Add observability indexes for efficient filtering
--- Run after models are migrated via GORM AutoMigrate
+-- Runs after the GORM AutoMigrate baseline (see database.Migrate)
 
 -- ============================================
 -- Check Results Indexes
@@ -0,0 +1,2 @@
+-- Irreversible data fix: the original empty roles are not recorded.
+-- Rolling back leaves the assigned roles in place.
@@ -0,0 +1,6 @@
+-- Migration: Set default role for existing users without one
+-- Users created before roles existed created their org, so they are owners
+
+UPDATE users
+SET role = 'owner'
+WHERE role IS NULL OR role = '';
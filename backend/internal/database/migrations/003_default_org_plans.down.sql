@@ -0,0 +1,2 @@
+-- Irreversible data fix: the original empty plans are not recorded.
+-- Rolling back leaves the assigned plans in place.
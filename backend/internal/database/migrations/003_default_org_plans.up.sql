@@ -0,0 +1,5 @@
+-- Migration: Ensure all existing organizations have a plan set
+
+UPDATE organizations
+SET plan = 'free'
+WHERE plan IS NULL OR plan = '';
@@ -0,0 +1,4 @@
+-- The state column itself belongs to the AutoMigrate baseline; rolling back
+-- only clears the backfilled values.
+
+UPDATE checks SET state = NULL;
@@ -0,0 +1,6 @@
+-- Migration: Backfill checks.state from last_status
+-- Before assertions a check was up exactly when its last status was 2xx
+
+UPDATE checks
+SET state = CASE WHEN last_status BETWEEN 200 AND 299 THEN 'UP' ELSE 'DOWN' END
+WHERE last_status IS NOT NULL AND (state IS NULL OR state = '');
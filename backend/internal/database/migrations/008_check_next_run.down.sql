@@ -0,0 +1,4 @@
+-- The scheduling columns belong to the AutoMigrate baseline; rolling back
+-- only clears the backfilled values.
+
+UPDATE checks SET next_run_at = NULL;
@@ -0,0 +1,10 @@
+-- Migration: Backfill checks.next_run_at for the leasing scheduler
+-- Spread existing checks over the same jitter the scheduler adds (a tenth
+-- of the interval, at most 30s) so the first poll after deploy doesn't run
+-- them all at once. Never-run checks stay NULL and run right away.
+
+UPDATE checks
+SET next_run_at = last_checked_at
+    + interval_seconds * interval '1 second'
+    + random() * LEAST(interval_seconds / 10.0, 30) * interval '1 second'
+WHERE next_run_at IS NULL AND last_checked_at IS NOT NULL;
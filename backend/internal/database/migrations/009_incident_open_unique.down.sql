@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_incidents_check_open;
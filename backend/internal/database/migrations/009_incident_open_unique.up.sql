@@ -0,0 +1,6 @@
+-- Migration: At most one open incident per check
+-- Alerts attach to the check's open incident; two open at once would split
+-- an outage's timeline.
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incidents_check_open
+ON incidents (check_id) WHERE state <> 'resolved';
@@ -0,0 +1,390 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// Check results have no plan-based retention; keep roughly 13 months so
+// year-over-year uptime stays available
+const checkResultRetention = 395 * 24 * time.Hour
+
+// Expired rows are purged from a default partition in batches of this size,
+// at most defaultPurgeMaxBatches per table per run
+const (
+	defaultPurgeBatchSize  = 5000
+	defaultPurgeMaxBatches = 20
+)
+
+// partitionInterval is the time range covered by one partition
+type partitionInterval int
+
+const (
+	partitionDaily partitionInterval = iota
+	partitionMonthly
+)
+
+// partitionedTable describes a high-volume table stored with native range
+// partitioning on a timestamp column. Each table also has a DEFAULT
+// partition that catches rows outside every range (e.g. client timestamps
+// far in the future); they are moved out when a matching partition is created.
+type partitionedTable struct {
+	name      string
+	column    string
+	interval  partitionInterval
+	ahead     int // Future partitions kept ready
+	retention func(db *gorm.DB) time.Duration
+}
+
+var partitionedTables = []partitionedTable{
+	{name: "log_entries", column: "timestamp", interval: partitionDaily, ahead: 7, retention: logRetentionHorizon},
+	{name: "trace_spans", column: "start_time", interval: partitionDaily, ahead: 7, retention: logRetentionHorizon},
+	{name: "check_results", column: "created_at", interval: partitionMonthly, ahead: 2, retention: func(*gorm.DB) time.Duration { return checkResultRetention }},
+}
+
+// periodStart truncates t to the start of its partition (UTC)
+func (p partitionedTable) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	if p.interval == partitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// next returns the start of the partition after the one starting at start
+func (p partitionedTable) next(start time.Time) time.Time {
+	if p.interval == partitionMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func (p partitionedTable) suffixLayout() string {
+	if p.interval == partitionMonthly {
+		return "200601"
+	}
+	return "20060102"
+}
+
+// partitionName is e.g. trace_spans_p20240131 or check_results_p202401
+func (p partitionedTable) partitionName(start time.Time) string {
+	return p.name + "_p" + start.Format(p.suffixLayout())
+}
+
+// parsePartitionName returns the start of a partition from its name.
+// ok is false for the default partition or anything not created here.
+func (p partitionedTable) parsePartitionName(rel string) (time.Time, bool) {
+	prefix := p.name + "_p"
+	if len(rel) <= len(prefix) || rel[:len(prefix)] != prefix {
+		return time.Time{}, false
+	}
+	start, err := time.Parse(p.suffixLayout(), rel[len(prefix):])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+func (p partitionedTable) defaultPartition() string {
+	return p.name + "_default"
+}
+
+// setupPartitioning converts the AutoMigrate-created plain tables into
+// partitioned tables (migration 006). Already-partitioned tables are skipped.
+func setupPartitioning(db *gorm.DB) error {
+	for _, p := range partitionedTables {
+		if err := convertToPartitioned(db, p); err != nil {
+			return fmt.Errorf("failed to partition %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// isPartitioned reports whether the table exists as a partitioned table
+func isPartitioned(db *gorm.DB, table string) (bool, error) {
+	var relkind string
+	err := db.Raw(`
+		SELECT c.relkind FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = ? AND n.nspname = current_schema()
+	`, table).Scan(&relkind).Error
+	return relkind == "p", err
+}
+
+// convertToPartitioned rebuilds a plain table as a range-partitioned one,
+// copying existing rows across. Runs in one transaction, so a failure leaves
+// the original table untouched. Existing rows older than the retention
+// horizon are not copied, since they would have no partition to live in;
+// how many were dropped is logged.
+func convertToPartitioned(db *gorm.DB, p partitionedTable) error {
+	partitioned, err := isPartitioned(db, p.name)
+	if err != nil || partitioned {
+		return err
+	}
+
+	log.Printf("Converting %s to a partitioned table...", p.name)
+	started := time.Now()
+	legacy := p.name + "_legacy"
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var sequence *string
+		if err := tx.Raw(`SELECT pg_get_serial_sequence(?, 'id')`, p.name).Scan(&sequence).Error; err != nil {
+			return err
+		}
+
+		statements := []string{
+			fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, p.name, legacy),
+			fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (%s)`, p.name, legacy, p.column),
+			fmt.Sprintf(`CREATE TABLE %s PARTITION OF %s DEFAULT`, p.defaultPartition(), p.name),
+		}
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+
+		// Partitions start at the oldest row still within retention, or at
+		// the current period for an empty table
+		now := time.Now()
+		from := now
+		var oldest *time.Time
+		if err := tx.Raw(fmt.Sprintf(`SELECT MIN(%s) FROM %s`, p.column, legacy)).Scan(&oldest).Error; err != nil {
+			return err
+		}
+		if oldest != nil && oldest.Before(from) {
+			from = *oldest
+			if horizon := now.Add(-p.retention(tx)); from.Before(horizon) {
+				from = horizon
+			}
+		}
+		if err := ensurePartitions(tx, p, from, now); err != nil {
+			return err
+		}
+
+		cutoff := p.periodStart(from)
+		var expired int64
+		if err := tx.Raw(fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s < ?`, legacy, p.column), cutoff).Scan(&expired).Error; err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.Printf("Discarding %d rows of %s older than %s, past the retention horizon",
+				expired, p.name, cutoff.Format(time.RFC3339))
+		}
+		// Column order matches because the new table was created LIKE the old one
+		if err := tx.Exec(fmt.Sprintf(`INSERT INTO %s SELECT * FROM %s WHERE %s >= ?`, p.name, legacy, p.column),
+			cutoff).Error; err != nil {
+			return err
+		}
+		// Keep the id sequence alive when the legacy table is dropped
+		if sequence != nil {
+			if err := tx.Exec(fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY %s.id`, *sequence, p.name)).Error; err != nil {
+				return err
+			}
+		}
+
+		// Capture secondary indexes and foreign keys so they can be recreated
+		// on the new parent once the legacy table (and its names) are gone
+		var indexDefs []string
+		if err := tx.Raw(`
+			SELECT indexdef FROM pg_indexes
+			WHERE schemaname = current_schema() AND tablename = ? AND indexname <> ?
+		`, legacy, p.name+"_pkey").Scan(&indexDefs).Error; err != nil {
+			return err
+		}
+		var foreignKeys []struct {
+			Name       string
+			Definition string
+		}
+		if err := tx.Raw(`
+			SELECT conname AS name, pg_get_constraintdef(oid) AS definition FROM pg_constraint
+			WHERE conrelid = ?::regclass AND contype = 'f'
+		`, legacy).Scan(&foreignKeys).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(fmt.Sprintf(`DROP TABLE %s`, legacy)).Error; err != nil {
+			return err
+		}
+		// Unique constraints on a partitioned table must include the partition key
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD PRIMARY KEY (id, %s)`, p.name, p.column)).Error; err != nil {
+			return err
+		}
+		for _, def := range indexDefs {
+			if err := tx.Exec(strings.Replace(def, legacy, p.name, 1)).Error; err != nil {
+				return err
+			}
+		}
+		for _, fk := range foreignKeys {
+			if err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s %s`, p.name, fk.Name, fk.Definition)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Converted %s to a partitioned table in %s", p.name, time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// ensurePartitions creates every partition from the one containing from up
+// to p.ahead periods past the one containing now
+func ensurePartitions(db *gorm.DB, p partitionedTable, from, now time.Time) error {
+	end := p.periodStart(now)
+	for i := 0; i <= p.ahead; i++ {
+		end = p.next(end)
+	}
+	for start := p.periodStart(from); start.Before(end); start = p.next(start) {
+		if err := createPartition(db, p, start); err != nil {
+			return fmt.Errorf("failed to create partition %s: %w", p.partitionName(start), err)
+		}
+	}
+	return nil
+}
+
+// createPartition creates and attaches the partition starting at start.
+// Rows for its range already sitting in the default partition are moved
+// into it first; Postgres refuses to attach otherwise.
+func createPartition(db *gorm.DB, p partitionedTable, start time.Time) error {
+	name := p.partitionName(start)
+	var exists bool
+	if err := db.Raw(`SELECT to_regclass(?) IS NOT NULL`, name).Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	end := p.next(start)
+	// Bounds must be literals in ATTACH PARTITION; both are generated here
+	const layout = "2006-01-02 15:04:05-07"
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)`, name, p.name)).Error; err != nil {
+			return err
+		}
+		move := fmt.Sprintf(
+			`WITH moved AS (DELETE FROM %s WHERE %s >= ? AND %s < ? RETURNING *) INSERT INTO %s SELECT * FROM moved`,
+			p.defaultPartition(), p.column, p.column, name,
+		)
+		if err := tx.Exec(move, start, end).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf(`ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`,
+			p.name, name, start.Format(layout), end.Format(layout))).Error
+	})
+}
+
+// dropExpiredPartitions drops partitions whose whole range is older than the
+// table's retention horizon
+func dropExpiredPartitions(db *gorm.DB, p partitionedTable) error {
+	cutoff := time.Now().Add(-p.retention(db))
+
+	var partitions []string
+	if err := db.Raw(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = ?::regclass
+	`, p.name).Scan(&partitions).Error; err != nil {
+		return err
+	}
+
+	for _, rel := range partitions {
+		start, ok := p.parsePartitionName(rel)
+		if !ok || p.next(start).After(cutoff) {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf(`DROP TABLE %s`, rel)).Error; err != nil {
+			return fmt.Errorf("failed to drop partition %s: %w", rel, err)
+		}
+		log.Printf("[Partitions] Dropped expired partition %s", rel)
+	}
+	return nil
+}
+
+// purgeExpiredDefault deletes rows past the table's retention horizon from
+// its default partition, which dropExpiredPartitions never drops. Rows land
+// there when they are older than every partition, e.g. late-arriving data
+// or rows carried over by an earlier conversion.
+func purgeExpiredDefault(db *gorm.DB, p partitionedTable) error {
+	cutoff := time.Now().Add(-p.retention(db))
+	purge := fmt.Sprintf(
+		`DELETE FROM %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s < ? LIMIT ?)`,
+		p.defaultPartition(), p.defaultPartition(), p.column,
+	)
+	var total int64
+	for i := 0; i < defaultPurgeMaxBatches; i++ {
+		result := db.Exec(purge, cutoff, defaultPurgeBatchSize)
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge %s: %w", p.defaultPartition(), result.Error)
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < defaultPurgeBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("[Partitions] Purged %d expired rows from %s", total, p.defaultPartition())
+	}
+	return nil
+}
+
+// MaintainPartitions creates upcoming partitions and drops expired ones for
+// every partitioned table. Safe to run concurrently from several instances:
+// creation is skipped when the partition exists and a losing racer's
+// transaction simply fails and is retried next run.
+func MaintainPartitions(db *gorm.DB) error {
+	var errs []error
+	now := time.Now()
+	for _, p := range partitionedTables {
+		partitioned, err := isPartitioned(db, p.name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !partitioned {
+			// Conversion failed at startup; nothing to maintain
+			continue
+		}
+		if err := ensurePartitions(db, p, now, now); err != nil {
+			errs = append(errs, err)
+		}
+		if err := dropExpiredPartitions(db, p); err != nil {
+			errs = append(errs, err)
+		}
+		if err := purgeExpiredDefault(db, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// logRetentionHorizon is the longest log retention any org currently needs.
+// Per-org retention shorter than this is still enforced by row deletes; whole
+// partitions are dropped once every org is past them. Falls back to the
+// longest plan retention until every org has been swept once.
+func logRetentionHorizon(db *gorm.DB) time.Duration {
+	days := 0
+	for _, cfg := range models.PlanConfigs {
+		if cfg.LogRetentionDays > days {
+			days = cfg.LogRetentionDays
+		}
+	}
+
+	var row struct {
+		MaxApplied int
+		Unswept    int64
+	}
+	err := db.Model(&models.Organization{}).
+		Select("COALESCE(MAX(applied_retention_days), 0) AS max_applied, COUNT(*) FILTER (WHERE applied_retention_days = 0) AS unswept").
+		Scan(&row).Error
+	if err == nil && row.Unswept == 0 && row.MaxApplied > 0 && row.MaxApplied < days {
+		days = row.MaxApplied
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
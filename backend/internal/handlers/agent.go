@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/agent"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/probe"
+	"github.com/oFuterman/light-house/internal/worker"
+	"gorm.io/gorm"
+)
+
+const (
+	agentPollIntervalSeconds = 5
+	defaultAgentClaimLimit   = 10
+	maxAgentClaimLimit       = 100
+	maxAgentResults          = 100
+)
+
+// regionName is a valid probe region, e.g. "us-east" or "eu-west-1"
+var regionName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+
+type CreateAgentRequest struct {
+	Name   string `json:"name"`
+	Region string `json:"region"`
+}
+
+type CreateAgentResponse struct {
+	Agent models.ProbeAgent `json:"agent"`
+	Token string            `json:"token"` // Full token, only shown once
+}
+
+// ListAgents returns the org's probe agents
+// GET /api/v1/agents
+func ListAgents(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		var agents []models.ProbeAgent
+		if err := db.Where("org_id = ?", orgID).Order("region ASC, name ASC").Find(&agents).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch agents",
+			})
+		}
+		return c.JSON(agents)
+	}
+}
+
+// CreateAgent creates a probe agent and returns its token once
+// POST /api/v1/agents
+func CreateAgent(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		var req CreateAgentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name is required",
+			})
+		}
+		req.Region = strings.ToLower(strings.TrimSpace(req.Region))
+		if !regionName.MatchString(req.Region) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "region must be lowercase letters, digits and dashes (max 50)",
+			})
+		}
+
+		token, hash, err := models.GenerateAgentToken()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to generate agent token",
+			})
+		}
+		probeAgent := models.ProbeAgent{
+			OrgID:       orgID,
+			Name:        req.Name,
+			Region:      req.Region,
+			TokenHash:   hash,
+			TokenPrefix: token[:12],
+			CreatedByID: &userID,
+		}
+		if err := db.Create(&probeAgent).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create agent",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionAgentCreated, "agent", &probeAgent.ID, models.JSONMap{
+			"name":   probeAgent.Name,
+			"region": probeAgent.Region,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.Status(fiber.StatusCreated).JSON(CreateAgentResponse{
+			Agent: probeAgent,
+			Token: token,
+		})
+	}
+}
+
+// DeleteAgent deletes a probe agent, revoking its token. Runs it had
+// claimed are handed out again once their leases expire.
+// DELETE /api/v1/agents/:id
+func DeleteAgent(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+		agentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid agent ID",
+			})
+		}
+
+		var probeAgent models.ProbeAgent
+		if err := db.Where("id = ? AND org_id = ?", agentID, orgID).First(&probeAgent).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "agent not found",
+			})
+		}
+		if err := db.Delete(&probeAgent).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to delete agent",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionAgentDeleted, "agent", &probeAgent.ID, models.JSONMap{
+			"name":   probeAgent.Name,
+			"region": probeAgent.Region,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(fiber.Map{
+			"message": "agent deleted successfully",
+		})
+	}
+}
+
+// AgentRegister records an agent's version and host when it starts
+// POST /api/v1/agent/register
+func AgentRegister(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		probeAgent := c.Locals("agent").(*models.ProbeAgent)
+
+		var req agent.RegisterRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		updates := map[string]interface{}{
+			"version":  truncate(req.Version, 50),
+			"hostname": truncate(req.Hostname, 255),
+		}
+		if err := db.Model(probeAgent).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to register agent",
+			})
+		}
+
+		return c.JSON(agent.RegisterResponse{
+			AgentID:             probeAgent.ID,
+			Name:                probeAgent.Name,
+			Region:              probeAgent.Region,
+			PollIntervalSeconds: agentPollIntervalSeconds,
+		})
+	}
+}
+
+// AgentClaim leases checks due in the agent's region and returns them with
+// their credentials
+// POST /api/v1/agent/claim
+func AgentClaim(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		probeAgent := c.Locals("agent").(*models.ProbeAgent)
+
+		var req agent.ClaimRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		if req.Limit <= 0 {
+			req.Limit = defaultAgentClaimLimit
+		}
+		if req.Limit > maxAgentClaimLimit {
+			req.Limit = maxAgentClaimLimit
+		}
+
+		checks, err := worker.ClaimRegionalChecks(db, *probeAgent, req.Limit)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to claim checks",
+			})
+		}
+
+		jobs := make([]agent.Job, 0, len(checks))
+		for _, check := range checks {
+			sec, err := probe.OpenSecrets(check)
+			if err != nil {
+				// The agent can't run it without credentials; record the
+				// failure here as this region's result
+				outcome := probe.Outcome{StatusCode: probe.StatusDown, ErrorMessage: err.Error()}
+				if err := worker.RecordAgentResult(db, *probeAgent, check.ID, outcome); err != nil {
+					log.Printf("Error recording result for check %d: %v", check.ID, err)
+				}
+				continue
+			}
+			jobs = append(jobs, agent.Job{Check: check, Secrets: sec})
+		}
+		return c.JSON(agent.ClaimResponse{Jobs: jobs})
+	}
+}
+
+// AgentPushResults records the outcomes of runs the agent claimed
+// POST /api/v1/agent/results
+func AgentPushResults(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		probeAgent := c.Locals("agent").(*models.ProbeAgent)
+
+		var req agent.ResultsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		if len(req.Results) > maxAgentResults {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "too many results in one request",
+			})
+		}
+
+		var resp agent.ResultsResponse
+		for _, result := range req.Results {
+			outcome := result.Outcome
+			outcome.ErrorMessage = truncate(outcome.ErrorMessage, 1024)
+			err := worker.RecordAgentResult(db, *probeAgent, result.CheckID, outcome)
+			if err != nil {
+				msg := "failed to record result"
+				if errors.Is(err, worker.ErrLeaseLost) {
+					msg = err.Error()
+				} else {
+					log.Printf("Error recording agent %d result for check %d: %v", probeAgent.ID, result.CheckID, err)
+				}
+				resp.Rejected = append(resp.Rejected, agent.RejectedResult{CheckID: result.CheckID, Error: msg})
+				continue
+			}
+			resp.Accepted++
+		}
+		return c.JSON(resp)
+	}
+}
+
+// truncate cuts s to at most max bytes
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return strings.ToValidUTF8(s[:max], "")
+	}
+	return s
+}
@@ -18,6 +18,7 @@ type AlertResponse struct {
     AlertType    models.AlertType `json:"alert_type"`
     StatusCode   int              `json:"status_code"`
     ErrorMessage string           `json:"error_message,omitempty"`
+    IncidentID   *uint            `json:"incident_id,omitempty"`
 }
 
 // AlertsListResponse wraps the alerts array for consistent API responses
@@ -58,6 +59,7 @@ func toAlertResponse(alert models.Alert, checkName string) AlertResponse {
         AlertType:    alert.AlertType,
         StatusCode:   alert.StatusCode,
         ErrorMessage: alert.ErrorMessage,
+        IncidentID:   alert.IncidentID,
     }
 }
 
@@ -103,15 +105,25 @@ func GetCheckAlerts(db *gorm.DB) fiber.Handler {
     }
 }
 
-// GetOrgAlerts returns all alerts for the current organization
+// GetOrgAlerts returns all alerts for the current organization, optionally
+// only those of one incident (incident_id)
 func GetOrgAlerts(db *gorm.DB) fiber.Handler {
     return func(c *fiber.Ctx) error {
         orgID := c.Locals("orgID").(uint)
         limit, cutoff := parseAlertQueryParams(c)
+        query := db.Preload("Check").Where("org_id = ? AND created_at >= ?", orgID, cutoff)
+        if incidentParam := c.Query("incident_id"); incidentParam != "" {
+            incidentID, err := strconv.ParseUint(incidentParam, 10, 32)
+            if err != nil {
+                return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+                    "error": "invalid incident ID",
+                })
+            }
+            query = query.Where("incident_id = ?", incidentID)
+        }
         // Query alerts for this org within time window, preload check for name
         var alerts []models.Alert
-        if err := db.Preload("Check").
-            Where("org_id = ? AND created_at >= ?", orgID, cutoff).
+        if err := query.
             Order("created_at DESC").
             Limit(limit).
             Find(&alerts).Error; err != nil {
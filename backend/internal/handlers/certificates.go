@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// CertificateDTO is the TLS certificate last seen by an HTTPS check
+type CertificateDTO struct {
+	CheckID       uint       `json:"check_id"`
+	CheckName     string     `json:"check_name"`
+	URL           string     `json:"url"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	DaysRemaining int        `json:"days_remaining"`
+	Issuer        string     `json:"issuer"`
+	Subject       string     `json:"subject"`
+	SANs          []string   `json:"sans"`
+	HostnameValid bool       `json:"hostname_valid"`
+	ChainValid    bool       `json:"chain_valid"`
+	Error         string     `json:"error,omitempty"`
+	CheckedAt     *time.Time `json:"checked_at"`
+	AlertDays     []int64    `json:"alert_days"`
+}
+
+// ListCertificates returns the certificates of all the org's HTTPS checks,
+// soonest expiry first
+// GET /api/v1/certificates
+func ListCertificates(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		var checks []models.Check
+		if err := db.Where("org_id = ? AND cert_expires_at IS NOT NULL", orgID).
+			Order("cert_expires_at ASC").
+			Find(&checks).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch certificates",
+			})
+		}
+
+		now := time.Now()
+		certs := make([]CertificateDTO, 0, len(checks))
+		for _, check := range checks {
+			alertDays := []int64(check.CertAlertDays)
+			if alertDays == nil {
+				alertDays = models.DefaultCertAlertDays
+			}
+			cert := CertificateDTO{
+				CheckID:       check.ID,
+				CheckName:     check.Name,
+				URL:           check.URL,
+				ExpiresAt:     *check.CertExpiresAt,
+				DaysRemaining: int(check.CertExpiresAt.Sub(now).Hours() / 24),
+				Issuer:        check.CertIssuer,
+				Subject:       check.CertSubject,
+				SANs:          check.CertSANs,
+				Error:         check.CertError,
+				CheckedAt:     check.CertCheckedAt,
+				AlertDays:     alertDays,
+			}
+			if cert.SANs == nil {
+				cert.SANs = []string{}
+			}
+			if check.CertHostnameValid != nil {
+				cert.HostnameValid = *check.CertHostnameValid
+			}
+			if check.CertChainValid != nil {
+				cert.ChainValid = *check.CertChainValid
+			}
+			certs = append(certs, cert)
+		}
+
+		return c.JSON(certs)
+	}
+}
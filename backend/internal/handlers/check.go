@@ -1,37 +1,108 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/url"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/assertion"
 	"github.com/oFuterman/light-house/internal/billing"
 	"github.com/oFuterman/light-house/internal/models"
 	"github.com/oFuterman/light-house/internal/search"
+	"github.com/oFuterman/light-house/internal/secrets"
 	"gorm.io/gorm"
 )
 
 type CreateCheckRequest struct {
-	Name            string         `json:"name"`
-	URL             string         `json:"url"`
-	IntervalSeconds int            `json:"interval_seconds"`
-	ServiceName     string         `json:"service_name,omitempty"`
-	Environment     string         `json:"environment,omitempty"`
-	Region          string         `json:"region,omitempty"`
-	Tags            models.JSONMap `json:"tags,omitempty"`
+	Name                string             `json:"name"`
+	Type                string             `json:"type,omitempty"` // http (default), tcp, dns, multistep or heartbeat
+	URL                 string             `json:"url"`            // host:port for tcp, hostname for dns, none for heartbeat
+	TCPSend             string             `json:"tcp_send,omitempty"`
+	TCPExpect           string             `json:"tcp_expect,omitempty"`
+	DNSRecordType       string             `json:"dns_record_type,omitempty"`
+	DNSResolver         string             `json:"dns_resolver,omitempty"`
+	DNSExpected         []string           `json:"dns_expected,omitempty"`
+	CertAlertDays       []int64            `json:"cert_alert_days,omitempty"` // Defaults to 30/14/7; [] disables
+	Assertions          []models.Assertion `json:"assertions,omitempty"`
+	HTTPMethod          string             `json:"http_method,omitempty"` // GET (default), HEAD, POST, PUT, PATCH, DELETE or OPTIONS
+	HTTPHeaders         map[string]string  `json:"http_headers,omitempty"`
+	HTTPBody            string             `json:"http_body,omitempty"`
+	AuthType            string             `json:"auth_type,omitempty"` // basic or bearer
+	AuthUsername        string             `json:"auth_username,omitempty"`
+	AuthSecret          string             `json:"auth_secret,omitempty"`    // Password or token; write-only
+	SecretHeaders       map[string]string  `json:"secret_headers,omitempty"` // Write-only; only names are returned
+	TimeoutSeconds      int                `json:"timeout_seconds,omitempty"`
+	MaxRedirects        *int               `json:"max_redirects,omitempty"`         // 0 doesn't follow redirects
+	Steps               []models.CheckStep `json:"steps,omitempty"`                 // multistep only; the first step's URL becomes the target
+	SecretVariables     map[string]string  `json:"secret_variables,omitempty"`      // Write-only; usable as {{name}} in steps
+	GraceSeconds        int                `json:"grace_seconds,omitempty"`         // heartbeat only; how late a ping may be
+	FailureThreshold    int                `json:"failure_threshold,omitempty"`     // Failures before DOWN (default 1)
+	FailureWindow       int                `json:"failure_window,omitempty"`        // Count failures in the last N runs instead of consecutively
+	Retries             int                `json:"retries,omitempty"`               // Immediate re-probes on failure
+	DegradedThresholdMs int64              `json:"degraded_threshold_ms,omitempty"` // Response time above which the check is DEGRADED; 0 disables
+	DegradedMode        string             `json:"degraded_mode,omitempty"`         // consecutive (default) or p95
+	DegradedRuns        int                `json:"degraded_runs,omitempty"`         // Runs the threshold is applied over (default 1)
+	DependsOn           []int64            `json:"depends_on,omitempty"`            // Check IDs whose DOWN state blocks this check's alerts
+	Regions             []string           `json:"regions,omitempty"`               // Run from agents in these regions instead of the server
+	RegionQuorum        int                `json:"region_quorum,omitempty"`         // Regions that must fail for DOWN; 0 means a majority
+	EscalationPolicyID  *uint              `json:"escalation_policy_id,omitempty"`  // Notify incidents through this policy instead of the org's settings
+	IntervalSeconds     int                `json:"interval_seconds"`
+	ServiceName         string             `json:"service_name,omitempty"`
+	Environment         string             `json:"environment,omitempty"`
+	Region              string             `json:"region,omitempty"`
+	Tags                models.JSONMap     `json:"tags,omitempty"`
 }
 
 type UpdateCheckRequest struct {
-	Name            *string         `json:"name,omitempty"`
-	URL             *string         `json:"url,omitempty"`
-	IntervalSeconds *int            `json:"interval_seconds,omitempty"`
-	IsActive        *bool           `json:"is_active,omitempty"`
-	ServiceName     *string         `json:"service_name,omitempty"`
-	Environment     *string         `json:"environment,omitempty"`
-	Region          *string         `json:"region,omitempty"`
-	Tags            *models.JSONMap `json:"tags,omitempty"`
+	Name                *string             `json:"name,omitempty"`
+	Type                *string             `json:"type,omitempty"`
+	URL                 *string             `json:"url,omitempty"`
+	TCPSend             *string             `json:"tcp_send,omitempty"`
+	TCPExpect           *string             `json:"tcp_expect,omitempty"`
+	DNSRecordType       *string             `json:"dns_record_type,omitempty"`
+	DNSResolver         *string             `json:"dns_resolver,omitempty"`
+	DNSExpected         *[]string           `json:"dns_expected,omitempty"`
+	CertAlertDays       *[]int64            `json:"cert_alert_days,omitempty"`
+	Assertions          *[]models.Assertion `json:"assertions,omitempty"`
+	HTTPMethod          *string             `json:"http_method,omitempty"`
+	HTTPHeaders         *map[string]string  `json:"http_headers,omitempty"`
+	HTTPBody            *string             `json:"http_body,omitempty"`
+	AuthType            *string             `json:"auth_type,omitempty"` // "" removes auth and its secret
+	AuthUsername        *string             `json:"auth_username,omitempty"`
+	AuthSecret          *string             `json:"auth_secret,omitempty"`
+	SecretHeaders       *map[string]string  `json:"secret_headers,omitempty"` // Replaces all secret headers
+	TimeoutSeconds      *int                `json:"timeout_seconds,omitempty"`
+	MaxRedirects        *int                `json:"max_redirects,omitempty"`
+	Steps               *[]models.CheckStep `json:"steps,omitempty"`
+	SecretVariables     *map[string]string  `json:"secret_variables,omitempty"` // Replaces all secret variables
+	GraceSeconds        *int                `json:"grace_seconds,omitempty"`
+	FailureThreshold    *int                `json:"failure_threshold,omitempty"`
+	FailureWindow       *int                `json:"failure_window,omitempty"`
+	Retries             *int                `json:"retries,omitempty"`
+	DegradedThresholdMs *int64              `json:"degraded_threshold_ms,omitempty"`
+	DegradedMode        *string             `json:"degraded_mode,omitempty"`
+	DegradedRuns        *int                `json:"degraded_runs,omitempty"`
+	DependsOn           *[]int64            `json:"depends_on,omitempty"` // Replaces all dependencies
+	Regions             *[]string           `json:"regions,omitempty"`    // [] runs the check on the server again
+	RegionQuorum        *int                `json:"region_quorum,omitempty"`
+	EscalationPolicyID  *uint               `json:"escalation_policy_id,omitempty"` // 0 removes the policy
+	IntervalSeconds     *int                `json:"interval_seconds,omitempty"`
+	IsActive            *bool               `json:"is_active,omitempty"`
+	ServiceName         *string             `json:"service_name,omitempty"`
+	Environment         *string             `json:"environment,omitempty"`
+	Region              *string             `json:"region,omitempty"`
+	Tags                *models.JSONMap     `json:"tags,omitempty"`
 }
 
 // ListChecks returns all checks for the current organization
@@ -72,17 +143,112 @@ func CreateCheck(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		if req.URL == "" {
+		checkType, err := parseCheckType(req.Type)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if checkType == models.CheckTypeMultiStep && len(req.Steps) > 0 {
+			// The first step's URL stands in as the target
+			req.URL = strings.TrimSpace(req.Steps[0].URL)
+		}
+
+		if req.URL == "" && checkType != models.CheckTypeHeartbeat {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "url is required",
 			})
 		}
 
-		// Validate URL format
-		parsedURL, err := url.ParseRequestURI(req.URL)
-		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		// Validate the target for the check type
+		target, err := validateCheckTarget(checkType, req.URL)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		check := models.Check{
+			OrgID:               orgID,
+			Name:                req.Name,
+			Type:                checkType,
+			URL:                 target,
+			TCPSend:             req.TCPSend,
+			TCPExpect:           req.TCPExpect,
+			DNSRecordType:       req.DNSRecordType,
+			DNSResolver:         req.DNSResolver,
+			DNSExpected:         pq.StringArray(req.DNSExpected),
+			CertAlertDays:       pq.Int64Array(req.CertAlertDays),
+			Assertions:          models.CheckAssertions(req.Assertions),
+			HTTPMethod:          req.HTTPMethod,
+			HTTPHeaders:         models.StringMap(req.HTTPHeaders),
+			HTTPBody:            req.HTTPBody,
+			AuthType:            models.CheckAuthType(req.AuthType),
+			AuthUsername:        req.AuthUsername,
+			TimeoutSeconds:      req.TimeoutSeconds,
+			MaxRedirects:        req.MaxRedirects,
+			Steps:               models.CheckSteps(req.Steps),
+			GraceSeconds:        req.GraceSeconds,
+			FailureThreshold:    req.FailureThreshold,
+			FailureWindow:       req.FailureWindow,
+			Retries:             req.Retries,
+			DegradedThresholdMs: req.DegradedThresholdMs,
+			DegradedMode:        models.DegradedMode(req.DegradedMode),
+			DegradedRuns:        req.DegradedRuns,
+			DependsOn:           pq.Int64Array(req.DependsOn),
+			Regions:             pq.StringArray(req.Regions),
+			RegionQuorum:        req.RegionQuorum,
+			EscalationPolicyID:  req.EscalationPolicyID,
+			IntervalSeconds:     req.IntervalSeconds,
+			IsActive:            true,
+			ServiceName:         strings.TrimSpace(req.ServiceName),
+			Environment:         strings.TrimSpace(req.Environment),
+			Region:              strings.TrimSpace(req.Region),
+			Tags:                req.Tags,
+		}
+		if err := validateHeaders(req.SecretHeaders, "secret_headers"); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := setAuthSecret(&check, req.AuthSecret); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to encrypt credentials",
+			})
+		}
+		if err := setSecretHeaders(&check, req.SecretHeaders); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to encrypt credentials",
+			})
+		}
+		if err := validateSecretVariables(req.SecretVariables); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := setSecretVariables(&check, req.SecretVariables); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to encrypt credentials",
+			})
+		}
+		if err := validateCheckOptions(&check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := validateDependencies(db, &check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := validateRegions(&check); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid URL format (must be http or https)",
+				"error": err.Error(),
+			})
+		}
+		if err := validateEscalationPolicyID(db, &check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
 			})
 		}
 
@@ -116,18 +282,6 @@ func CreateCheck(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusForbidden).JSON(billing.EntitlementError(msg, "check_interval"))
 		}
 
-		check := models.Check{
-			OrgID:           orgID,
-			Name:            req.Name,
-			URL:             req.URL,
-			IntervalSeconds: req.IntervalSeconds,
-			IsActive:        true,
-			ServiceName:     strings.TrimSpace(req.ServiceName),
-			Environment:     strings.TrimSpace(req.Environment),
-			Region:          strings.TrimSpace(req.Region),
-			Tags:            req.Tags,
-		}
-
 		if err := db.Create(&check).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "failed to create check",
@@ -199,7 +353,8 @@ func UpdateCheck(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// Apply updates
+		// Apply updates. resets lists the runtime columns an edit clears.
+		var resets []string
 		if req.Name != nil {
 			name := strings.TrimSpace(*req.Name)
 			if name == "" {
@@ -210,15 +365,176 @@ func UpdateCheck(db *gorm.DB) fiber.Handler {
 			check.Name = name
 		}
 
+		if req.Type != nil {
+			checkType, err := parseCheckType(*req.Type)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			if checkType != check.Type {
+				// Options of the old type don't carry over
+				clearCheckOptions(&check)
+				resets = append(resets, "last_ping_at", "heartbeat_started_at")
+				check.Type = checkType
+				if checkType == models.CheckTypeHeartbeat {
+					check.URL = ""
+					check.FailureThreshold, check.FailureWindow, check.Retries = 1, 0, 0
+					check.DegradedThresholdMs, check.DegradedMode, check.DegradedRuns = 0, "", 1
+					check.Regions, check.RegionQuorum = nil, 0
+				}
+			}
+		}
 		if req.URL != nil {
-			urlStr := strings.TrimSpace(*req.URL)
-			parsedURL, err := url.ParseRequestURI(urlStr)
-			if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			check.URL = *req.URL
+		}
+		if req.TCPSend != nil {
+			check.TCPSend = *req.TCPSend
+		}
+		if req.TCPExpect != nil {
+			check.TCPExpect = *req.TCPExpect
+		}
+		if req.DNSRecordType != nil {
+			check.DNSRecordType = *req.DNSRecordType
+		}
+		if req.DNSResolver != nil {
+			check.DNSResolver = *req.DNSResolver
+		}
+		if req.DNSExpected != nil {
+			check.DNSExpected = pq.StringArray(*req.DNSExpected)
+		}
+		if req.CertAlertDays != nil {
+			check.CertAlertDays = pq.Int64Array(*req.CertAlertDays)
+		}
+		if req.Assertions != nil {
+			check.Assertions = models.CheckAssertions(*req.Assertions)
+		}
+		if req.HTTPMethod != nil {
+			check.HTTPMethod = *req.HTTPMethod
+		}
+		if req.HTTPHeaders != nil {
+			check.HTTPHeaders = models.StringMap(*req.HTTPHeaders)
+		}
+		if req.HTTPBody != nil {
+			check.HTTPBody = *req.HTTPBody
+		}
+		if req.AuthType != nil {
+			check.AuthType = models.CheckAuthType(*req.AuthType)
+			if check.AuthType == models.CheckAuthNone {
+				check.AuthUsername = ""
+				check.AuthSecret = ""
+			}
+		}
+		if req.AuthUsername != nil {
+			check.AuthUsername = *req.AuthUsername
+		}
+		if req.AuthSecret != nil {
+			if err := setAuthSecret(&check, *req.AuthSecret); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "failed to encrypt credentials",
+				})
+			}
+		}
+		if req.SecretHeaders != nil {
+			if err := validateHeaders(*req.SecretHeaders, "secret_headers"); err != nil {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": "invalid URL format",
+					"error": err.Error(),
+				})
+			}
+			if err := setSecretHeaders(&check, *req.SecretHeaders); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "failed to encrypt credentials",
 				})
 			}
-			check.URL = urlStr
+		}
+		if req.TimeoutSeconds != nil {
+			check.TimeoutSeconds = *req.TimeoutSeconds
+		}
+		if req.MaxRedirects != nil {
+			check.MaxRedirects = req.MaxRedirects
+		}
+		if req.Steps != nil {
+			check.Steps = models.CheckSteps(*req.Steps)
+		}
+		if req.GraceSeconds != nil {
+			check.GraceSeconds = *req.GraceSeconds
+		}
+		if req.FailureThreshold != nil {
+			check.FailureThreshold = *req.FailureThreshold
+		}
+		if req.FailureWindow != nil {
+			check.FailureWindow = *req.FailureWindow
+		}
+		if req.Retries != nil {
+			check.Retries = *req.Retries
+		}
+		if req.DegradedThresholdMs != nil {
+			check.DegradedThresholdMs = *req.DegradedThresholdMs
+		}
+		if req.DegradedMode != nil {
+			check.DegradedMode = models.DegradedMode(*req.DegradedMode)
+		}
+		if req.DegradedRuns != nil {
+			check.DegradedRuns = *req.DegradedRuns
+		}
+		if req.DependsOn != nil {
+			check.DependsOn = pq.Int64Array(*req.DependsOn)
+		}
+		if req.Regions != nil {
+			check.Regions = pq.StringArray(*req.Regions)
+		}
+		if req.RegionQuorum != nil {
+			check.RegionQuorum = *req.RegionQuorum
+		}
+		if req.EscalationPolicyID != nil {
+			check.EscalationPolicyID = req.EscalationPolicyID
+		}
+		if req.SecretVariables != nil {
+			if err := validateSecretVariables(*req.SecretVariables); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			if err := setSecretVariables(&check, *req.SecretVariables); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "failed to encrypt credentials",
+				})
+			}
+		}
+		if check.Type == models.CheckTypeMultiStep && len(check.Steps) > 0 {
+			// The first step's URL stands in as the target
+			check.URL = check.Steps[0].URL
+		}
+		// Revalidate the target whenever it or the type changes, since a
+		// valid URL is not a valid host:port or hostname
+		if req.Type != nil || req.URL != nil || req.Steps != nil {
+			target, err := validateCheckTarget(check.Type, check.URL)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			check.URL = target
+		}
+		if err := validateCheckOptions(&check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := validateDependencies(db, &check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := validateRegions(&check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := validateEscalationPolicyID(db, &check); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
 		}
 
 		if req.IntervalSeconds != nil {
@@ -233,6 +549,10 @@ func UpdateCheck(db *gorm.DB) fiber.Handler {
 			if allowed, msg := billing.CanUseCheckInterval(plan, *req.IntervalSeconds); !allowed {
 				return c.Status(fiber.StatusForbidden).JSON(billing.EntitlementError(msg, "check_interval"))
 			}
+			if *req.IntervalSeconds != check.IntervalSeconds {
+				check.NextRunAt = nil // Run now instead of on the old interval
+				resets = append(resets, "next_run_at")
+			}
 			check.IntervalSeconds = *req.IntervalSeconds
 		}
 
@@ -256,11 +576,22 @@ func UpdateCheck(db *gorm.DB) fiber.Handler {
 			check.Tags = *req.Tags
 		}
 
-		if err := db.Save(&check).Error; err != nil {
+		// Runtime columns keep whatever the workers wrote while this request
+		// ran, except those the edit deliberately resets
+		omit := make([]string, 0, len(models.CheckRuntimeColumns))
+		for _, col := range models.CheckRuntimeColumns {
+			if !slices.Contains(resets, col) {
+				omit = append(omit, col)
+			}
+		}
+		if err := db.Omit(omit...).Save(&check).Error; err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "failed to update check",
 			})
 		}
+		// Respond with the current runtime state rather than the stale copy;
+		// on failure the saved configuration is still right
+		db.First(&check, check.ID)
 
 		return c.JSON(check)
 	}
@@ -291,6 +622,13 @@ func DeleteCheck(db *gorm.DB) fiber.Handler {
 			})
 		}
 
+		// Checks depending on it no longer do
+		if err := db.Model(&models.Check{}).
+			Where("org_id = ? AND ? = ANY(depends_on)", orgID, checkID).
+			Update("depends_on", gorm.Expr("array_remove(depends_on, ?)", checkID)).Error; err != nil {
+			log.Printf("Error removing check %d from dependents: %v", checkID, err)
+		}
+
 		// Sync usage counts after deleting
 		billing.SyncResourceCounts(db, orgID)
 
@@ -360,17 +698,21 @@ func GetCheckResults(db *gorm.DB) fiber.Handler {
 }
 
 // CheckSummaryResponse represents aggregated statistics for a check
+
 type CheckSummaryResponse struct {
-    CheckID          uint       `json:"check_id"`
-    WindowHours      int        `json:"window_hours"`
-    TotalRuns        int        `json:"total_runs"`
-    SuccessfulRuns   int        `json:"successful_runs"`
-    FailedRuns       int        `json:"failed_runs"`
-    UptimePercentage float64    `json:"uptime_percentage"`
-    AvgResponseMs    int        `json:"avg_response_ms"`
-    P95ResponseMs    int        `json:"p95_response_ms"`
-    LastStatus       *int       `json:"last_status"`
-    LastCheckedAt    *time.Time `json:"last_checked_at"`
+    CheckID            uint                        `json:"check_id"`
+    WindowHours        int                         `json:"window_hours"`
+    TotalRuns          int                         `json:"total_runs"`
+    SuccessfulRuns     int                         `json:"successful_runs"`
+    FailedRuns         int                         `json:"failed_runs"`
+    MaintenanceRuns    int                         `json:"maintenance_runs"` // Excluded from uptime
+    UptimePercentage   float64                     `json:"uptime_percentage"`
+    AvgResponseMs      int                         `json:"avg_response_ms"`
+    P95ResponseMs      int                         `json:"p95_response_ms"`
+    LastStatus         *int                        `json:"last_status"`
+    State              models.CheckState           `json:"state"`
+    LastCheckedAt      *time.Time                  `json:"last_checked_at"`
+    TimeInStateSeconds map[models.CheckState]int64 `json:"time_in_state_seconds"`
 }
 
 // GetCheckSummary returns aggregated statistics for a check within a time window
@@ -420,6 +762,7 @@ func GetCheckSummary(db *gorm.DB) fiber.Handler {
             CheckID:       check.ID,
             WindowHours:   windowHours,
             LastStatus:    check.LastStatus,
+            State:         check.State,
             LastCheckedAt: check.LastCheckedAt,
         }
         totalRuns := len(results)
@@ -429,23 +772,63 @@ func GetCheckSummary(db *gorm.DB) fiber.Handler {
             return c.JSON(summary)
         }
         // Compute statistics
-        var successfulRuns int
+        var successfulRuns, maintenanceRuns int
         var totalResponseMs int64
         for _, r := range results {
-            if r.StatusCode >= 200 && r.StatusCode <= 299 {
+            totalResponseMs += r.ResponseTimeMs
+            // Planned downtime doesn't count against uptime
+            if r.InMaintenance {
+                maintenanceRuns++
+                continue
+            }
+            // Success rather than the status code: a 2xx can still fail assertions
+            if r.Success {
                 successfulRuns++
             }
-            totalResponseMs += r.ResponseTimeMs
         }
         summary.SuccessfulRuns = successfulRuns
-        summary.FailedRuns = totalRuns - successfulRuns
-        summary.UptimePercentage = float64(successfulRuns) / float64(totalRuns) * 100
+        summary.MaintenanceRuns = maintenanceRuns
+        summary.FailedRuns = totalRuns - maintenanceRuns - successfulRuns
+        if countedRuns := totalRuns - maintenanceRuns; countedRuns > 0 {
+            summary.UptimePercentage = float64(successfulRuns) / float64(countedRuns) * 100
+        }
         summary.AvgResponseMs = int(totalResponseMs / int64(totalRuns))
         summary.P95ResponseMs = int(results[p95Index(totalRuns)].ResponseTimeMs)
+        summary.TimeInStateSeconds = timeInState(results, time.Now())
         return c.JSON(summary)
     }
 }
 
+// timeInState totals how long the check spent in each state: every result's
+// state holds until the next result, the last one until now. Results stored
+// before states were recorded fall back to UP/DOWN from Success.
+func timeInState(results []models.CheckResult, now time.Time) map[models.CheckState]int64 {
+    byTime := make([]models.CheckResult, len(results))
+    copy(byTime, results)
+    sort.Slice(byTime, func(i, j int) bool { return byTime[i].CreatedAt.Before(byTime[j].CreatedAt) })
+
+    totals := map[models.CheckState]int64{
+        models.CheckStateUp:       0,
+        models.CheckStateDegraded: 0,
+        models.CheckStateDown:     0,
+    }
+    for i, r := range byTime {
+        state := r.State
+        if state == "" {
+            state = models.CheckStateDown
+            if r.Success {
+                state = models.CheckStateUp
+            }
+        }
+        end := now
+        if i+1 < len(byTime) {
+            end = byTime[i+1].CreatedAt
+        }
+        totals[state] += int64(end.Sub(r.CreatedAt).Seconds())
+    }
+    return totals
+}
+
 // p95Index returns the index for the 95th percentile in a sorted slice
 func p95Index(length int) int {
     idx := int(float64(length) * 0.95)
@@ -456,12 +839,18 @@ func p95Index(length int) int {
 }
 
 // CheckResultSearchDTO is the response DTO for check result search
+
 type CheckResultSearchDTO struct {
-    ID             uint      `json:"id"`
-    StatusCode     int       `json:"status_code"`
-    ResponseTimeMs int64     `json:"response_time_ms"`
-    ErrorMessage   string    `json:"error_message,omitempty"`
-    CreatedAt      time.Time `json:"created_at"`
+    ID             uint              `json:"id"`
+    StatusCode     int               `json:"status_code"`
+    ResponseTimeMs int64             `json:"response_time_ms"`
+    Success        bool              `json:"success"`
+    State          models.CheckState `json:"state,omitempty"`
+    ErrorMessage   string            `json:"error_message,omitempty"`
+    FailedStep     *int              `json:"failed_step,omitempty"`
+    InMaintenance  bool              `json:"in_maintenance,omitempty"`
+    BlockedBy      *uint             `json:"blocked_by,omitempty"`
+    CreatedAt      time.Time         `json:"created_at"`
 }
 
 // SearchCheckResults handles POST /api/v1/checks/:id/results/search
@@ -524,7 +913,12 @@ func SearchCheckResults(db *gorm.DB) fiber.Handler {
                 ID:             r.ID,
                 StatusCode:     r.StatusCode,
                 ResponseTimeMs: r.ResponseTimeMs,
+                Success:        r.Success,
+                State:          r.State,
                 ErrorMessage:   r.ErrorMessage,
+                FailedStep:     r.FailedStep,
+                InMaintenance:  r.InMaintenance,
+                BlockedBy:      r.BlockedBy,
                 CreatedAt:      r.CreatedAt,
             }
         }
@@ -536,3 +930,668 @@ func SearchCheckResults(db *gorm.DB) fiber.Handler {
         })
     }
 }
+
+// parseCheckType normalizes a requested check type, defaulting to http
+func parseCheckType(raw string) (models.CheckType, error) {
+	checkType := models.CheckType(strings.ToLower(strings.TrimSpace(raw)))
+	if checkType == "" {
+		return models.CheckTypeHTTP, nil
+	}
+	if !checkType.IsValid() {
+		return "", fmt.Errorf("invalid check type %q (must be http, tcp, dns, multistep or heartbeat)", raw)
+	}
+	return checkType, nil
+}
+
+// validateCheckTarget checks the target format for the check type and
+// returns it normalized: an http(s) URL, host:port for tcp (a tcp://
+// prefix is accepted and stripped), or a hostname for dns
+func validateCheckTarget(checkType models.CheckType, raw string) (string, error) {
+	target := strings.TrimSpace(raw)
+	if checkType == models.CheckTypeHeartbeat {
+		if target != "" {
+			return "", errors.New("heartbeat checks have no url; jobs ping the check's heartbeat URL instead")
+		}
+		return "", nil
+	}
+	if target == "" {
+		return "", errors.New("url is required")
+	}
+
+	switch checkType {
+	case models.CheckTypeTCP:
+		target = strings.TrimPrefix(target, "tcp://")
+		host, portStr, err := net.SplitHostPort(target)
+		if err != nil || host == "" {
+			return "", errors.New("invalid target (tcp checks need host:port)")
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return "", errors.New("invalid port (must be 1-65535)")
+		}
+		return net.JoinHostPort(host, portStr), nil
+	case models.CheckTypeMultiStep:
+		// May hold {{variables}}, so only the scheme can be checked here
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return "", errors.New("invalid URL format (must be http or https)")
+		}
+		return target, nil
+	case models.CheckTypeDNS:
+		target = strings.TrimSuffix(strings.ToLower(target), ".")
+		if len(target) > 253 || strings.ContainsAny(target, "/: \t@") {
+			return "", errors.New("invalid target (dns checks need a hostname)")
+		}
+		return target, nil
+	default:
+		parsedURL, err := url.ParseRequestURI(target)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return "", errors.New("invalid URL format (must be http or https)")
+		}
+		return target, nil
+	}
+}
+
+// Limits on per-type options
+const (
+	maxTCPPayloadBytes     = 1024
+	maxDNSExpected         = 20
+	maxCertAlertDays       = 10
+	maxHTTPHeaders         = 20
+	maxHeaderValueLen      = 1024
+	maxHTTPBodyBytes       = 64 * 1024
+	maxRedirectsLimit      = 20
+	maxCheckTimeoutSeconds = 60
+	maxCheckSteps          = 10
+	maxStepExtractors      = 10
+	maxSecretVariables     = 20
+	maxGraceSeconds        = 7 * 24 * 3600
+	maxFailureThreshold    = 10
+	maxFailureWindow       = 20
+	maxRetries             = 3
+	maxDegradedRuns        = 20
+	maxDegradedThresholdMs = 120000
+	maxDependencies        = 20
+	maxCheckRegions        = 10
+)
+
+// variableName is a valid extractor or secret variable name
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validHTTPMethods are the methods an http check can send
+var validHTTPMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// validDNSRecordTypes are the record types a dns check can query
+var validDNSRecordTypes = map[string]bool{
+	"A": true, "AAAA": true, "CNAME": true, "MX": true, "TXT": true, "NS": true,
+}
+
+// clearCheckOptions resets every type-specific option
+func clearCheckOptions(check *models.Check) {
+	check.TCPSend = ""
+	check.TCPExpect = ""
+	check.DNSRecordType = ""
+	check.DNSResolver = ""
+	check.DNSExpected = nil
+	check.CertAlertDays = nil
+	check.Assertions = nil
+	check.HTTPMethod = ""
+	check.HTTPHeaders = nil
+	check.HTTPBody = ""
+	check.AuthType = models.CheckAuthNone
+	check.AuthUsername = ""
+	check.AuthSecret = ""
+	check.SecretHeaders = ""
+	check.SecretHeaderNames = nil
+	check.MaxRedirects = nil
+	check.Steps = nil
+	check.SecretVariables = ""
+	check.SecretVariableNames = nil
+	check.HeartbeatToken = nil
+	check.GraceSeconds = 0
+	check.LastPingAt = nil
+	check.HeartbeatStartedAt = nil
+}
+
+// validateCheckOptions rejects options that don't belong to the check's
+// type and validates and normalizes the ones that do
+func validateCheckOptions(check *models.Check) error {
+	if check.Type != models.CheckTypeTCP && (check.TCPSend != "" || check.TCPExpect != "") {
+		return errors.New("tcp_send and tcp_expect are only valid for tcp checks")
+	}
+	if check.Type != models.CheckTypeDNS && (check.DNSRecordType != "" || check.DNSResolver != "" || len(check.DNSExpected) > 0) {
+		return errors.New("dns_record_type, dns_resolver and dns_expected are only valid for dns checks")
+	}
+
+	if check.Type != models.CheckTypeHTTP && (len(check.CertAlertDays) > 0 || len(check.Assertions) > 0) {
+		return errors.New("cert_alert_days and assertions are only valid for http checks")
+	}
+	if check.Type != models.CheckTypeHTTP && (check.HTTPMethod != "" || len(check.HTTPHeaders) > 0 || check.HTTPBody != "" || check.AuthType != models.CheckAuthNone) {
+		return errors.New("http_method, http_headers, http_body and auth are only valid for http checks")
+	}
+	isHTTPLike := check.Type == models.CheckTypeHTTP || check.Type == models.CheckTypeMultiStep
+	if !isHTTPLike && (check.SecretHeaders != "" || check.MaxRedirects != nil) {
+		return errors.New("secret_headers and max_redirects are only valid for http and multistep checks")
+	}
+	if check.Type != models.CheckTypeMultiStep && (len(check.Steps) > 0 || check.SecretVariables != "") {
+		return errors.New("steps and secret_variables are only valid for multistep checks")
+	}
+	if check.Type != models.CheckTypeHeartbeat && check.GraceSeconds != 0 {
+		return errors.New("grace_seconds is only valid for heartbeat checks")
+	}
+	if err := validateConfirmation(check); err != nil {
+		return err
+	}
+	if err := validateDegraded(check); err != nil {
+		return err
+	}
+	if check.TimeoutSeconds == 0 {
+		check.TimeoutSeconds = models.DefaultCheckTimeoutSeconds
+	}
+	if check.TimeoutSeconds < 1 || check.TimeoutSeconds > maxCheckTimeoutSeconds {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", maxCheckTimeoutSeconds)
+	}
+
+	switch check.Type {
+	case models.CheckTypeHTTP:
+		if len(check.CertAlertDays) > maxCertAlertDays {
+			return fmt.Errorf("cert_alert_days may list at most %d thresholds", maxCertAlertDays)
+		}
+		for _, days := range check.CertAlertDays {
+			if days < 1 || days > 365 {
+				return errors.New("cert_alert_days values must be between 1 and 365")
+			}
+		}
+		if err := validateHTTPRequestOptions(check); err != nil {
+			return err
+		}
+		if len(check.Assertions) > assertion.MaxAssertions {
+			return fmt.Errorf("a check may have at most %d assertions", assertion.MaxAssertions)
+		}
+		for i, a := range check.Assertions {
+			if err := assertion.Validate(a); err != nil {
+				return fmt.Errorf("assertion %d: %v", i+1, err)
+			}
+		}
+	case models.CheckTypeMultiStep:
+		if len(check.SecretHeaderNames) > maxHTTPHeaders {
+			return fmt.Errorf("a check may send at most %d secret headers", maxHTTPHeaders)
+		}
+		if check.MaxRedirects != nil && (*check.MaxRedirects < 0 || *check.MaxRedirects > maxRedirectsLimit) {
+			return fmt.Errorf("max_redirects must be between 0 and %d", maxRedirectsLimit)
+		}
+		if err := validateSteps(check); err != nil {
+			return err
+		}
+	case models.CheckTypeHeartbeat:
+		if check.GraceSeconds < 0 || check.GraceSeconds > maxGraceSeconds {
+			return fmt.Errorf("grace_seconds must be between 0 and %d", maxGraceSeconds)
+		}
+		if check.HeartbeatToken == nil {
+			token, err := models.GenerateHeartbeatToken()
+			if err != nil {
+				return errors.New("failed to generate heartbeat token")
+			}
+			check.HeartbeatToken = &token
+		}
+	case models.CheckTypeTCP:
+		if len(check.TCPSend) > maxTCPPayloadBytes || len(check.TCPExpect) > maxTCPPayloadBytes {
+			return fmt.Errorf("tcp_send and tcp_expect must be at most %d bytes", maxTCPPayloadBytes)
+		}
+	case models.CheckTypeDNS:
+		recordType := strings.ToUpper(strings.TrimSpace(check.DNSRecordType))
+		if recordType == "" {
+			recordType = "A"
+		}
+		if !validDNSRecordTypes[recordType] {
+			return fmt.Errorf("invalid dns_record_type %q (must be A, AAAA, CNAME, MX, TXT or NS)", check.DNSRecordType)
+		}
+		check.DNSRecordType = recordType
+
+		if resolver := strings.TrimSpace(check.DNSResolver); resolver != "" {
+			// Bare hosts and IPs (including unbracketed IPv6) get port 53
+			if _, _, err := net.SplitHostPort(resolver); err != nil {
+				resolver = net.JoinHostPort(strings.Trim(resolver, "[]"), "53")
+			}
+			host, portStr, err := net.SplitHostPort(resolver)
+			port, portErr := strconv.Atoi(portStr)
+			if err != nil || host == "" || portErr != nil || port < 1 || port > 65535 {
+				return errors.New("invalid dns_resolver (must be host or host:port)")
+			}
+			check.DNSResolver = resolver
+		} else {
+			check.DNSResolver = ""
+		}
+
+		if len(check.DNSExpected) > maxDNSExpected {
+			return fmt.Errorf("dns_expected may list at most %d values", maxDNSExpected)
+		}
+		expected := make(pq.StringArray, 0, len(check.DNSExpected))
+		for _, v := range check.DNSExpected {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			if len(v) > 255 {
+				return errors.New("dns_expected values must be at most 255 characters")
+			}
+			switch recordType {
+			case "A", "AAAA":
+				ip := net.ParseIP(v)
+				isV4 := ip != nil && ip.To4() != nil
+				if ip == nil || isV4 != (recordType == "A") {
+					return fmt.Errorf("dns_expected value %q is not a valid %s record address", v, recordType)
+				}
+				v = ip.String()
+			case "TXT":
+				// TXT values are compared verbatim
+			default:
+				v = strings.TrimSuffix(strings.ToLower(v), ".")
+			}
+			expected = append(expected, v)
+		}
+		check.DNSExpected = expected
+	}
+	return nil
+}
+
+// validateConfirmation validates the rule for confirming a failure before
+// the check goes DOWN
+func validateConfirmation(check *models.Check) error {
+	if check.FailureThreshold == 0 {
+		check.FailureThreshold = 1
+	}
+	if check.FailureThreshold < 1 || check.FailureThreshold > maxFailureThreshold {
+		return fmt.Errorf("failure_threshold must be between 1 and %d", maxFailureThreshold)
+	}
+	if check.FailureWindow != 0 && (check.FailureWindow < check.FailureThreshold || check.FailureWindow > maxFailureWindow) {
+		return fmt.Errorf("failure_window must be 0 or between failure_threshold and %d", maxFailureWindow)
+	}
+	if check.Retries < 0 || check.Retries > maxRetries {
+		return fmt.Errorf("retries must be between 0 and %d", maxRetries)
+	}
+	// Heartbeats are pushed to: there is nothing to retry, and grace_seconds
+	// already tolerates a late ping
+	if check.Type == models.CheckTypeHeartbeat && (check.FailureThreshold != 1 || check.FailureWindow != 0 || check.Retries != 0) {
+		return errors.New("failure_threshold, failure_window and retries are not valid for heartbeat checks")
+	}
+	return nil
+}
+
+// validateDegraded validates the latency threshold that turns a healthy
+// check DEGRADED
+func validateDegraded(check *models.Check) error {
+	check.DegradedMode = models.DegradedMode(strings.ToLower(strings.TrimSpace(string(check.DegradedMode))))
+	if check.DegradedMode == "" {
+		check.DegradedMode = models.DegradedConsecutive
+	}
+	if check.DegradedRuns == 0 {
+		check.DegradedRuns = 1
+	}
+	if check.DegradedMode != models.DegradedConsecutive && check.DegradedMode != models.DegradedP95 {
+		return fmt.Errorf("invalid degraded_mode %q (must be consecutive or p95)", check.DegradedMode)
+	}
+	if check.DegradedThresholdMs < 0 || check.DegradedThresholdMs > maxDegradedThresholdMs {
+		return fmt.Errorf("degraded_threshold_ms must be between 0 and %d", maxDegradedThresholdMs)
+	}
+	if check.DegradedRuns < 1 || check.DegradedRuns > maxDegradedRuns {
+		return fmt.Errorf("degraded_runs must be between 1 and %d", maxDegradedRuns)
+	}
+	// A ping has no response time to measure
+	if check.Type == models.CheckTypeHeartbeat && check.DegradedThresholdMs != 0 {
+		return errors.New("degraded_threshold_ms is not valid for heartbeat checks")
+	}
+	return nil
+}
+
+// validateDependencies checks that every dependency is another check in the
+// org and that the new edges don't close a cycle
+func validateDependencies(db *gorm.DB, check *models.Check) error {
+	if len(check.DependsOn) == 0 {
+		check.DependsOn = nil
+		return nil
+	}
+	check.DependsOn = pq.Int64Array(uniqueIDs(check.DependsOn))
+	if len(check.DependsOn) > maxDependencies {
+		return fmt.Errorf("depends_on may list at most %d checks", maxDependencies)
+	}
+
+	var others []models.Check
+	if err := db.Select("id", "depends_on").Where("org_id = ?", check.OrgID).Find(&others).Error; err != nil {
+		return errors.New("failed to verify depends_on")
+	}
+	graph := make(map[int64][]int64, len(others)+1)
+	for _, other := range others {
+		graph[int64(other.ID)] = other.DependsOn
+	}
+	self := int64(check.ID)
+	for _, id := range check.DependsOn {
+		if _, ok := graph[id]; !ok || id == self {
+			return fmt.Errorf("depends_on contains an unknown check %d", id)
+		}
+	}
+	if check.ID == 0 {
+		return nil // Nothing can depend on a check that doesn't exist yet
+	}
+
+	// A cycle exists if any new parent already reaches this check
+	graph[self] = check.DependsOn
+	parent := make(map[int64]int64)
+	visited := map[int64]bool{self: true}
+	queue := append([]int64(nil), check.DependsOn...)
+	for _, id := range check.DependsOn {
+		parent[id] = self
+		visited[id] = true
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range graph[id] {
+			if next == self {
+				// Walk back to this check, then read the cycle forwards
+				path := []string{strconv.FormatInt(self, 10)}
+				for at := id; at != self; at = parent[at] {
+					path = append([]string{strconv.FormatInt(at, 10)}, path...)
+				}
+				path = append([]string{strconv.FormatInt(self, 10)}, path...)
+				return fmt.Errorf("depends_on would create a dependency cycle (%s)", strings.Join(path, " -> "))
+			}
+			if !visited[next] {
+				visited[next] = true
+				parent[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRegions normalizes the regions a check runs from and its quorum.
+// Without regions the check runs on the server as before.
+func validateRegions(check *models.Check) error {
+	if len(check.Regions) == 0 {
+		check.Regions = nil
+		check.RegionQuorum = 0
+		return nil
+	}
+	if check.Type == models.CheckTypeHeartbeat {
+		return errors.New("regions are not valid for heartbeat checks")
+	}
+	if check.FailureWindow != 0 {
+		return errors.New("failure_window is not valid with regions; use region_quorum")
+	}
+	seen := make(map[string]bool, len(check.Regions))
+	var regions []string
+	for _, r := range check.Regions {
+		r = strings.ToLower(strings.TrimSpace(r))
+		if !regionName.MatchString(r) {
+			return fmt.Errorf("invalid region %q: use lowercase letters, digits and dashes", r)
+		}
+		if !seen[r] {
+			seen[r] = true
+			regions = append(regions, r)
+		}
+	}
+	if len(regions) > maxCheckRegions {
+		return fmt.Errorf("regions may list at most %d regions", maxCheckRegions)
+	}
+	check.Regions = pq.StringArray(regions)
+	if check.RegionQuorum < 0 || check.RegionQuorum > len(regions) {
+		return errors.New("region_quorum must be between 0 (majority) and the number of regions")
+	}
+	return nil
+}
+
+// validateHTTPRequestOptions normalizes and validates an http check's
+// method, headers, body, auth and redirect limit
+func validateHTTPRequestOptions(check *models.Check) error {
+	check.HTTPMethod = strings.ToUpper(strings.TrimSpace(check.HTTPMethod))
+	if check.HTTPMethod == "" {
+		check.HTTPMethod = "GET"
+	}
+	if !validHTTPMethods[check.HTTPMethod] {
+		return fmt.Errorf("invalid http_method %q", check.HTTPMethod)
+	}
+	if check.HTTPBody != "" && (check.HTTPMethod == "GET" || check.HTTPMethod == "HEAD") {
+		return errors.New("http_body needs a method other than GET or HEAD")
+	}
+	if len(check.HTTPBody) > maxHTTPBodyBytes {
+		return fmt.Errorf("http_body is limited to %d bytes", maxHTTPBodyBytes)
+	}
+
+	if err := validateHeaders(check.HTTPHeaders, "http_headers"); err != nil {
+		return err
+	}
+	for name := range check.HTTPHeaders {
+		if strings.EqualFold(name, "Authorization") {
+			return errors.New("set Authorization through auth_type or secret_headers so it is stored encrypted")
+		}
+	}
+	if len(check.HTTPHeaders)+len(check.SecretHeaderNames) > maxHTTPHeaders {
+		return fmt.Errorf("a check may send at most %d headers", maxHTTPHeaders)
+	}
+
+	check.AuthType = models.CheckAuthType(strings.ToLower(strings.TrimSpace(string(check.AuthType))))
+	check.AuthUsername = strings.TrimSpace(check.AuthUsername)
+	switch check.AuthType {
+	case models.CheckAuthNone:
+		if check.AuthUsername != "" || check.AuthSecret != "" {
+			return errors.New("auth_username and auth_secret need an auth_type")
+		}
+	case models.CheckAuthBasic:
+		if check.AuthUsername == "" || check.AuthSecret == "" {
+			return errors.New("basic auth needs auth_username and auth_secret")
+		}
+	case models.CheckAuthBearer:
+		if check.AuthSecret == "" {
+			return errors.New("bearer auth needs auth_secret")
+		}
+		if check.AuthUsername != "" {
+			return errors.New("bearer auth doesn't take auth_username")
+		}
+	default:
+		return fmt.Errorf("invalid auth_type %q (must be basic or bearer)", check.AuthType)
+	}
+	if check.AuthType != models.CheckAuthNone {
+		for _, name := range check.SecretHeaderNames {
+			if strings.EqualFold(name, "Authorization") {
+				return errors.New("auth_type and an Authorization secret header can't be combined")
+			}
+		}
+	}
+
+	if check.MaxRedirects != nil && (*check.MaxRedirects < 0 || *check.MaxRedirects > maxRedirectsLimit) {
+		return fmt.Errorf("max_redirects must be between 0 and %d", maxRedirectsLimit)
+	}
+	return nil
+}
+
+// validateHeaders checks header names and values are safe to send as-is
+func validateHeaders(headers map[string]string, field string) error {
+	if len(headers) > maxHTTPHeaders {
+		return fmt.Errorf("%s may have at most %d entries", field, maxHTTPHeaders)
+	}
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("%s has an invalid header name %q", field, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s value for %s contains a line break", field, name)
+		}
+		if len(value) > maxHeaderValueLen {
+			return fmt.Errorf("%s value for %s is limited to %d characters", field, name, maxHeaderValueLen)
+		}
+	}
+	return nil
+}
+
+// setAuthSecret stores the auth password or token encrypted
+func setAuthSecret(check *models.Check, secret string) error {
+	sealed, err := secrets.Encrypt(secret)
+	if err != nil {
+		return err
+	}
+	check.AuthSecret = sealed
+	return nil
+}
+
+// setSecretHeaders stores header values encrypted, keeping only their
+// names readable
+func setSecretHeaders(check *models.Check, headers map[string]string) error {
+	sealed, names, err := sealStringMap(headers)
+	if err != nil {
+		return err
+	}
+	check.SecretHeaders = sealed
+	check.SecretHeaderNames = names
+	return nil
+}
+
+// validateSteps normalizes and validates a multistep check's steps. Every
+// {{variable}} a step uses must be a secret variable or extracted by an
+// earlier step.
+func validateSteps(check *models.Check) error {
+	if len(check.Steps) == 0 {
+		return errors.New("multistep checks need at least one step")
+	}
+	if len(check.Steps) > maxCheckSteps {
+		return fmt.Errorf("a multistep check may have at most %d steps", maxCheckSteps)
+	}
+
+	defined := make(map[string]bool)
+	for _, name := range check.SecretVariableNames {
+		defined[name] = true
+	}
+	for i := range check.Steps {
+		step := &check.Steps[i]
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("step %d: %s", i+1, fmt.Sprintf(format, args...))
+		}
+
+		step.Name = strings.TrimSpace(step.Name)
+		if len(step.Name) > 100 {
+			return fail("name is limited to 100 characters")
+		}
+		step.Method = strings.ToUpper(strings.TrimSpace(step.Method))
+		if step.Method == "" {
+			step.Method = "GET"
+		}
+		if !validHTTPMethods[step.Method] {
+			return fail("invalid method %q", step.Method)
+		}
+		step.URL = strings.TrimSpace(step.URL)
+		if !strings.HasPrefix(step.URL, "http://") && !strings.HasPrefix(step.URL, "https://") {
+			return fail("url must be http or https")
+		}
+		if len(step.URL) > 2048 {
+			return fail("url is limited to 2048 characters")
+		}
+		if step.Body != "" && (step.Method == "GET" || step.Method == "HEAD") {
+			return fail("body needs a method other than GET or HEAD")
+		}
+		if len(step.Body) > maxHTTPBodyBytes {
+			return fail("body is limited to %d bytes", maxHTTPBodyBytes)
+		}
+		if err := validateHeaders(step.Headers, "headers"); err != nil {
+			return fail("%v", err)
+		}
+
+		templated := []string{step.URL, step.Body}
+		for _, value := range step.Headers {
+			templated = append(templated, value)
+		}
+		for _, s := range templated {
+			for _, ref := range models.StepVariableRef.FindAllStringSubmatch(s, -1) {
+				if !defined[ref[1]] {
+					return fail("variable %q is not defined by a secret variable or an earlier step", ref[1])
+				}
+			}
+		}
+
+		if len(step.Assertions) > assertion.MaxAssertions {
+			return fail("at most %d assertions", assertion.MaxAssertions)
+		}
+		for j, a := range step.Assertions {
+			if err := assertion.Validate(a); err != nil {
+				return fail("assertion %d: %v", j+1, err)
+			}
+		}
+
+		if len(step.Extract) > maxStepExtractors {
+			return fail("at most %d extractors", maxStepExtractors)
+		}
+		for _, ex := range step.Extract {
+			if !variableName.MatchString(ex.Name) {
+				return fail("invalid extractor name %q", ex.Name)
+			}
+			switch ex.Type {
+			case models.ExtractJSONPath:
+				if err := assertion.ValidatePath(ex.Path); err != nil {
+					return fail("%v", err)
+				}
+			case models.ExtractHeader:
+				if strings.TrimSpace(ex.Path) == "" {
+					return fail("header extractor %s needs a header name as path", ex.Name)
+				}
+			case models.ExtractRegex:
+				if _, err := regexp.Compile(ex.Path); err != nil {
+					return fail("invalid regex for %s: %v", ex.Name, err)
+				}
+			default:
+				return fail("invalid extractor type %q (must be json_path, header or regex)", ex.Type)
+			}
+			defined[ex.Name] = true
+		}
+	}
+	return nil
+}
+
+// validateSecretVariables checks secret variable names and sizes
+func validateSecretVariables(vars map[string]string) error {
+	if len(vars) > maxSecretVariables {
+		return fmt.Errorf("secret_variables may have at most %d entries", maxSecretVariables)
+	}
+	for name, value := range vars {
+		if !variableName.MatchString(name) {
+			return fmt.Errorf("invalid secret variable name %q", name)
+		}
+		if len(value) > maxHeaderValueLen {
+			return fmt.Errorf("secret variable %s is limited to %d characters", name, maxHeaderValueLen)
+		}
+	}
+	return nil
+}
+
+// setSecretVariables stores multistep variables encrypted, keeping only
+// their names readable
+func setSecretVariables(check *models.Check, vars map[string]string) error {
+	sealed, names, err := sealStringMap(vars)
+	if err != nil {
+		return err
+	}
+	check.SecretVariables = sealed
+	check.SecretVariableNames = names
+	return nil
+}
+
+// sealStringMap encrypts a map as JSON and returns its sorted keys
+func sealStringMap(values map[string]string) (string, pq.StringArray, error) {
+	if len(values) == 0 {
+		return "", nil, nil
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", nil, err
+	}
+	sealed, err := secrets.Encrypt(string(encoded))
+	if err != nil {
+		return "", nil, err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return sealed, pq.StringArray(names), nil
+}
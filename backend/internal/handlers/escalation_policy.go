@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxEscalationLevels        = 10
+	maxEscalationTargets       = 10
+	maxEscalationDelayMinutes  = 24 * 60
+	minEscalationRepeatMinutes = 5
+	maxEscalationRepeatMinutes = 24 * 60
+	maxEscalationRepeats       = 100
+)
+
+type CreateEscalationPolicyRequest struct {
+	Name                  string                   `json:"name"`
+	Levels                []models.EscalationLevel `json:"levels"`
+	RepeatIntervalMinutes int                      `json:"repeat_interval_minutes,omitempty"` // 0 never repeats
+	MaxRepeats            int                      `json:"max_repeats,omitempty"`             // 0 repeats until acknowledged
+}
+
+type UpdateEscalationPolicyRequest struct {
+	Name                  *string                   `json:"name,omitempty"`
+	Levels                *[]models.EscalationLevel `json:"levels,omitempty"` // Replaces all levels
+	RepeatIntervalMinutes *int                      `json:"repeat_interval_minutes,omitempty"`
+	MaxRepeats            *int                      `json:"max_repeats,omitempty"`
+}
+
+// ListEscalationPolicies returns the org's escalation policies
+// GET /api/v1/escalation-policies
+func ListEscalationPolicies(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		var policies []models.EscalationPolicy
+		if err := db.Where("org_id = ?", orgID).Order("name ASC").Find(&policies).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch escalation policies",
+			})
+		}
+		return c.JSON(policies)
+	}
+}
+
+// GetEscalationPolicy returns a single escalation policy
+// GET /api/v1/escalation-policies/:id
+func GetEscalationPolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		policy, err := findEscalationPolicy(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(policy)
+	}
+}
+
+// CreateEscalationPolicy creates an escalation policy
+// POST /api/v1/escalation-policies
+func CreateEscalationPolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		var req CreateEscalationPolicyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		policy := models.EscalationPolicy{
+			OrgID:                 orgID,
+			Name:                  req.Name,
+			Levels:                models.EscalationLevels(req.Levels),
+			RepeatIntervalMinutes: req.RepeatIntervalMinutes,
+			MaxRepeats:            req.MaxRepeats,
+			CreatedByID:           &userID,
+		}
+		if err := validateEscalationPolicy(db, &policy); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Create(&policy).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create escalation policy",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionEscalationCreated, "escalation_policy", &policy.ID, models.JSONMap{
+			"name":   policy.Name,
+			"levels": len(policy.Levels),
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.Status(fiber.StatusCreated).JSON(policy)
+	}
+}
+
+// UpdateEscalationPolicy updates an escalation policy. Open incidents
+// continue from the level they reached under the new levels.
+// PUT /api/v1/escalation-policies/:id
+func UpdateEscalationPolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		policy, err := findEscalationPolicy(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		var req UpdateEscalationPolicyRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		if req.Name != nil {
+			policy.Name = *req.Name
+		}
+		if req.Levels != nil {
+			policy.Levels = models.EscalationLevels(*req.Levels)
+		}
+		if req.RepeatIntervalMinutes != nil {
+			policy.RepeatIntervalMinutes = *req.RepeatIntervalMinutes
+		}
+		if req.MaxRepeats != nil {
+			policy.MaxRepeats = *req.MaxRepeats
+		}
+		if err := validateEscalationPolicy(db, policy); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Save(policy).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to update escalation policy",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionEscalationUpdated, "escalation_policy", &policy.ID, models.JSONMap{
+			"name":   policy.Name,
+			"levels": len(policy.Levels),
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(policy)
+	}
+}
+
+// DeleteEscalationPolicy deletes an escalation policy. Its checks go back
+// to the org's notification settings, and its open incidents to plain
+// repeat notifications.
+// DELETE /api/v1/escalation-policies/:id
+func DeleteEscalationPolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		policy, err := findEscalationPolicy(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.Check{}).Where("escalation_policy_id = ?", policy.ID).
+				Update("escalation_policy_id", nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Incident{}).Where("escalation_policy_id = ?", policy.ID).
+				Updates(map[string]interface{}{"escalation_policy_id": nil, "next_escalation_at": nil}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(policy).Error
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to delete escalation policy",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionEscalationDeleted, "escalation_policy", &policy.ID, models.JSONMap{
+			"name": policy.Name,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(fiber.Map{
+			"message": "escalation policy deleted successfully",
+		})
+	}
+}
+
+// findEscalationPolicy loads the org's policy named by the :id param. Its
+// errors are *fiber.Error, rendered as {"error": ...} by the app's error
+// handler.
+func findEscalationPolicy(db *gorm.DB, c *fiber.Ctx, orgID uint) (*models.EscalationPolicy, error) {
+	policyID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid escalation policy ID")
+	}
+
+	var policy models.EscalationPolicy
+	if err := db.Where("id = ? AND org_id = ?", policyID, orgID).First(&policy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "escalation policy not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch escalation policy")
+	}
+	return &policy, nil
+}
+
+// validateEscalationPolicy normalizes and validates a policy's levels and
+// repeat settings. Each target keeps only the field its type uses.
+func validateEscalationPolicy(db *gorm.DB, p *models.EscalationPolicy) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(p.Levels) == 0 || len(p.Levels) > maxEscalationLevels {
+		return fmt.Errorf("levels must list between 1 and %d levels", maxEscalationLevels)
+	}
+	for i := range p.Levels {
+		level := &p.Levels[i]
+		if level.DelayMinutes < 0 || level.DelayMinutes > maxEscalationDelayMinutes {
+			return fmt.Errorf("level %d: delay_minutes must be between 0 and %d", i+1, maxEscalationDelayMinutes)
+		}
+		if len(level.Targets) == 0 || len(level.Targets) > maxEscalationTargets {
+			return fmt.Errorf("level %d: targets must list between 1 and %d targets", i+1, maxEscalationTargets)
+		}
+		for j := range level.Targets {
+			if err := validateEscalationTarget(db, p.OrgID, &level.Targets[j]); err != nil {
+				return fmt.Errorf("level %d: %w", i+1, err)
+			}
+		}
+	}
+
+	if p.RepeatIntervalMinutes != 0 &&
+		(p.RepeatIntervalMinutes < minEscalationRepeatMinutes || p.RepeatIntervalMinutes > maxEscalationRepeatMinutes) {
+		return fmt.Errorf("repeat_interval_minutes must be 0 or between %d and %d",
+			minEscalationRepeatMinutes, maxEscalationRepeatMinutes)
+	}
+	if p.MaxRepeats < 0 || p.MaxRepeats > maxEscalationRepeats {
+		return fmt.Errorf("max_repeats must be between 0 and %d", maxEscalationRepeats)
+	}
+	if p.RepeatIntervalMinutes == 0 {
+		p.MaxRepeats = 0
+	}
+	return nil
+}
+
+// validateEscalationTarget checks one target and clears fields its type
+// doesn't use
+func validateEscalationTarget(db *gorm.DB, orgID uint, t *models.EscalationTarget) error {
+	t.Type = models.EscalationTargetType(strings.ToLower(strings.TrimSpace(string(t.Type))))
+	t.Address = strings.TrimSpace(t.Address)
+	switch t.Type {
+	case models.EscalationTargetEmail:
+		t.Address = strings.ToLower(t.Address)
+		if !emailRegex.MatchString(t.Address) {
+			return fmt.Errorf("invalid email: %s", t.Address)
+		}
+		t.UserID, t.ScheduleID = nil, nil
+	case models.EscalationTargetWebhook:
+		if !strings.HasPrefix(t.Address, "http://") && !strings.HasPrefix(t.Address, "https://") {
+			return errors.New("webhook address must start with http:// or https://")
+		}
+		t.UserID, t.ScheduleID = nil, nil
+	case models.EscalationTargetUser:
+		if t.UserID == nil {
+			return errors.New("user target requires user_id")
+		}
+		var count int64
+		if err := db.Model(&models.User{}).Where("id = ? AND org_id = ?", *t.UserID, orgID).Count(&count).Error; err != nil {
+			return errors.New("failed to verify user_id")
+		}
+		if count == 0 {
+			return fmt.Errorf("user %d is not a member of the organization", *t.UserID)
+		}
+		t.Address, t.ScheduleID = "", nil
+	case models.EscalationTargetOnCall:
+		if t.ScheduleID == nil {
+			return errors.New("on_call target requires schedule_id")
+		}
+		var count int64
+		if err := db.Model(&models.OnCallSchedule{}).Where("id = ? AND org_id = ?", *t.ScheduleID, orgID).Count(&count).Error; err != nil {
+			return errors.New("failed to verify schedule_id")
+		}
+		if count == 0 {
+			return fmt.Errorf("schedule %d is not an on-call schedule of the organization", *t.ScheduleID)
+		}
+		t.Address, t.UserID = "", nil
+	case models.EscalationTargetAdmins, models.EscalationTargetDefaults:
+		t.Address, t.UserID, t.ScheduleID = "", nil, nil
+	default:
+		return errors.New("target type must be email, webhook, user, on_call, admins or defaults")
+	}
+	return nil
+}
+
+// validateEscalationPolicyID checks that a check's policy belongs to its org
+func validateEscalationPolicyID(db *gorm.DB, check *models.Check) error {
+	if check.EscalationPolicyID == nil {
+		return nil
+	}
+	if *check.EscalationPolicyID == 0 {
+		check.EscalationPolicyID = nil
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.EscalationPolicy{}).
+		Where("id = ? AND org_id = ?", *check.EscalationPolicyID, check.OrgID).
+		Count(&count).Error; err != nil {
+		return errors.New("failed to verify escalation_policy_id")
+	}
+	if count == 0 {
+		return errors.New("escalation_policy_id is not a policy of this organization")
+	}
+	return nil
+}
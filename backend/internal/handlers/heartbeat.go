@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/worker"
+	"gorm.io/gorm"
+)
+
+// HeartbeatPingKind is what a ping to a heartbeat URL reports
+type HeartbeatPingKind string
+
+const (
+	HeartbeatSuccess HeartbeatPingKind = "success" // The job finished
+	HeartbeatStart   HeartbeatPingKind = "start"   // The job started; times the run
+	HeartbeatFail    HeartbeatPingKind = "fail"    // The job failed
+)
+
+// maxPingMessageBytes is how much of a /fail request body is kept as the error
+const maxPingMessageBytes = 500
+
+// HeartbeatPing records a ping from a monitored job. The token is the only
+// credential, so the route is public. A success ping stores an UP result
+// (timed from the last start ping, if any) and a fail ping a DOWN result
+// carrying the request body as its message.
+// GET|POST /api/v1/heartbeat/:token[/start|/fail]
+func HeartbeatPing(db *gorm.DB, kind HeartbeatPingKind) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var check models.Check
+		if err := db.Where("heartbeat_token = ? AND type = ?", c.Params("token"), models.CheckTypeHeartbeat).First(&check).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "heartbeat not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch heartbeat",
+			})
+		}
+		// Paused monitors accept pings so jobs don't error, but ignore them
+		if !check.IsActive {
+			return c.JSON(fiber.Map{"status": "paused"})
+		}
+
+		now := time.Now()
+		if kind == HeartbeatStart {
+			if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Update("heartbeat_started_at", now).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "failed to record ping",
+				})
+			}
+			return c.JSON(fiber.Map{"status": "ok"})
+		}
+
+		result := models.CheckResult{
+			OrgID:       check.OrgID,
+			ServiceName: check.ServiceName,
+			Environment: check.Environment,
+			Region:      check.Region,
+			Tags:        check.Tags,
+		}
+		if check.HeartbeatStartedAt != nil {
+			result.ResponseTimeMs = now.Sub(*check.HeartbeatStartedAt).Milliseconds()
+		}
+		updates := map[string]interface{}{"heartbeat_started_at": nil}
+		if kind == HeartbeatSuccess {
+			result.StatusCode = fiber.StatusOK
+			result.Success = true
+			updates["last_ping_at"] = now
+		} else {
+			result.ErrorMessage = "job reported failure"
+			if body := strings.TrimSpace(string(c.Body())); body != "" {
+				if len(body) > maxPingMessageBytes {
+					body = body[:maxPingMessageBytes]
+				}
+				result.ErrorMessage += ": " + strings.ToValidUTF8(body, "")
+			}
+		}
+		if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to record ping",
+			})
+		}
+		if !worker.RecordResult(db, check, result) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to record ping",
+			})
+		}
+		return c.JSON(fiber.Map{"status": "ok"})
+	}
+}
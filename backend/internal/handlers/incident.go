@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+const maxIncidentCommentLength = 10000
+
+// IncidentDTO is an incident with its check's name and its MTTA/MTTR terms
+type IncidentDTO struct {
+	models.Incident
+	CheckName                string `json:"check_name,omitempty"`
+	TimeToAcknowledgeSeconds *int64 `json:"time_to_acknowledge_seconds,omitempty"`
+	TimeToResolveSeconds     *int64 `json:"time_to_resolve_seconds,omitempty"`
+}
+
+func newIncidentDTO(incident models.Incident, checkName string) IncidentDTO {
+	dto := IncidentDTO{Incident: incident, CheckName: checkName}
+	if d := incident.TimeToAcknowledge(); d != nil {
+		secs := int64(d.Seconds())
+		dto.TimeToAcknowledgeSeconds = &secs
+	}
+	if d := incident.TimeToResolve(); d != nil {
+		secs := int64(d.Seconds())
+		dto.TimeToResolveSeconds = &secs
+	}
+	return dto
+}
+
+// IncidentDetailResponse is an incident with its timeline and alerts
+type IncidentDetailResponse struct {
+	Incident IncidentDTO            `json:"incident"`
+	Timeline []models.IncidentEvent `json:"timeline"`
+	Alerts   []AlertResponse        `json:"alerts"`
+}
+
+// IncidentStatsResponse summarizes incidents triggered in a time window
+type IncidentStatsResponse struct {
+	WindowHours  int      `json:"window_hours"`
+	Total        int64    `json:"total"`
+	Triggered    int64    `json:"triggered"`
+	Acknowledged int64    `json:"acknowledged"`
+	Resolved     int64    `json:"resolved"`
+	MTTASeconds  *float64 `json:"mtta_seconds"` // Mean time to acknowledge; nil without acknowledged incidents
+	MTTRSeconds  *float64 `json:"mttr_seconds"` // Mean time to resolve; nil without resolved incidents
+}
+
+type AssignIncidentRequest struct {
+	AssigneeID *uint `json:"assignee_id"` // null unassigns
+}
+
+type IncidentNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// ListIncidents returns the org's incidents, newest first. Filters: state,
+// check_id, limit (default 50, max 200).
+// GET /api/v1/incidents
+func ListIncidents(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		query := db.Preload("Check", func(tx *gorm.DB) *gorm.DB {
+			return tx.Unscoped().Select("id", "name")
+		}).Where("org_id = ?", orgID)
+		if state := c.Query("state"); state != "" {
+			switch models.IncidentState(state) {
+			case models.IncidentStateTriggered, models.IncidentStateAcknowledged, models.IncidentStateResolved:
+				query = query.Where("state = ?", state)
+			default:
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "state must be triggered, acknowledged or resolved",
+				})
+			}
+		}
+		if checkParam := c.Query("check_id"); checkParam != "" {
+			checkID, err := strconv.ParseUint(checkParam, 10, 32)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid check ID",
+				})
+			}
+			query = query.Where("check_id = ?", checkID)
+		}
+		limit, _ := parseAlertQueryParams(c)
+
+		var incidents []models.Incident
+		if err := query.Order("triggered_at DESC").Limit(limit).Find(&incidents).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch incidents",
+			})
+		}
+
+		dtos := make([]IncidentDTO, len(incidents))
+		for i, incident := range incidents {
+			dtos[i] = newIncidentDTO(incident, incident.Check.Name)
+		}
+		return c.JSON(fiber.Map{"incidents": dtos})
+	}
+}
+
+// GetIncidentStats returns incident counts and MTTA/MTTR for incidents
+// triggered in the last window_hours (default 24), optionally for one check
+// GET /api/v1/incidents/stats
+func GetIncidentStats(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		_, cutoff := parseAlertQueryParams(c)
+
+		query := db.Model(&models.Incident{}).Where("org_id = ? AND triggered_at >= ?", orgID, cutoff)
+		if checkParam := c.Query("check_id"); checkParam != "" {
+			checkID, err := strconv.ParseUint(checkParam, 10, 32)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid check ID",
+				})
+			}
+			query = query.Where("check_id = ?", checkID)
+		}
+
+		var stats IncidentStatsResponse
+		err := query.Select(`
+            COUNT(*) AS total,
+            COUNT(*) FILTER (WHERE state = ?) AS triggered,
+            COUNT(*) FILTER (WHERE state = ?) AS acknowledged,
+            COUNT(*) FILTER (WHERE state = ?) AS resolved,
+            AVG(EXTRACT(EPOCH FROM acknowledged_at - triggered_at)) AS mtta_seconds,
+            AVG(EXTRACT(EPOCH FROM resolved_at - triggered_at)) AS mttr_seconds`,
+			models.IncidentStateTriggered, models.IncidentStateAcknowledged, models.IncidentStateResolved).
+			Scan(&stats).Error
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to compute incident stats",
+			})
+		}
+		stats.WindowHours = int(time.Since(cutoff).Round(time.Hour).Hours())
+		return c.JSON(stats)
+	}
+}
+
+// GetIncident returns an incident with its timeline and alerts
+// GET /api/v1/incidents/:id
+func GetIncident(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		incident, err := findIncident(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		return incidentDetail(db, c, *incident)
+	}
+}
+
+// AcknowledgeIncident marks a triggered incident as being worked on, which
+// stops its repeat notifications
+// POST /api/v1/incidents/:id/acknowledge
+func AcknowledgeIncident(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+		incident, err := findIncident(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := db.Model(&models.Incident{}).
+			Where("id = ? AND state = ?", incident.ID, models.IncidentStateTriggered).
+			Updates(map[string]interface{}{
+				"state":              models.IncidentStateAcknowledged,
+				"acknowledged_at":    now,
+				"acknowledged_by_id": userID,
+			})
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to acknowledge incident",
+			})
+		}
+		if result.RowsAffected == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "incident is already " + string(currentIncidentState(db, *incident)),
+			})
+		}
+		addUserIncidentEvent(db, incident.ID, userID, models.IncidentEventAcknowledged, nil, "")
+
+		incident.State = models.IncidentStateAcknowledged
+		incident.AcknowledgedAt = &now
+		incident.AcknowledgedByID = &userID
+		return incidentDetail(db, c, *incident)
+	}
+}
+
+// ResolveIncident closes an incident by hand, with an optional note. The
+// check's next outage opens a new incident.
+// POST /api/v1/incidents/:id/resolve
+func ResolveIncident(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+		incident, err := findIncident(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		var req IncidentNoteRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid request body",
+				})
+			}
+		}
+		req.Body = strings.TrimSpace(req.Body)
+		if len(req.Body) > maxIncidentCommentLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "note is too long",
+			})
+		}
+
+		now := time.Now()
+		result := db.Model(&models.Incident{}).
+			Where("id = ? AND state <> ?", incident.ID, models.IncidentStateResolved).
+			Updates(map[string]interface{}{
+				"state":          models.IncidentStateResolved,
+				"resolved_at":    now,
+				"resolved_by_id": userID,
+			})
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to resolve incident",
+			})
+		}
+		if result.RowsAffected == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "incident is already resolved",
+			})
+		}
+		addUserIncidentEvent(db, incident.ID, userID, models.IncidentEventResolved, nil, req.Body)
+
+		incident.State = models.IncidentStateResolved
+		incident.ResolvedAt = &now
+		incident.ResolvedByID = &userID
+		return incidentDetail(db, c, *incident)
+	}
+}
+
+// AssignIncident sets or clears an open incident's assignee, who must be a
+// member of the org
+// PUT /api/v1/incidents/:id/assignee
+func AssignIncident(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+		incident, err := findIncident(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		var req AssignIncidentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		if !incident.IsOpen() {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "incident is already resolved",
+			})
+		}
+		if req.AssigneeID != nil {
+			var count int64
+			if err := db.Model(&models.User{}).Where("id = ? AND org_id = ?", *req.AssigneeID, orgID).Count(&count).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "failed to verify assignee",
+				})
+			}
+			if count == 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "assignee must be a member of the organization",
+				})
+			}
+		}
+
+		if err := db.Model(incident).Update("assignee_id", req.AssigneeID).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to assign incident",
+			})
+		}
+		eventType := models.IncidentEventAssigned
+		if req.AssigneeID == nil {
+			eventType = models.IncidentEventUnassigned
+		}
+		addUserIncidentEvent(db, incident.ID, userID, eventType, req.AssigneeID, "")
+
+		incident.AssigneeID = req.AssigneeID
+		return incidentDetail(db, c, *incident)
+	}
+}
+
+// AddIncidentComment appends a comment to an incident's timeline
+// POST /api/v1/incidents/:id/comments
+func AddIncidentComment(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+		incident, err := findIncident(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		var req IncidentNoteRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		req.Body = strings.TrimSpace(req.Body)
+		if req.Body == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "body is required",
+			})
+		}
+		if len(req.Body) > maxIncidentCommentLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "comment is too long",
+			})
+		}
+
+		event := addUserIncidentEvent(db, incident.ID, userID, models.IncidentEventComment, nil, req.Body)
+		if event == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to add comment",
+			})
+		}
+		return c.Status(fiber.StatusCreated).JSON(event)
+	}
+}
+
+func findIncident(db *gorm.DB, c *fiber.Ctx, orgID uint) (*models.Incident, error) {
+	incidentID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid incident ID")
+	}
+
+	var incident models.Incident
+	if err := db.Where("id = ? AND org_id = ?", incidentID, orgID).First(&incident).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "incident not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch incident")
+	}
+	return &incident, nil
+}
+
+// incidentDetail responds with the incident, its timeline and its alerts
+func incidentDetail(db *gorm.DB, c *fiber.Ctx, incident models.Incident) error {
+	var check models.Check
+	if err := db.Unscoped().Select("id", "name").First(&check, incident.CheckID).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch check")
+	}
+	var timeline []models.IncidentEvent
+	if err := db.Where("incident_id = ?", incident.ID).Order("created_at ASC, id ASC").Find(&timeline).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch incident timeline")
+	}
+	var alerts []models.Alert
+	if err := db.Where("incident_id = ?", incident.ID).Order("created_at ASC").Find(&alerts).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch alerts")
+	}
+
+	alertDTOs := make([]AlertResponse, len(alerts))
+	for i, alert := range alerts {
+		alertDTOs[i] = toAlertResponse(alert, check.Name)
+	}
+	return c.JSON(IncidentDetailResponse{
+		Incident: newIncidentDTO(incident, check.Name),
+		Timeline: timeline,
+		Alerts:   alertDTOs,
+	})
+}
+
+// currentIncidentState rereads an incident's state after a conditional
+// update matched nothing
+func currentIncidentState(db *gorm.DB, incident models.Incident) models.IncidentState {
+	var current models.Incident
+	if err := db.Select("state").First(&current, incident.ID).Error; err != nil {
+		return incident.State
+	}
+	return current.State
+}
+
+// addUserIncidentEvent appends an entry made by a user to an incident's
+// timeline. Returns nil if it couldn't be stored.
+func addUserIncidentEvent(db *gorm.DB, incidentID, userID uint, eventType models.IncidentEventType, assigneeID *uint, body string) *models.IncidentEvent {
+	event := models.IncidentEvent{
+		IncidentID: incidentID,
+		Type:       eventType,
+		UserID:     &userID,
+		AssigneeID: assigneeID,
+		Body:       body,
+	}
+	if err := db.Create(&event).Error; err != nil {
+		return nil
+	}
+	return &event
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/schedule"
+	"gorm.io/gorm"
+)
+
+const (
+	maxMaintenanceDurationMinutes = 7 * 24 * 60
+	maxMaintenanceCheckIDs        = 100
+	maxMaintenanceTags            = 10
+)
+
+type CreateMaintenanceWindowRequest struct {
+	Name            string            `json:"name"`
+	Scope           string            `json:"scope,omitempty"`     // org (default), checks or tags
+	CheckIDs        []int64           `json:"check_ids,omitempty"` // checks scope
+	Tags            map[string]string `json:"tags,omitempty"`      // tags scope; a check must carry all of them
+	StartsAt        *time.Time        `json:"starts_at,omitempty"` // Required for one-off windows
+	EndsAt          *time.Time        `json:"ends_at,omitempty"`
+	Schedule        string            `json:"schedule,omitempty"` // Cron or RRULE; makes the window recurring
+	DurationMinutes int               `json:"duration_minutes,omitempty"`
+	Timezone        string            `json:"timezone,omitempty"` // IANA name for the schedule (default UTC)
+}
+
+type UpdateMaintenanceWindowRequest struct {
+	Name            *string            `json:"name,omitempty"`
+	Scope           *string            `json:"scope,omitempty"`
+	CheckIDs        *[]int64           `json:"check_ids,omitempty"`
+	Tags            *map[string]string `json:"tags,omitempty"`
+	StartsAt        *time.Time         `json:"starts_at,omitempty"`
+	EndsAt          *time.Time         `json:"ends_at,omitempty"`
+	ClearStartsAt   bool               `json:"clear_starts_at,omitempty"` // Unbound a recurring window's start
+	ClearEndsAt     bool               `json:"clear_ends_at,omitempty"`
+	Schedule        *string            `json:"schedule,omitempty"` // "" makes the window one-off
+	DurationMinutes *int               `json:"duration_minutes,omitempty"`
+	Timezone        *string            `json:"timezone,omitempty"`
+}
+
+// MaintenanceWindowDTO is a maintenance window and whether it is open now
+type MaintenanceWindowDTO struct {
+	models.MaintenanceWindow
+	Active bool `json:"active"`
+}
+
+func newMaintenanceWindowDTO(w models.MaintenanceWindow) MaintenanceWindowDTO {
+	return MaintenanceWindowDTO{MaintenanceWindow: w, Active: w.ActiveAt(time.Now())}
+}
+
+// ListMaintenanceWindows returns the org's maintenance windows
+// GET /api/v1/maintenance-windows
+func ListMaintenanceWindows(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		var windows []models.MaintenanceWindow
+		if err := db.Where("org_id = ?", orgID).Order("created_at DESC").Find(&windows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch maintenance windows",
+			})
+		}
+
+		dtos := make([]MaintenanceWindowDTO, len(windows))
+		for i, w := range windows {
+			dtos[i] = newMaintenanceWindowDTO(w)
+		}
+		return c.JSON(dtos)
+	}
+}
+
+// CreateMaintenanceWindow creates a one-off or recurring maintenance window
+// POST /api/v1/maintenance-windows
+func CreateMaintenanceWindow(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		var req CreateMaintenanceWindowRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		window := models.MaintenanceWindow{
+			OrgID:           orgID,
+			Name:            req.Name,
+			Scope:           models.MaintenanceScope(req.Scope),
+			CheckIDs:        pq.Int64Array(req.CheckIDs),
+			Tags:            models.StringMap(req.Tags),
+			StartsAt:        req.StartsAt,
+			EndsAt:          req.EndsAt,
+			Schedule:        req.Schedule,
+			DurationMinutes: req.DurationMinutes,
+			Timezone:        req.Timezone,
+			CreatedByID:     &userID,
+		}
+		if err := validateMaintenanceWindow(db, &window); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Create(&window).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create maintenance window",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionMaintenanceCreated, "maintenance_window", &window.ID, models.JSONMap{
+			"name":  window.Name,
+			"scope": window.Scope,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.Status(fiber.StatusCreated).JSON(newMaintenanceWindowDTO(window))
+	}
+}
+
+// GetMaintenanceWindow returns a single maintenance window
+// GET /api/v1/maintenance-windows/:id
+func GetMaintenanceWindow(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		window, err := findMaintenanceWindow(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(newMaintenanceWindowDTO(*window))
+	}
+}
+
+// UpdateMaintenanceWindow updates a maintenance window
+// PUT /api/v1/maintenance-windows/:id
+func UpdateMaintenanceWindow(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		window, err := findMaintenanceWindow(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		var req UpdateMaintenanceWindowRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		if req.Name != nil {
+			window.Name = *req.Name
+		}
+		if req.Scope != nil {
+			window.Scope = models.MaintenanceScope(*req.Scope)
+		}
+		if req.CheckIDs != nil {
+			window.CheckIDs = pq.Int64Array(*req.CheckIDs)
+		}
+		if req.Tags != nil {
+			window.Tags = models.StringMap(*req.Tags)
+		}
+		if req.StartsAt != nil {
+			window.StartsAt = req.StartsAt
+		}
+		if req.ClearStartsAt {
+			window.StartsAt = nil
+		}
+		if req.EndsAt != nil {
+			window.EndsAt = req.EndsAt
+		}
+		if req.ClearEndsAt {
+			window.EndsAt = nil
+		}
+		if req.Schedule != nil {
+			window.Schedule = *req.Schedule
+		}
+		if req.DurationMinutes != nil {
+			window.DurationMinutes = *req.DurationMinutes
+		}
+		if req.Timezone != nil {
+			window.Timezone = *req.Timezone
+		}
+		if err := validateMaintenanceWindow(db, window); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Save(window).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to update maintenance window",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionMaintenanceUpdated, "maintenance_window", &window.ID, models.JSONMap{
+			"name":  window.Name,
+			"scope": window.Scope,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(newMaintenanceWindowDTO(*window))
+	}
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window, ending it at once
+// DELETE /api/v1/maintenance-windows/:id
+func DeleteMaintenanceWindow(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		window, err := findMaintenanceWindow(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		if err := db.Delete(window).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to delete maintenance window",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionMaintenanceDeleted, "maintenance_window", &window.ID, models.JSONMap{
+			"name": window.Name,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(fiber.Map{
+			"message": "maintenance window deleted successfully",
+		})
+	}
+}
+
+// findMaintenanceWindow loads the org's window named by the :id param. Its
+// errors are *fiber.Error, rendered as {"error": ...} by the app's error
+// handler.
+func findMaintenanceWindow(db *gorm.DB, c *fiber.Ctx, orgID uint) (*models.MaintenanceWindow, error) {
+	windowID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid maintenance window ID")
+	}
+
+	var window models.MaintenanceWindow
+	if err := db.Where("id = ? AND org_id = ?", windowID, orgID).First(&window).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "maintenance window not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch maintenance window")
+	}
+	return &window, nil
+}
+
+// validateMaintenanceWindow normalizes and validates a window's scope and
+// timing. Options of the unused scope are cleared.
+func validateMaintenanceWindow(db *gorm.DB, w *models.MaintenanceWindow) error {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" {
+		return errors.New("name is required")
+	}
+
+	w.Scope = models.MaintenanceScope(strings.ToLower(strings.TrimSpace(string(w.Scope))))
+	switch w.Scope {
+	case "", models.MaintenanceScopeOrg:
+		w.Scope = models.MaintenanceScopeOrg
+		w.CheckIDs, w.Tags = nil, nil
+	case models.MaintenanceScopeChecks:
+		w.Tags = nil
+		if len(w.CheckIDs) == 0 || len(w.CheckIDs) > maxMaintenanceCheckIDs {
+			return fmt.Errorf("check_ids must list 1 to %d checks", maxMaintenanceCheckIDs)
+		}
+		var found int64
+		if err := db.Model(&models.Check{}).Where("org_id = ? AND id IN ?", w.OrgID, []int64(w.CheckIDs)).Count(&found).Error; err != nil {
+			return errors.New("failed to verify check_ids")
+		}
+		if int(found) != len(uniqueIDs(w.CheckIDs)) {
+			return errors.New("check_ids contains an unknown check")
+		}
+	case models.MaintenanceScopeTags:
+		w.CheckIDs = nil
+		if len(w.Tags) == 0 || len(w.Tags) > maxMaintenanceTags {
+			return fmt.Errorf("tags must have 1 to %d entries", maxMaintenanceTags)
+		}
+	default:
+		return fmt.Errorf("invalid scope %q (must be org, checks or tags)", w.Scope)
+	}
+
+	if w.StartsAt != nil && w.EndsAt != nil && !w.EndsAt.After(*w.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	w.Schedule = strings.TrimSpace(w.Schedule)
+	if w.Schedule == "" {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return errors.New("starts_at and ends_at are required without a schedule")
+		}
+		w.DurationMinutes, w.Timezone = 0, ""
+		return nil
+	}
+
+	if _, err := schedule.Parse(w.Schedule); err != nil {
+		return err
+	}
+	if w.DurationMinutes < 1 || w.DurationMinutes > maxMaintenanceDurationMinutes {
+		return fmt.Errorf("duration_minutes must be between 1 and %d", maxMaintenanceDurationMinutes)
+	}
+	w.Timezone = strings.TrimSpace(w.Timezone)
+	if w.Timezone == "" {
+		w.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(w.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", w.Timezone)
+	}
+	return nil
+}
+
+// uniqueIDs returns ids without duplicates
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var out []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/oFuterman/light-house/internal/models"
@@ -242,6 +243,17 @@ func RemoveMember(db *gorm.DB) fiber.Handler {
 				}
 			}
 
+			// Take the member off the org's on-call rotations and overrides
+			if err := tx.Exec(`UPDATE on_call_schedules SET member_ids = array_remove(member_ids, ?) WHERE org_id = ?`,
+				member.ID, orgID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ? AND ends_at > ? AND schedule_id IN (?)", member.ID, time.Now(),
+				tx.Model(&models.OnCallSchedule{}).Select("id").Where("org_id = ?", orgID)).
+				Delete(&models.OnCallOverride{}).Error; err != nil {
+				return err
+			}
+
 			// Soft delete the member
 			if err := tx.Delete(&member).Error; err != nil {
 				return err
@@ -13,14 +13,16 @@ import (
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
 type NotificationSettingsRequest struct {
-    EmailRecipients []string `json:"email_recipients"`
-    WebhookURL      *string  `json:"webhook_url"`
+    EmailRecipients   []string `json:"email_recipients"`
+    WebhookURL        *string  `json:"webhook_url"`
+    OnCallScheduleIDs *[]uint  `json:"on_call_schedule_ids"` // Omitted keeps the current schedules
 }
 
 type NotificationSettingsResponse struct {
-    ID              uint     `json:"id"`
-    EmailRecipients []string `json:"email_recipients"`
-    WebhookURL      *string  `json:"webhook_url,omitempty"`
+    ID                uint     `json:"id"`
+    EmailRecipients   []string `json:"email_recipients"`
+    WebhookURL        *string  `json:"webhook_url,omitempty"`
+    OnCallScheduleIDs []int64  `json:"on_call_schedule_ids"`
 }
 
 // validateEmails checks each email has valid format
@@ -49,7 +51,8 @@ func GetNotificationSettings(db *gorm.DB) fiber.Handler {
             if err == gorm.ErrRecordNotFound {
                 // Return empty settings if none exist
                 return c.JSON(NotificationSettingsResponse{
-                    EmailRecipients: []string{},
+                    EmailRecipients:   []string{},
+                    OnCallScheduleIDs: []int64{},
                 })
             }
             return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -57,9 +60,10 @@ func GetNotificationSettings(db *gorm.DB) fiber.Handler {
             })
         }
         return c.JSON(NotificationSettingsResponse{
-            ID:              settings.ID,
-            EmailRecipients: settings.EmailRecipients,
-            WebhookURL:      settings.WebhookURL,
+            ID:                settings.ID,
+            EmailRecipients:   settings.EmailRecipients,
+            WebhookURL:        settings.WebhookURL,
+            OnCallScheduleIDs: settings.OnCallScheduleIDs,
         })
     }
 }
@@ -95,14 +99,25 @@ func UpdateNotificationSettings(db *gorm.DB) fiber.Handler {
                 req.WebhookURL = &url
             }
         }
+        // Validate on-call schedules belong to the org
+        var scheduleIDs pq.Int64Array
+        if req.OnCallScheduleIDs != nil {
+            scheduleIDs, err = validateOnCallScheduleIDs(db, orgID, *req.OnCallScheduleIDs)
+            if err != nil {
+                return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+                    "error": err.Error(),
+                })
+            }
+        }
         // Upsert settings
         var settings models.NotificationSettings
         err = db.Where("org_id = ?", orgID).First(&settings).Error
         if err == gorm.ErrRecordNotFound {
             settings = models.NotificationSettings{
-                OrgID:           orgID,
-                EmailRecipients: pq.StringArray(validatedEmails),
-                WebhookURL:      req.WebhookURL,
+                OrgID:             orgID,
+                EmailRecipients:   pq.StringArray(validatedEmails),
+                WebhookURL:        req.WebhookURL,
+                OnCallScheduleIDs: scheduleIDs,
             }
             if err := db.Create(&settings).Error; err != nil {
                 return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -116,6 +131,9 @@ func UpdateNotificationSettings(db *gorm.DB) fiber.Handler {
         } else {
             settings.EmailRecipients = pq.StringArray(validatedEmails)
             settings.WebhookURL = req.WebhookURL
+            if req.OnCallScheduleIDs != nil {
+                settings.OnCallScheduleIDs = scheduleIDs
+            }
             if err := db.Save(&settings).Error; err != nil {
                 return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
                     "error": "failed to update notification settings",
@@ -124,14 +142,16 @@ func UpdateNotificationSettings(db *gorm.DB) fiber.Handler {
         }
 
         logAuditEvent(db, orgID, &userID, models.AuditActionSettingsUpdated, "notification_settings", &settings.ID, models.JSONMap{
-            "email_recipients": validatedEmails,
-            "webhook_url":     req.WebhookURL,
+            "email_recipients":     validatedEmails,
+            "webhook_url":          req.WebhookURL,
+            "on_call_schedule_ids": settings.OnCallScheduleIDs,
         }, c.IP(), c.Get("User-Agent"))
 
         return c.JSON(NotificationSettingsResponse{
-            ID:              settings.ID,
-            EmailRecipients: settings.EmailRecipients,
-            WebhookURL:      settings.WebhookURL,
+            ID:                settings.ID,
+            EmailRecipients:   settings.EmailRecipients,
+            WebhookURL:        settings.WebhookURL,
+            OnCallScheduleIDs: settings.OnCallScheduleIDs,
         })
     }
 }
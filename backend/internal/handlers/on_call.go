@@ -0,0 +1,542 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/oncall"
+	"github.com/oFuterman/light-house/internal/schedule"
+	"gorm.io/gorm"
+)
+
+const (
+	maxOnCallMembers         = 50
+	maxOnCallOverrideDays    = 90
+	defaultOnCallHandoffTime = "09:00"
+)
+
+type CreateOnCallScheduleRequest struct {
+	Name           string     `json:"name"`
+	Rotation       string     `json:"rotation"`                  // daily or weekly
+	HandoffTime    string     `json:"handoff_time,omitempty"`    // HH:MM (default 09:00)
+	HandoffWeekday *int       `json:"handoff_weekday,omitempty"` // 0 = Sunday; weekly only (default Monday)
+	Timezone       string     `json:"timezone,omitempty"`        // IANA name (default UTC)
+	StartsAt       *time.Time `json:"starts_at,omitempty"`       // Default now
+	MemberIDs      []int64    `json:"member_ids"`                // Rotation order
+}
+
+type UpdateOnCallScheduleRequest struct {
+	Name           *string    `json:"name,omitempty"`
+	Rotation       *string    `json:"rotation,omitempty"`
+	HandoffTime    *string    `json:"handoff_time,omitempty"`
+	HandoffWeekday *int       `json:"handoff_weekday,omitempty"`
+	Timezone       *string    `json:"timezone,omitempty"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	MemberIDs      *[]int64   `json:"member_ids,omitempty"` // Replaces the rotation
+}
+
+type CreateOnCallOverrideRequest struct {
+	UserID   uint      `json:"user_id"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Note     string    `json:"note,omitempty"`
+}
+
+// OnCallScheduleDTO is an on-call schedule and who is on call now
+type OnCallScheduleDTO struct {
+	models.OnCallSchedule
+	OnCall *oncall.Shift `json:"on_call,omitempty"`
+}
+
+func newOnCallScheduleDTO(db *gorm.DB, s models.OnCallSchedule) OnCallScheduleDTO {
+	dto := OnCallScheduleDTO{OnCallSchedule: s}
+	if shift, err := oncall.At(db, s, time.Now()); err == nil {
+		dto.OnCall = &shift
+	}
+	return dto
+}
+
+// ListOnCallSchedules returns the org's on-call schedules
+// GET /api/v1/on-call-schedules
+func ListOnCallSchedules(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		var schedules []models.OnCallSchedule
+		if err := db.Where("org_id = ?", orgID).Order("name ASC").Find(&schedules).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch on-call schedules",
+			})
+		}
+
+		dtos := make([]OnCallScheduleDTO, len(schedules))
+		for i, s := range schedules {
+			dtos[i] = newOnCallScheduleDTO(db, s)
+		}
+		return c.JSON(dtos)
+	}
+}
+
+// GetOnCallSchedule returns a single on-call schedule
+// GET /api/v1/on-call-schedules/:id
+func GetOnCallSchedule(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		return c.JSON(newOnCallScheduleDTO(db, *s))
+	}
+}
+
+// CreateOnCallSchedule creates an on-call schedule
+// POST /api/v1/on-call-schedules
+func CreateOnCallSchedule(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		var req CreateOnCallScheduleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		s := models.OnCallSchedule{
+			OrgID:          orgID,
+			Name:           req.Name,
+			Rotation:       schedule.RotationPeriod(req.Rotation),
+			HandoffTime:    req.HandoffTime,
+			HandoffWeekday: int(time.Monday),
+			Timezone:       req.Timezone,
+			StartsAt:       time.Now(),
+			MemberIDs:      pq.Int64Array(req.MemberIDs),
+			CreatedByID:    &userID,
+		}
+		if req.HandoffWeekday != nil {
+			s.HandoffWeekday = *req.HandoffWeekday
+		}
+		if req.StartsAt != nil {
+			s.StartsAt = *req.StartsAt
+		}
+		if err := validateOnCallSchedule(db, &s); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Create(&s).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create on-call schedule",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionOnCallCreated, "on_call_schedule", &s.ID, models.JSONMap{
+			"name":     s.Name,
+			"rotation": s.Rotation,
+			"members":  len(s.MemberIDs),
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.Status(fiber.StatusCreated).JSON(newOnCallScheduleDTO(db, s))
+	}
+}
+
+// UpdateOnCallSchedule updates an on-call schedule. Changing the rotation
+// or its members reassigns shifts from now on; overrides stay.
+// PUT /api/v1/on-call-schedules/:id
+func UpdateOnCallSchedule(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		var req UpdateOnCallScheduleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		if req.Name != nil {
+			s.Name = *req.Name
+		}
+		if req.Rotation != nil {
+			s.Rotation = schedule.RotationPeriod(*req.Rotation)
+		}
+		if req.HandoffTime != nil {
+			s.HandoffTime = *req.HandoffTime
+		}
+		if req.HandoffWeekday != nil {
+			s.HandoffWeekday = *req.HandoffWeekday
+		}
+		if req.Timezone != nil {
+			s.Timezone = *req.Timezone
+		}
+		if req.StartsAt != nil {
+			s.StartsAt = *req.StartsAt
+		}
+		if req.MemberIDs != nil {
+			s.MemberIDs = pq.Int64Array(*req.MemberIDs)
+		}
+		if err := validateOnCallSchedule(db, s); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Save(s).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to update on-call schedule",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionOnCallUpdated, "on_call_schedule", &s.ID, models.JSONMap{
+			"name":     s.Name,
+			"rotation": s.Rotation,
+			"members":  len(s.MemberIDs),
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(newOnCallScheduleDTO(db, *s))
+	}
+}
+
+// DeleteOnCallSchedule deletes an on-call schedule and its overrides, and
+// drops it from the org's notification settings. Schedules still targeted
+// by an escalation policy can't be deleted.
+// DELETE /api/v1/on-call-schedules/:id
+func DeleteOnCallSchedule(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		var policies []string
+		target := fmt.Sprintf(`[{"targets": [{"type": %q, "schedule_id": %d}]}]`, models.EscalationTargetOnCall, s.ID)
+		if err := db.Model(&models.EscalationPolicy{}).
+			Where("org_id = ? AND levels @> ?::jsonb", orgID, target).
+			Order("name ASC").
+			Pluck("name", &policies).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to check escalation policies",
+			})
+		}
+		if len(policies) > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "schedule is used by escalation policies: " + strings.Join(policies, ", "),
+			})
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("schedule_id = ?", s.ID).Delete(&models.OnCallOverride{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`UPDATE notification_settings SET on_call_schedule_ids = array_remove(on_call_schedule_ids, ?) WHERE org_id = ?`,
+				s.ID, orgID).Error; err != nil {
+				return err
+			}
+			return tx.Delete(s).Error
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to delete on-call schedule",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionOnCallDeleted, "on_call_schedule", &s.ID, models.JSONMap{
+			"name": s.Name,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(fiber.Map{
+			"message": "on-call schedule deleted successfully",
+		})
+	}
+}
+
+// WhoIsOnCall returns who is on call for a schedule now, or at the time
+// given by ?at= (RFC 3339)
+// GET /api/v1/on-call-schedules/:id/on-call
+func WhoIsOnCall(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		at := time.Now()
+		if param := c.Query("at"); param != "" {
+			t, err := time.Parse(time.RFC3339, param)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "at must be an RFC 3339 time",
+				})
+			}
+			at = t
+		}
+
+		shift, err := oncall.At(db, *s, at)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to resolve on-call shift",
+			})
+		}
+		return c.JSON(shift)
+	}
+}
+
+// ListOnCallOverrides returns a schedule's current and upcoming overrides
+// GET /api/v1/on-call-schedules/:id/overrides
+func ListOnCallOverrides(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		var overrides []models.OnCallOverride
+		if err := db.Where("schedule_id = ? AND ends_at > ?", s.ID, time.Now()).
+			Order("starts_at ASC, id ASC").
+			Find(&overrides).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch overrides",
+			})
+		}
+		return c.JSON(overrides)
+	}
+}
+
+// CreateOnCallOverride puts a member on call for a span of time in place
+// of the rotation
+// POST /api/v1/on-call-schedules/:id/overrides
+func CreateOnCallOverride(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+
+		var req CreateOnCallOverrideRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+
+		override := models.OnCallOverride{
+			ScheduleID:  s.ID,
+			UserID:      req.UserID,
+			StartsAt:    req.StartsAt,
+			EndsAt:      req.EndsAt,
+			Note:        strings.TrimSpace(req.Note),
+			CreatedByID: &userID,
+		}
+		if err := validateOnCallOverride(db, orgID, &override); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := db.Create(&override).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to create override",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionOnCallOverrideCreated, "on_call_schedule", &s.ID, models.JSONMap{
+			"override_id": override.ID,
+			"user_id":     override.UserID,
+			"starts_at":   override.StartsAt,
+			"ends_at":     override.EndsAt,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.Status(fiber.StatusCreated).JSON(override)
+	}
+}
+
+// DeleteOnCallOverride deletes an override, handing its span back to the
+// rotation
+// DELETE /api/v1/on-call-schedules/:id/overrides/:override_id
+func DeleteOnCallOverride(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		userID := c.Locals("userID").(uint)
+
+		s, err := findOnCallSchedule(db, c, orgID)
+		if err != nil {
+			return err
+		}
+		overrideID, err := strconv.ParseUint(c.Params("override_id"), 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid override ID",
+			})
+		}
+
+		result := db.Where("id = ? AND schedule_id = ?", overrideID, s.ID).Delete(&models.OnCallOverride{})
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to delete override",
+			})
+		}
+		if result.RowsAffected == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "override not found",
+			})
+		}
+
+		logAuditEvent(db, orgID, &userID, models.AuditActionOnCallOverrideDeleted, "on_call_schedule", &s.ID, models.JSONMap{
+			"override_id": overrideID,
+		}, c.IP(), c.Get("User-Agent"))
+
+		return c.JSON(fiber.Map{
+			"message": "override deleted successfully",
+		})
+	}
+}
+
+// findOnCallSchedule loads the org's schedule named by the :id param. Its
+// errors are *fiber.Error, rendered as {"error": ...} by the app's error
+// handler.
+func findOnCallSchedule(db *gorm.DB, c *fiber.Ctx, orgID uint) (*models.OnCallSchedule, error) {
+	scheduleID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid on-call schedule ID")
+	}
+
+	var s models.OnCallSchedule
+	if err := db.Where("id = ? AND org_id = ?", scheduleID, orgID).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fiber.NewError(fiber.StatusNotFound, "on-call schedule not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch on-call schedule")
+	}
+	return &s, nil
+}
+
+// validateOnCallSchedule normalizes and validates a schedule's rotation and
+// members. Members must belong to the org; duplicates are dropped.
+func validateOnCallSchedule(db *gorm.DB, s *models.OnCallSchedule) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+
+	s.Rotation = schedule.RotationPeriod(strings.ToLower(strings.TrimSpace(string(s.Rotation))))
+	switch s.Rotation {
+	case schedule.Daily:
+		s.HandoffWeekday = 0
+	case schedule.Weekly:
+		if s.HandoffWeekday < 0 || s.HandoffWeekday > 6 {
+			return errors.New("handoff_weekday must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	default:
+		return fmt.Errorf("invalid rotation %q (must be daily or weekly)", s.Rotation)
+	}
+
+	s.HandoffTime = strings.TrimSpace(s.HandoffTime)
+	if s.HandoffTime == "" {
+		s.HandoffTime = defaultOnCallHandoffTime
+	}
+	hour, minute, err := schedule.ParseHandoff(s.HandoffTime)
+	if err != nil {
+		return err
+	}
+	s.HandoffTime = fmt.Sprintf("%02d:%02d", hour, minute)
+
+	s.Timezone = strings.TrimSpace(s.Timezone)
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", s.Timezone)
+	}
+
+	members := uniqueIDs(s.MemberIDs)
+	if len(members) == 0 || len(members) > maxOnCallMembers {
+		return fmt.Errorf("member_ids must list 1 to %d members", maxOnCallMembers)
+	}
+	var found int64
+	if err := db.Model(&models.User{}).Where("org_id = ? AND id IN ?", s.OrgID, members).Count(&found).Error; err != nil {
+		return errors.New("failed to verify member_ids")
+	}
+	if int(found) != len(members) {
+		return errors.New("member_ids contains a user who is not a member of the organization")
+	}
+	s.MemberIDs = pq.Int64Array(members)
+	return nil
+}
+
+// validateOnCallOverride checks an override's span and that its user
+// belongs to the org
+func validateOnCallOverride(db *gorm.DB, orgID uint, o *models.OnCallOverride) error {
+	if o.StartsAt.IsZero() || o.EndsAt.IsZero() {
+		return errors.New("starts_at and ends_at are required")
+	}
+	if !o.EndsAt.After(o.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if !o.EndsAt.After(time.Now()) {
+		return errors.New("ends_at must be in the future")
+	}
+	if o.EndsAt.Sub(o.StartsAt) > maxOnCallOverrideDays*24*time.Hour {
+		return fmt.Errorf("an override can last at most %d days", maxOnCallOverrideDays)
+	}
+	if len(o.Note) > 255 {
+		return errors.New("note must be at most 255 characters")
+	}
+
+	if o.UserID == 0 {
+		return errors.New("user_id is required")
+	}
+	var count int64
+	if err := db.Model(&models.User{}).Where("id = ? AND org_id = ?", o.UserID, orgID).Count(&count).Error; err != nil {
+		return errors.New("failed to verify user_id")
+	}
+	if count == 0 {
+		return fmt.Errorf("user %d is not a member of the organization", o.UserID)
+	}
+	return nil
+}
+
+// validateOnCallScheduleIDs checks that every schedule belongs to the org
+// and drops duplicates
+func validateOnCallScheduleIDs(db *gorm.DB, orgID uint, ids []uint) (pq.Int64Array, error) {
+	scheduleIDs := make([]int64, len(ids))
+	for i, id := range ids {
+		scheduleIDs[i] = int64(id)
+	}
+	scheduleIDs = uniqueIDs(scheduleIDs)
+	if len(scheduleIDs) == 0 {
+		return pq.Int64Array{}, nil
+	}
+	var found int64
+	if err := db.Model(&models.OnCallSchedule{}).Where("org_id = ? AND id IN ?", orgID, scheduleIDs).Count(&found).Error; err != nil {
+		return nil, errors.New("failed to verify on_call_schedule_ids")
+	}
+	if int(found) != len(scheduleIDs) {
+		return nil, errors.New("on_call_schedule_ids contains an unknown schedule")
+	}
+	return pq.Int64Array(scheduleIDs), nil
+}
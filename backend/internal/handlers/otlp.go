@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/billing"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/otlp"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/gorm"
+)
+
+// OTLPTraces receives OTLP/HTTP trace exports (protobuf or JSON, optionally gzipped)
+// POST /v1/traces
+func OTLPTraces(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		enc, body, err := readOTLPRequest(c)
+		if err != nil {
+			return otlpError(c, fiber.StatusBadRequest, err.Error())
+		}
+		req, err := otlp.DecodeTraces(body, enc)
+		if err != nil {
+			return otlpError(c, fiber.StatusBadRequest, "invalid trace export: "+err.Error())
+		}
+
+		spans, rejected := otlp.SpansFromRequest(req, orgID)
+		if len(spans) > 0 {
+			incomingBytes := int64(len(body))
+			if status, msg := checkIngestVolume(db, orgID, incomingBytes); status != fiber.StatusOK {
+				return otlpError(c, status, msg)
+			}
+			if err := db.CreateInBatches(spans, 100).Error; err != nil {
+				return otlpError(c, fiber.StatusInternalServerError, "failed to store spans")
+			}
+			billing.IncrementLogVolume(db, orgID, incomingBytes)
+		}
+
+		resp := &coltracepb.ExportTraceServiceResponse{}
+		if rejected.Count > 0 {
+			resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+				RejectedSpans: rejected.Count,
+				ErrorMessage:  rejected.Message,
+			}
+		}
+		return otlpRespond(c, enc, resp)
+	}
+}
+
+// OTLPLogs receives OTLP/HTTP log exports (protobuf or JSON, optionally gzipped)
+// POST /v1/logs
+func OTLPLogs(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		enc, body, err := readOTLPRequest(c)
+		if err != nil {
+			return otlpError(c, fiber.StatusBadRequest, err.Error())
+		}
+		req, err := otlp.DecodeLogs(body, enc)
+		if err != nil {
+			return otlpError(c, fiber.StatusBadRequest, "invalid log export: "+err.Error())
+		}
+
+		entries, rejected := otlp.LogsFromRequest(req, orgID)
+		if len(entries) > 0 {
+			incomingBytes := int64(len(body))
+			if status, msg := checkIngestVolume(db, orgID, incomingBytes); status != fiber.StatusOK {
+				return otlpError(c, status, msg)
+			}
+			if err := db.CreateInBatches(entries, 100).Error; err != nil {
+				return otlpError(c, fiber.StatusInternalServerError, "failed to store logs")
+			}
+			billing.IncrementLogVolume(db, orgID, incomingBytes)
+		}
+
+		resp := &collogspb.ExportLogsServiceResponse{}
+		if rejected.Count > 0 {
+			resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+				RejectedLogRecords: rejected.Count,
+				ErrorMessage:       rejected.Message,
+			}
+		}
+		return otlpRespond(c, enc, resp)
+	}
+}
+
+// readOTLPRequest resolves the request encoding and returns the decompressed body
+func readOTLPRequest(c *fiber.Ctx) (otlp.Encoding, []byte, error) {
+	enc, err := otlp.EncodingFromContentType(c.Get(fiber.HeaderContentType))
+	if err != nil {
+		return 0, nil, err
+	}
+	// Use the raw body: Ctx.Body() would decompress without a size cap
+	body, err := otlp.ReadBody(c.Request().Body(), c.Get(fiber.HeaderContentEncoding))
+	if err != nil {
+		return 0, nil, err
+	}
+	return enc, body, nil
+}
+
+// checkIngestVolume applies the plan's monthly volume limit to an OTLP batch.
+// Returns fiber.StatusOK when the batch may be stored.
+func checkIngestVolume(db *gorm.DB, orgID uint, incomingBytes int64) (int, string) {
+	var org models.Organization
+	if err := db.First(&org, orgID).Error; err != nil {
+		return fiber.StatusInternalServerError, "failed to load organization"
+	}
+	currentVolume, err := billing.GetCurrentLogVolume(db, orgID)
+	if err != nil {
+		// Fail closed - reject if we can't verify limits
+		return fiber.StatusInternalServerError, "failed to check usage limits"
+	}
+	plan := billing.EffectivePlan(&org)
+	if allowed, _, message := billing.CanIngestLogs(plan, currentVolume, incomingBytes); !allowed {
+		// 403 is non-retryable for OTLP exporters, which is what we want here
+		return fiber.StatusForbidden, message
+	}
+	return fiber.StatusOK, ""
+}
+
+// otlpRespond writes a successful export response in the request's encoding
+func otlpRespond(c *fiber.Ctx, enc otlp.Encoding, resp proto.Message) error {
+	data, err := otlp.MarshalResponse(resp, enc)
+	if err != nil {
+		return otlpError(c, fiber.StatusInternalServerError, "failed to encode response")
+	}
+	c.Set(fiber.HeaderContentType, enc.ContentType())
+	return c.Status(fiber.StatusOK).Send(data)
+}
+
+// otlpError writes an error response. OTLP exporters only inspect the status
+// code for retry decisions, so the JSON error shape used elsewhere is kept.
+func otlpError(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(fiber.Map{
+		"error": message,
+	})
+}
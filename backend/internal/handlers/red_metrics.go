@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/search"
+	"gorm.io/gorm"
+)
+
+// Bucket sizing for RED metrics. Buckets are whole multiples of the rollup
+// granularity so both data sources line up on the same boundaries.
+const (
+	redTargetBuckets = 120
+	redMaxBuckets    = 1000
+)
+
+// Filter fields that exist as columns on span_rollups. Any other filter, or
+// any tag filter, forces the query onto raw spans.
+var redRollupFields = map[string]bool{
+	"service_name": true,
+	"environment":  true,
+	"operation":    true,
+}
+
+// REDMetricsRequest is a trace search plus the bucket width.
+// Limit caps the number of service/operation series (highest traffic first).
+type REDMetricsRequest struct {
+	search.SearchRequest
+	BucketSeconds int `json:"bucket_seconds,omitempty"` // Multiple of 300; chosen from the range if omitted
+}
+
+// REDBucket is rate, errors and duration for one series in one time bucket
+type REDBucket struct {
+	Start      time.Time `json:"start"`
+	Requests   int64     `json:"requests"`
+	Errors     int64     `json:"errors"`
+	RatePerSec float64   `json:"rate_per_sec"`
+	ErrorRate  float64   `json:"error_rate"`
+	AvgMs      float64   `json:"avg_ms"`
+	P50Ms      float64   `json:"p50_ms"`
+	P95Ms      float64   `json:"p95_ms"`
+	P99Ms      float64   `json:"p99_ms"`
+}
+
+// REDSeries is the metrics for one service/operation over the whole range
+type REDSeries struct {
+	ServiceName string      `json:"service_name"`
+	Operation   string      `json:"operation"`
+	Requests    int64       `json:"requests"`
+	Errors      int64       `json:"errors"`
+	RatePerSec  float64     `json:"rate_per_sec"`
+	ErrorRate   float64     `json:"error_rate"`
+	AvgMs       float64     `json:"avg_ms"`
+	P50Ms       float64     `json:"p50_ms"`
+	P95Ms       float64     `json:"p95_ms"`
+	P99Ms       float64     `json:"p99_ms"`
+	Buckets     []REDBucket `json:"buckets"`
+}
+
+// REDMetricsResponse is the set of series for the requested range
+type REDMetricsResponse struct {
+	From          time.Time   `json:"from"`
+	To            time.Time   `json:"to"`
+	BucketSeconds int         `json:"bucket_seconds"`
+	Source        string      `json:"source"` // "rollup" or "spans"
+	Series        []REDSeries `json:"series"`
+	TotalSeries   int         `json:"total_series"`
+}
+
+// redRow is one (service, operation, bucket) aggregate from either source
+type redRow struct {
+	ServiceName string
+	Operation   string
+	Bucket      time.Time
+	Requests    int64
+	Errors      int64
+	DurationSum int64
+	P50         float64
+	P95         float64
+	P99         float64
+}
+
+// GetREDMetrics returns rate, errors and duration percentiles per service
+// and operation, bucketed over time. Served from span_rollups when the
+// filters allow it, otherwise aggregated from raw spans.
+// POST /api/v1/traces/metrics
+func GetREDMetrics(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		var req REDMetricsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		if err := search.ValidateTracesSearch(&req.SearchRequest); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		// Results are grouped, so row ordering and paging don't apply
+		req.Sort = nil
+		req.Offset = 0
+
+		// Fill in whichever end of the range the client left open
+		to := time.Now().UTC()
+		if req.TimeRange.To != nil {
+			to = req.TimeRange.To.UTC()
+		}
+		from := to.Add(-24 * time.Hour)
+		if req.TimeRange.From != nil {
+			from = req.TimeRange.From.UTC()
+		}
+		// A lone from in the future lands after the defaulted to
+		if !from.Before(to) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "time_range: from must be before to",
+			})
+		}
+		req.TimeRange = &search.TimeRange{From: &from, To: &to}
+
+		bucketSeconds, err := redBucketSeconds(req.BucketSeconds, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		var rows []redRow
+		source := "rollup"
+		if canUseSpanRollup(&req.SearchRequest) {
+			rows, err = queryREDFromRollup(db, &req.SearchRequest, orgID, bucketSeconds)
+		} else {
+			source = "spans"
+			rows, err = queryREDFromSpans(db, &req.SearchRequest, orgID, bucketSeconds)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to compute metrics",
+			})
+		}
+
+		series := buildREDSeries(rows, bucketSeconds, from, to)
+		resp := REDMetricsResponse{
+			From:          from,
+			To:            to,
+			BucketSeconds: bucketSeconds,
+			Source:        source,
+			TotalSeries:   len(series),
+		}
+		if len(series) > req.Limit {
+			series = series[:req.Limit]
+		}
+		resp.Series = series
+		return c.JSON(resp)
+	}
+}
+
+// redBucketSeconds validates a requested bucket width, or picks one that
+// yields about redTargetBuckets buckets over the range
+func redBucketSeconds(requested int, from, to time.Time) (int, error) {
+	span := int(to.Sub(from).Seconds())
+	if requested == 0 {
+		requested = span / redTargetBuckets
+		// Round up to the next rollup boundary
+		requested = (requested + models.SpanRollupBucketSeconds - 1) / models.SpanRollupBucketSeconds * models.SpanRollupBucketSeconds
+		if requested < models.SpanRollupBucketSeconds {
+			requested = models.SpanRollupBucketSeconds
+		}
+		return requested, nil
+	}
+	if requested < 0 || requested%models.SpanRollupBucketSeconds != 0 {
+		return 0, search.ValidationError{Field: "bucket_seconds", Message: "must be a positive multiple of 300"}
+	}
+	if span/requested > redMaxBuckets {
+		return 0, search.ValidationError{Field: "bucket_seconds", Message: "too many buckets for the time range"}
+	}
+	return requested, nil
+}
+
+// canUseSpanRollup reports whether every filter maps onto a rollup column
+func canUseSpanRollup(req *search.SearchRequest) bool {
+	if len(req.Tags) > 0 {
+		return false
+	}
+	for _, f := range req.Filters {
+		if !redRollupFields[f.Field] {
+			return false
+		}
+	}
+	return true
+}
+
+// queryREDFromRollup re-buckets 5-minute rollups into the requested width.
+// Percentiles cannot be merged exactly, so they are averaged weighted by
+// request count (same approximation as the service map).
+func queryREDFromRollup(db *gorm.DB, req *search.SearchRequest, orgID uint, bucketSeconds int) ([]redRow, error) {
+	// Rollup rows are keyed by bucket start; widen the lower bound so the
+	// bucket containing "from" is included
+	from := req.TimeRange.From.Truncate(time.Duration(models.SpanRollupBucketSeconds) * time.Second)
+	rollupReq := *req
+	rollupReq.TimeRange = &search.TimeRange{From: &from, To: req.TimeRange.To}
+
+	builder := search.NewQueryBuilder(db.Model(&models.SpanRollup{}), "bucket_start")
+	var rows []redRow
+	err := builder.Build(&rollupReq, orgID).
+		Select(`service_name, operation,
+			to_timestamp(floor(extract(epoch FROM bucket_start) / ?) * ?) AS bucket,
+			SUM(request_count) AS requests,
+			SUM(error_count) AS errors,
+			SUM(duration_sum_ms) AS duration_sum,
+			SUM(p50_ms * request_count)::float / NULLIF(SUM(request_count), 0) AS p50,
+			SUM(p95_ms * request_count)::float / NULLIF(SUM(request_count), 0) AS p95,
+			SUM(p99_ms * request_count)::float / NULLIF(SUM(request_count), 0) AS p99`,
+			bucketSeconds, bucketSeconds).
+		Group("service_name, operation, bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// queryREDFromSpans aggregates matching spans directly, for filters the
+// rollup can't answer (tags, status, duration, IDs)
+func queryREDFromSpans(db *gorm.DB, req *search.SearchRequest, orgID uint, bucketSeconds int) ([]redRow, error) {
+	builder := search.NewQueryBuilder(db.Model(&models.TraceSpan{}), "start_time")
+	var rows []redRow
+	err := builder.Build(req, orgID).
+		Select(`service_name, operation,
+			to_timestamp(floor(extract(epoch FROM start_time) / ?) * ?) AS bucket,
+			COUNT(*) AS requests,
+			COUNT(*) FILTER (WHERE status = 'ERROR') AS errors,
+			SUM(duration_ms) AS duration_sum,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms) AS p50,
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms) AS p95,
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms) AS p99`,
+			bucketSeconds, bucketSeconds).
+		Group("service_name, operation, bucket").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// buildREDSeries groups bucket rows into one series per service/operation,
+// ordered by traffic. Buckets with no spans are omitted.
+func buildREDSeries(rows []redRow, bucketSeconds int, from, to time.Time) []REDSeries {
+	type seriesKey struct{ service, operation string }
+	type seriesAcc struct {
+		series                 *REDSeries
+		durationSum            int64
+		p50Sum, p95Sum, p99Sum float64
+	}
+
+	accs := make(map[seriesKey]*seriesAcc)
+	for _, r := range rows {
+		key := seriesKey{r.ServiceName, r.Operation}
+		acc, ok := accs[key]
+		if !ok {
+			acc = &seriesAcc{series: &REDSeries{
+				ServiceName: r.ServiceName,
+				Operation:   r.Operation,
+				Buckets:     []REDBucket{},
+			}}
+			accs[key] = acc
+		}
+		acc.series.Buckets = append(acc.series.Buckets, newREDBucket(r, bucketSeconds))
+		acc.series.Requests += r.Requests
+		acc.series.Errors += r.Errors
+		acc.durationSum += r.DurationSum
+		acc.p50Sum += r.P50 * float64(r.Requests)
+		acc.p95Sum += r.P95 * float64(r.Requests)
+		acc.p99Sum += r.P99 * float64(r.Requests)
+	}
+
+	rangeSeconds := to.Sub(from).Seconds()
+	series := make([]REDSeries, 0, len(accs))
+	for _, acc := range accs {
+		s := acc.series
+		if s.Requests > 0 {
+			requests := float64(s.Requests)
+			s.ErrorRate = float64(s.Errors) / requests
+			s.AvgMs = float64(acc.durationSum) / requests
+			s.P50Ms = acc.p50Sum / requests
+			s.P95Ms = acc.p95Sum / requests
+			s.P99Ms = acc.p99Sum / requests
+		}
+		if rangeSeconds > 0 {
+			s.RatePerSec = float64(s.Requests) / rangeSeconds
+		}
+		sort.Slice(s.Buckets, func(i, j int) bool { return s.Buckets[i].Start.Before(s.Buckets[j].Start) })
+		series = append(series, *s)
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Requests != series[j].Requests {
+			return series[i].Requests > series[j].Requests
+		}
+		if series[i].ServiceName != series[j].ServiceName {
+			return series[i].ServiceName < series[j].ServiceName
+		}
+		return series[i].Operation < series[j].Operation
+	})
+	return series
+}
+
+func newREDBucket(r redRow, bucketSeconds int) REDBucket {
+	b := REDBucket{
+		Start:      r.Bucket.UTC(),
+		Requests:   r.Requests,
+		Errors:     r.Errors,
+		RatePerSec: float64(r.Requests) / float64(bucketSeconds),
+		P50Ms:      r.P50,
+		P95Ms:      r.P95,
+		P99Ms:      r.P99,
+	}
+	if r.Requests > 0 {
+		b.ErrorRate = float64(r.Errors) / float64(r.Requests)
+		b.AvgMs = float64(r.DurationSum) / float64(r.Requests)
+	}
+	return b
+}
@@ -12,12 +12,14 @@ import (
 type CheckSearchDTO struct {
     ID              uint       `json:"id"`
     Name            string     `json:"name"`
+    Type            models.CheckType `json:"type"`
     URL             string     `json:"url"`
     ServiceName     string     `json:"service_name,omitempty"`
     Environment     string     `json:"environment,omitempty"`
     Region          string     `json:"region,omitempty"`
     IntervalSeconds int        `json:"interval_seconds"`
     LastStatus      *int       `json:"last_status"`
+    State           models.CheckState `json:"state"`
     LastCheckedAt   *time.Time `json:"last_checked_at"`
     IsActive        bool       `json:"is_active"`
     Tags            models.JSONMap `json:"tags,omitempty"`
@@ -87,12 +89,14 @@ func SearchChecks(db *gorm.DB) fiber.Handler {
             dtos[i] = CheckSearchDTO{
                 ID:              ch.ID,
                 Name:            ch.Name,
+                Type:            ch.Type,
                 URL:             ch.URL,
                 ServiceName:     ch.ServiceName,
                 Environment:     ch.Environment,
                 Region:          ch.Region,
                 IntervalSeconds: ch.IntervalSeconds,
                 LastStatus:      ch.LastStatus,
+                State:           ch.State,
                 LastCheckedAt:   ch.LastCheckedAt,
                 IsActive:        ch.IsActive,
                 Tags:            ch.Tags,
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// ServiceMapNode is a service seen on either end of an edge
+type ServiceMapNode struct {
+	Service      string  `json:"service"`
+	RequestCount int64   `json:"request_count"` // Incoming calls from other services
+	ErrorCount   int64   `json:"error_count"`
+	ErrorRate    float64 `json:"error_rate"`
+}
+
+// ServiceMapEdge aggregates calls from one service to another over the window
+type ServiceMapEdge struct {
+	Source       string  `json:"source"`
+	Target       string  `json:"target"`
+	RequestCount int64   `json:"request_count"`
+	ErrorCount   int64   `json:"error_count"`
+	ErrorRate    float64 `json:"error_rate"`
+	P50Ms        int     `json:"p50_ms"`
+	P95Ms        int     `json:"p95_ms"`
+}
+
+// ServiceMapResponse is the service dependency graph for a time window
+type ServiceMapResponse struct {
+	WindowHours int              `json:"window_hours"`
+	Environment string           `json:"environment,omitempty"`
+	Nodes       []ServiceMapNode `json:"nodes"`
+	Edges       []ServiceMapEdge `json:"edges"`
+	UpdatedAt   *time.Time       `json:"updated_at,omitempty"` // Last materialization of the newest bucket
+}
+
+// GetServiceMap returns the service dependency graph built from materialized edges
+// GET /api/v1/service-map?window_hours=24&environment=production
+func GetServiceMap(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		// Parse window_hours (default 24, max 720)
+		windowHours := 24
+		if windowParam := c.Query("window_hours"); windowParam != "" {
+			if w, err := strconv.Atoi(windowParam); err == nil && w > 0 {
+				windowHours = w
+				if windowHours > 720 {
+					windowHours = 720
+				}
+			}
+		}
+		environment := strings.TrimSpace(c.Query("environment"))
+
+		// Edges are hourly buckets; include the bucket the window starts in
+		cutoff := time.Now().UTC().Add(-time.Duration(windowHours) * time.Hour).Truncate(time.Hour)
+		query := db.Where("org_id = ? AND bucket_start >= ?", orgID, cutoff)
+		if environment != "" {
+			query = query.Where("environment = ?", environment)
+		}
+
+		var rows []models.ServiceEdge
+		if err := query.Find(&rows).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch service map",
+			})
+		}
+
+		return c.JSON(buildServiceMap(rows, windowHours, environment))
+	}
+}
+
+// buildServiceMap merges hourly edge buckets into one edge per service pair.
+// Percentiles cannot be merged exactly, so the per-bucket values are averaged
+// weighted by request count.
+func buildServiceMap(rows []models.ServiceEdge, windowHours int, environment string) ServiceMapResponse {
+	type edgeKey struct{ source, target string }
+	type edgeAcc struct {
+		requests, errors int64
+		p50Sum, p95Sum   float64
+	}
+
+	edges := make(map[edgeKey]*edgeAcc)
+	nodes := make(map[string]*ServiceMapNode)
+	var updatedAt *time.Time
+	for i := range rows {
+		r := rows[i]
+		key := edgeKey{r.SourceService, r.TargetService}
+		acc, ok := edges[key]
+		if !ok {
+			acc = &edgeAcc{}
+			edges[key] = acc
+		}
+		acc.requests += r.RequestCount
+		acc.errors += r.ErrorCount
+		acc.p50Sum += float64(r.P50Ms) * float64(r.RequestCount)
+		acc.p95Sum += float64(r.P95Ms) * float64(r.RequestCount)
+
+		for _, svc := range []string{r.SourceService, r.TargetService} {
+			if _, ok := nodes[svc]; !ok {
+				nodes[svc] = &ServiceMapNode{Service: svc}
+			}
+		}
+		target := nodes[r.TargetService]
+		target.RequestCount += r.RequestCount
+		target.ErrorCount += r.ErrorCount
+
+		if updatedAt == nil || r.UpdatedAt.After(*updatedAt) {
+			updatedAt = &rows[i].UpdatedAt
+		}
+	}
+
+	resp := ServiceMapResponse{
+		WindowHours: windowHours,
+		Environment: environment,
+		Nodes:       make([]ServiceMapNode, 0, len(nodes)),
+		Edges:       make([]ServiceMapEdge, 0, len(edges)),
+		UpdatedAt:   updatedAt,
+	}
+	for key, acc := range edges {
+		edge := ServiceMapEdge{
+			Source:       key.source,
+			Target:       key.target,
+			RequestCount: acc.requests,
+			ErrorCount:   acc.errors,
+		}
+		if acc.requests > 0 {
+			edge.ErrorRate = float64(acc.errors) / float64(acc.requests)
+			edge.P50Ms = int(acc.p50Sum / float64(acc.requests))
+			edge.P95Ms = int(acc.p95Sum / float64(acc.requests))
+		}
+		resp.Edges = append(resp.Edges, edge)
+	}
+	for _, node := range nodes {
+		if node.RequestCount > 0 {
+			node.ErrorRate = float64(node.ErrorCount) / float64(node.RequestCount)
+		}
+		resp.Nodes = append(resp.Nodes, *node)
+	}
+
+	// Stable output for clients diffing successive responses
+	sort.Slice(resp.Nodes, func(i, j int) bool { return resp.Nodes[i].Service < resp.Nodes[j].Service })
+	sort.Slice(resp.Edges, func(i, j int) bool {
+		if resp.Edges[i].Source != resp.Edges[j].Source {
+			return resp.Edges[i].Source < resp.Edges[j].Source
+		}
+		return resp.Edges[i].Target < resp.Edges[j].Target
+	})
+	return resp
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/oFuterman/light-house/internal/billing"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/tracing"
+	"gorm.io/gorm"
+)
+
+// Maximum spans per ingestion request
+const MaxSpansPerRequest = 1000
+
+// Maximum spans loaded when assembling a single trace
+const MaxSpansPerTrace = 10000
+
+// Column size limits from the TraceSpan model. Longer values are truncated,
+// as on the OTLP receiver, so one long value can't fail a batch.
+const (
+	maxSpanServiceNameLen = 255
+	maxSpanEnvironmentLen = 50
+	maxSpanOperationLen   = 512
+)
+
+// IngestSpan represents a single span in the trace ingestion request
+type IngestSpan struct {
+	ServiceName  string                 `json:"service_name"`
+	Environment  string                 `json:"environment,omitempty"`
+	Operation    string                 `json:"operation"`
+	Status       string                 `json:"status,omitempty"`
+	DurationMs   int                    `json:"duration_ms"`
+	StartTime    string                 `json:"start_time,omitempty"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Tags         map[string]interface{} `json:"tags,omitempty"`
+}
+
+// IngestTraceRequest is a batch of spans, possibly from several traces
+type IngestTraceRequest struct {
+	Spans []IngestSpan `json:"spans"`
+}
+
+// IngestTraceResponse is the response for trace ingestion
+type IngestTraceResponse struct {
+	Accepted   int    `json:"accepted"`
+	BytesUsed  int64  `json:"bytes_used"`
+	Warning    string `json:"warning,omitempty"`
+	UpgradeURL string `json:"upgrade_url,omitempty"`
+}
+
+// IngestTraces accepts batched trace spans via API key authentication.
+// Stored bytes count against the same monthly volume as logs.
+// POST /api/v1/traces
+func IngestTraces(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+
+		var req IngestTraceRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body: " + err.Error(),
+			})
+		}
+
+		if len(req.Spans) == 0 {
+			return c.JSON(IngestTraceResponse{
+				Accepted:  0,
+				BytesUsed: 0,
+			})
+		}
+
+		if len(req.Spans) > MaxSpansPerRequest {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":     "batch size exceeds limit",
+				"max_spans": MaxSpansPerRequest,
+				"submitted": len(req.Spans),
+			})
+		}
+
+		// Validate the whole batch before touching usage so a bad span
+		// never consumes quota
+		if err := validateIngestSpans(req.Spans); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		var org models.Organization
+		if err := db.First(&org, orgID).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to load organization",
+			})
+		}
+
+		incomingBytes := calculateSpanBytes(req.Spans)
+
+		currentVolume, err := billing.GetCurrentLogVolume(db, orgID)
+		if err != nil {
+			// Fail closed - reject if we can't verify limits
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to check usage limits",
+			})
+		}
+
+		plan := billing.EffectivePlan(&org)
+		allowed, atWarning, message := billing.CanIngestLogs(plan, currentVolume, incomingBytes)
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":       message,
+				"limit_type":  "log_volume",
+				"upgrade_url": "/settings?tab=billing",
+			})
+		}
+
+		now := time.Now()
+		spans := make([]models.TraceSpan, 0, len(req.Spans))
+		for _, s := range req.Spans {
+			ts := now
+			if s.StartTime != "" {
+				if parsed, err := time.Parse(time.RFC3339Nano, s.StartTime); err == nil {
+					ts = parsed
+				}
+			}
+
+			var tags models.JSONMap
+			if s.Tags != nil {
+				tags = models.JSONMap(s.Tags)
+			}
+
+			spans = append(spans, models.TraceSpan{
+				OrgID:        orgID,
+				ServiceName:  strings.TrimSpace(s.ServiceName),
+				Environment:  strings.TrimSpace(s.Environment),
+				Operation:    strings.TrimSpace(s.Operation),
+				Status:       normalizeSpanStatus(s.Status),
+				DurationMs:   s.DurationMs,
+				StartTime:    ts,
+				TraceID:      strings.ToLower(strings.TrimSpace(s.TraceID)),
+				SpanID:       strings.ToLower(strings.TrimSpace(s.SpanID)),
+				ParentSpanID: strings.ToLower(strings.TrimSpace(s.ParentSpanID)),
+				Tags:         tags,
+			})
+		}
+
+		if err := db.CreateInBatches(spans, 100).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to store spans",
+			})
+		}
+
+		// Increment volume (best-effort, same as log ingestion)
+		billing.IncrementLogVolume(db, orgID, incomingBytes)
+
+		response := IngestTraceResponse{
+			Accepted:  len(spans),
+			BytesUsed: incomingBytes,
+		}
+		if atWarning {
+			response.Warning = message
+			response.UpgradeURL = "/settings?tab=billing"
+			c.Set("X-Usage-Warning", message)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(response)
+	}
+}
+
+// TraceResponse is the assembled waterfall for a single trace
+type TraceResponse struct {
+	tracing.Waterfall
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// GetTrace returns every span of a trace assembled into a parent/child tree
+// GET /api/v1/traces/:trace_id
+func GetTrace(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Locals("orgID").(uint)
+		traceID := strings.ToLower(strings.TrimSpace(c.Params("trace_id")))
+		if !isValidTraceID(traceID) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid trace ID",
+			})
+		}
+
+		// (org_id, trace_id) equality plus start_time ordering is served by
+		// idx_trace_spans_org_trace_start
+		var spans []models.TraceSpan
+		if err := db.Where("org_id = ? AND trace_id = ?", orgID, traceID).
+			Order("start_time ASC").
+			Limit(MaxSpansPerTrace + 1).
+			Find(&spans).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to fetch trace",
+			})
+		}
+		if len(spans) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "trace not found",
+			})
+		}
+
+		truncated := len(spans) > MaxSpansPerTrace
+		if truncated {
+			spans = spans[:MaxSpansPerTrace]
+		}
+
+		return c.JSON(TraceResponse{
+			Waterfall: tracing.BuildWaterfall(traceID, spans),
+			Truncated: truncated,
+		})
+	}
+}
+
+// validateIngestSpans checks required fields, ID formats and parent links,
+// and truncates names to their column sizes in place. A parent may live in
+// an earlier or later batch, so only parents that appear in this batch are
+// cross-checked against their trace.
+func validateIngestSpans(spans []IngestSpan) error {
+	// span_id -> trace_id for spans in this batch
+	seen := make(map[string]string, len(spans))
+	for i := range spans {
+		s := &spans[i]
+		field := func(name string) string { return fmt.Sprintf("spans[%d].%s", i, name) }
+
+		s.ServiceName = truncate(strings.TrimSpace(s.ServiceName), maxSpanServiceNameLen)
+		s.Environment = truncate(strings.TrimSpace(s.Environment), maxSpanEnvironmentLen)
+		s.Operation = truncate(strings.TrimSpace(s.Operation), maxSpanOperationLen)
+
+		if strings.TrimSpace(s.ServiceName) == "" {
+			return fmt.Errorf("%s: is required", field("service_name"))
+		}
+		if strings.TrimSpace(s.Operation) == "" {
+			return fmt.Errorf("%s: is required", field("operation"))
+		}
+		if s.DurationMs < 0 {
+			return fmt.Errorf("%s: must not be negative", field("duration_ms"))
+		}
+		if s.StartTime != "" {
+			if _, err := time.Parse(time.RFC3339Nano, s.StartTime); err != nil {
+				return fmt.Errorf("%s: must be an RFC3339 timestamp", field("start_time"))
+			}
+		}
+
+		traceID := strings.ToLower(strings.TrimSpace(s.TraceID))
+		if !isValidTraceID(traceID) {
+			return fmt.Errorf("%s: must be 16 or 32 hex characters and not all zeros", field("trace_id"))
+		}
+		spanID := strings.ToLower(strings.TrimSpace(s.SpanID))
+		if !isValidSpanID(spanID) {
+			return fmt.Errorf("%s: must be 16 hex characters and not all zeros", field("span_id"))
+		}
+		if prev, ok := seen[spanID]; ok && prev == traceID {
+			return fmt.Errorf("%s: duplicate span %s in trace %s", field("span_id"), spanID, traceID)
+		}
+		seen[spanID] = traceID
+	}
+
+	for i, s := range spans {
+		parentID := strings.ToLower(strings.TrimSpace(s.ParentSpanID))
+		if parentID == "" {
+			continue
+		}
+		field := fmt.Sprintf("spans[%d].parent_span_id", i)
+		if !isValidSpanID(parentID) {
+			return fmt.Errorf("%s: must be 16 hex characters and not all zeros", field)
+		}
+		if parentID == strings.ToLower(strings.TrimSpace(s.SpanID)) {
+			return fmt.Errorf("%s: span cannot be its own parent", field)
+		}
+		if parentTrace, ok := seen[parentID]; ok && parentTrace != strings.ToLower(strings.TrimSpace(s.TraceID)) {
+			return fmt.Errorf("%s: parent span belongs to a different trace", field)
+		}
+	}
+	return nil
+}
+
+// isValidTraceID accepts 64-bit or 128-bit lowercase hex trace IDs
+func isValidTraceID(id string) bool {
+	return (len(id) == 16 || len(id) == 32) && isNonZeroHex(id)
+}
+
+// isValidSpanID accepts 64-bit lowercase hex span IDs
+func isValidSpanID(id string) bool {
+	return len(id) == 16 && isNonZeroHex(id)
+}
+
+// isNonZeroHex returns true if s is lowercase hex with at least one non-zero digit
+func isNonZeroHex(s string) bool {
+	nonZero := false
+	for _, c := range s {
+		switch {
+		case c == '0':
+		case (c >= '1' && c <= '9') || (c >= 'a' && c <= 'f'):
+			nonZero = true
+		default:
+			return false
+		}
+	}
+	return nonZero
+}
+
+// normalizeSpanStatus maps a client status onto the stored status constants
+func normalizeSpanStatus(status string) string {
+	switch strings.ToUpper(strings.TrimSpace(status)) {
+	case models.SpanStatusOK:
+		return models.SpanStatusOK
+	case models.SpanStatusError:
+		return models.SpanStatusError
+	default:
+		return models.SpanStatusUnknown
+	}
+}
+
+// calculateSpanBytes estimates the size of spans in bytes
+func calculateSpanBytes(spans []IngestSpan) int64 {
+	data, err := json.Marshal(spans)
+	if err != nil {
+		// Fallback: estimate 500 bytes per span
+		return int64(len(spans) * 500)
+	}
+	return int64(len(data))
+}
@@ -297,3 +297,34 @@ func APIKeyAuthWithScope(db *gorm.DB, requiredScopes ...models.APIKeyScope) fibe
 		return c.Next()
 	}
 }
+
+// AgentAuth authenticates a probe agent by the token in its
+// "Authorization: Bearer lha_..." header
+func AgentAuth(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, models.AgentTokenPrefix) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "missing or invalid agent token",
+			})
+		}
+
+		var agent models.ProbeAgent
+		if err := db.Where("token_hash = ?", models.HashAgentToken(token)).First(&agent).Error; err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid agent token",
+			})
+		}
+
+		// Update last seen timestamp
+		now := time.Now()
+		db.Model(&agent).Update("last_seen_at", now)
+		agent.LastSeenAt = &now
+
+		// Set context
+		c.Locals("orgID", agent.OrgID)
+		c.Locals("agent", &agent)
+
+		return c.Next()
+	}
+}
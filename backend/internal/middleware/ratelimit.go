@@ -188,6 +188,18 @@ func RateLimitValidation() fiber.Handler {
 	})
 }
 
+// RateLimitHeartbeat limits pings per heartbeat token, so a runaway job
+// can't flood a check's results
+func RateLimitHeartbeat() fiber.Handler {
+	return RateLimit(RateLimitConfig{
+		Max:    60,          // 60 pings
+		Window: time.Minute, // per minute
+		KeyFunc: func(c *fiber.Ctx) string {
+			return "heartbeat:" + c.Params("token")
+		},
+	})
+}
+
 // itoa converts int to string without importing strconv
 func itoa(i int) string {
 	if i == 0 {
@@ -9,6 +9,11 @@ type AlertType string
 const (
     AlertTypeDown     AlertType = "DOWN"
     AlertTypeRecovery AlertType = "RECOVERY"
+    // Up but over the check's latency threshold
+    AlertTypeDegraded AlertType = "DEGRADED"
+    // Certificate expiry crossed one of the check's CertAlertDays thresholds.
+    // Independent of the up/down state and its suppression window.
+    AlertTypeCertExpiring AlertType = "CERT_EXPIRING"
 )
 
 type Alert struct {
@@ -19,6 +24,7 @@ type Alert struct {
     AlertType    AlertType `gorm:"not null;size:20;index" json:"alert_type"`
     StatusCode   int       `json:"status_code"`
     ErrorMessage string    `gorm:"size:1024" json:"error_message,omitempty"`
+    IncidentID   *uint     `gorm:"index" json:"incident_id,omitempty"` // Nil for alerts outside incidents (certificate expiry)
     // Relations
     Organization Organization `gorm:"foreignKey:OrgID" json:"organization,omitempty"`
     Check        Check        `gorm:"foreignKey:CheckID" json:"check,omitempty"`
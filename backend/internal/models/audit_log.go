@@ -35,6 +35,27 @@ const (
 	AuditActionCheckUpdated AuditAction = "check.updated"
 	AuditActionCheckDeleted AuditAction = "check.deleted"
 
+	// Maintenance window actions
+	AuditActionMaintenanceCreated AuditAction = "maintenance.created"
+	AuditActionMaintenanceUpdated AuditAction = "maintenance.updated"
+	AuditActionMaintenanceDeleted AuditAction = "maintenance.deleted"
+
+	// Escalation policy actions
+	AuditActionEscalationCreated AuditAction = "escalation_policy.created"
+	AuditActionEscalationUpdated AuditAction = "escalation_policy.updated"
+	AuditActionEscalationDeleted AuditAction = "escalation_policy.deleted"
+
+	// On-call schedule actions
+	AuditActionOnCallCreated         AuditAction = "on_call.created"
+	AuditActionOnCallUpdated         AuditAction = "on_call.updated"
+	AuditActionOnCallDeleted         AuditAction = "on_call.deleted"
+	AuditActionOnCallOverrideCreated AuditAction = "on_call.override_created"
+	AuditActionOnCallOverrideDeleted AuditAction = "on_call.override_deleted"
+
+	// Probe agent actions
+	AuditActionAgentCreated AuditAction = "agent.created"
+	AuditActionAgentDeleted AuditAction = "agent.deleted"
+
 	// Settings actions
 	AuditActionSettingsUpdated AuditAction = "settings.updated"
 
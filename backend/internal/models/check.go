@@ -1,10 +1,92 @@
 package models
 
 import (
+    "crypto/rand"
+    "encoding/hex"
     "time"
+
+    "github.com/lib/pq"
     "gorm.io/gorm"
 )
 
+// CheckType selects how a check probes its target
+type CheckType string
+
+const (
+    CheckTypeHTTP CheckType = "http"
+    CheckTypeTCP  CheckType = "tcp"
+    CheckTypeDNS  CheckType = "dns"
+    // A chain of HTTP requests sharing extracted variables
+    CheckTypeMultiStep CheckType = "multistep"
+    // Pushed to by the monitored job instead of probed
+    CheckTypeHeartbeat CheckType = "heartbeat"
+)
+
+// IsValid checks if the check type is a known value
+func (t CheckType) IsValid() bool {
+    switch t {
+    case CheckTypeHTTP, CheckTypeTCP, CheckTypeDNS, CheckTypeMultiStep, CheckTypeHeartbeat:
+        return true
+    }
+    return false
+}
+
+// CheckRuntimeColumns are written by the workers as checks run, never by
+// edits to a check's configuration. Saving a check loaded earlier must
+// leave them out, or it rolls back whatever the workers wrote meanwhile.
+var CheckRuntimeColumns = []string{
+    "state", "last_status", "consecutive_failures", "last_checked_at", "last_alert_at",
+    "next_run_at", "lease_expires_at", "leased_by",
+    "last_ping_at", "heartbeat_started_at",
+    "cert_expires_at", "cert_issuer", "cert_subject", "cert_sans", "cert_hostname_valid",
+    "cert_chain_valid", "cert_error", "cert_checked_at", "cert_alerted_days",
+}
+
+// CheckState is whether a check's latest result passed; empty until it first runs
+type CheckState string
+
+const (
+    CheckStateUp       CheckState = "UP"
+    CheckStateDegraded CheckState = "DEGRADED" // Up, but slower than the check's latency threshold
+    CheckStateDown     CheckState = "DOWN"
+)
+
+// DegradedMode selects how latency is compared to DegradedThresholdMs
+type DegradedMode string
+
+const (
+    DegradedConsecutive DegradedMode = "consecutive" // Each of the last DegradedRuns runs is over the threshold
+    DegradedP95         DegradedMode = "p95"         // The p95 of the last DegradedRuns runs is over the threshold
+)
+
+// CheckAuthType selects how an HTTP check authenticates
+type CheckAuthType string
+
+const (
+    CheckAuthNone   CheckAuthType = ""
+    CheckAuthBasic  CheckAuthType = "basic"
+    CheckAuthBearer CheckAuthType = "bearer"
+)
+
+// Defaults for HTTP request options
+const (
+    DefaultCheckTimeoutSeconds = 30
+    DefaultCheckMaxRedirects   = 10
+)
+
+// GenerateHeartbeatToken creates the secret part of a heartbeat ping URL
+func GenerateHeartbeatToken() (string, error) {
+    bytes := make([]byte, 32)
+    if _, err := rand.Read(bytes); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(bytes), nil
+}
+
+// DefaultCertAlertDays are the CERT_EXPIRING thresholds used when a check
+// doesn't set its own
+var DefaultCertAlertDays = []int64{30, 14, 7}
+
 type Check struct {
     ID        uint           `gorm:"primarykey" json:"id"`
     CreatedAt time.Time      `json:"created_at"`
@@ -12,12 +94,95 @@ type Check struct {
     DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
     OrgID           uint       `gorm:"not null;index" json:"org_id"`
     Name            string     `gorm:"not null;size:255" json:"name"`
-    URL             string     `gorm:"not null;size:2048" json:"url"`
+    Type            CheckType  `gorm:"not null;size:20;default:'http'" json:"type"`
+    URL             string     `gorm:"not null;size:2048" json:"url"` // Target: a URL for http, host:port for tcp
     IntervalSeconds int        `gorm:"not null;default:60" json:"interval_seconds"`
     LastStatus      *int       `json:"last_status"`
+    State           CheckState `gorm:"size:20" json:"state"`
+    // Confirmation: a failure only turns the check DOWN after
+    // FailureThreshold consecutive failures or, when FailureWindow is set,
+    // FailureThreshold failures among the last FailureWindow runs
+    FailureThreshold    int `gorm:"not null;default:1" json:"failure_threshold"`
+    FailureWindow       int `gorm:"not null;default:0" json:"failure_window"`
+    Retries             int `gorm:"not null;default:0" json:"retries"` // Immediate re-probes before a failure is recorded
+    ConsecutiveFailures int `gorm:"not null;default:0" json:"consecutive_failures"`
+    // Latency: a successful check is DEGRADED rather than UP when its
+    // response time is over DegradedThresholdMs (0 disables) per DegradedMode
+    DegradedThresholdMs int64        `gorm:"not null;default:0" json:"degraded_threshold_ms"`
+    DegradedMode        DegradedMode `gorm:"size:20" json:"degraded_mode,omitempty"`
+    DegradedRuns        int          `gorm:"not null;default:1" json:"degraded_runs"`
     LastCheckedAt   *time.Time `json:"last_checked_at"`
+    // Scheduling: when the check is next due (nil = now), and the lease a
+    // scheduler instance holds while running it. An expired lease means
+    // its holder died and the check can be claimed again.
+    NextRunAt      *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+    LeaseExpiresAt *time.Time `json:"-"`
+    LeasedBy       string     `gorm:"size:128" json:"-"`
     LastAlertAt     *time.Time `json:"last_alert_at"`
     IsActive        bool       `gorm:"default:true" json:"is_active"`
+    // TCP options: optional payload sent after connecting, and a substring
+    // the response must contain
+    TCPSend   string `gorm:"size:1024" json:"tcp_send,omitempty"`
+    TCPExpect string `gorm:"size:1024" json:"tcp_expect,omitempty"`
+    // DNS options: record type to query, resolver (host:port, empty = system
+    // resolver) and values that must all appear in the answer
+    DNSRecordType string         `gorm:"size:10" json:"dns_record_type,omitempty"`
+    DNSResolver   string         `gorm:"size:255" json:"dns_resolver,omitempty"`
+    DNSExpected   pq.StringArray `gorm:"type:text[]" json:"dns_expected,omitempty"`
+    // HTTP request options. Auth secrets and secret header values are
+    // encrypted with the secrets package and never serialized.
+    HTTPMethod        string         `gorm:"size:10" json:"http_method,omitempty"` // Empty means GET
+    HTTPHeaders       StringMap      `gorm:"type:jsonb" json:"http_headers,omitempty"`
+    HTTPBody          string         `gorm:"type:text" json:"http_body,omitempty"`
+    AuthType          CheckAuthType  `gorm:"size:20" json:"auth_type,omitempty"`
+    AuthUsername      string         `gorm:"size:255" json:"auth_username,omitempty"`
+    AuthSecret        string         `gorm:"type:text" json:"-"` // Basic password or bearer token
+    SecretHeaders     string         `gorm:"type:text" json:"-"` // Encrypted JSON object of header values
+    SecretHeaderNames pq.StringArray `gorm:"type:text[]" json:"secret_header_names,omitempty"`
+    TimeoutSeconds    int            `gorm:"not null;default:30" json:"timeout_seconds"`
+    MaxRedirects      *int           `json:"max_redirects"` // nil follows up to 10, 0 doesn't follow
+    // Multi-step options: the steps in order, and encrypted variables
+    // ({{name}} in any step) for credentials the steps send
+    Steps               CheckSteps     `gorm:"type:jsonb" json:"steps,omitempty"`
+    SecretVariables     string         `gorm:"type:text" json:"-"` // Encrypted JSON object
+    SecretVariableNames pq.StringArray `gorm:"type:text[]" json:"secret_variable_names,omitempty"`
+    // Heartbeat options: the secret in the ping URL, how long past the
+    // interval a ping may be late, the last successful ping and the start
+    // of a run that hasn't reported back yet
+    HeartbeatToken     *string    `gorm:"size:64;uniqueIndex" json:"heartbeat_token,omitempty"`
+    GraceSeconds       int        `gorm:"not null;default:0" json:"grace_seconds"`
+    LastPingAt         *time.Time `json:"last_ping_at,omitempty"`
+    HeartbeatStartedAt *time.Time `json:"heartbeat_started_at,omitempty"`
+    // HTTP assertions, all of which must pass. A status_code assertion
+    // replaces the default 2xx rule.
+    Assertions CheckAssertions `gorm:"type:jsonb" json:"assertions,omitempty"`
+    // TLS certificate of an https target, refreshed on every run.
+    // CertExpiresAt is the earliest expiry anywhere in the peer chain.
+    CertExpiresAt     *time.Time     `json:"cert_expires_at,omitempty"`
+    CertIssuer        string         `gorm:"size:512" json:"cert_issuer,omitempty"`
+    CertSubject       string         `gorm:"size:512" json:"cert_subject,omitempty"`
+    CertSANs          pq.StringArray `gorm:"type:text[]" json:"cert_sans,omitempty"`
+    CertHostnameValid *bool          `json:"cert_hostname_valid,omitempty"`
+    CertChainValid    *bool          `json:"cert_chain_valid,omitempty"`
+    CertError         string         `gorm:"size:512" json:"cert_error,omitempty"`
+    CertCheckedAt     *time.Time     `json:"cert_checked_at,omitempty"`
+    // Days-before-expiry thresholds for CERT_EXPIRING alerts; nil uses
+    // DefaultCertAlertDays, an empty list disables them
+    CertAlertDays pq.Int64Array `gorm:"type:bigint[]" json:"cert_alert_days"`
+    // Smallest threshold already alerted for the current certificate
+    CertAlertedDays *int `json:"-"`
+    // IDs of checks this one depends on. While any of them, directly or
+    // transitively, is DOWN, this check's failures are recorded as blocked
+    // and don't alert.
+    DependsOn pq.Int64Array `gorm:"type:bigint[]" json:"depends_on,omitempty"`
+    // Who hears about this check's incidents and when; nil notifies the
+    // org's notification settings and repeats until acknowledged
+    EscalationPolicyID *uint `gorm:"index" json:"escalation_policy_id,omitempty"`
+    // Remote regions: probe agents in each listed region run the check
+    // instead of the server. A run is a failure once RegionQuorum regions
+    // (0 = a majority) report failing.
+    Regions      pq.StringArray `gorm:"type:text[]" json:"regions,omitempty"`
+    RegionQuorum int            `gorm:"not null;default:0" json:"region_quorum,omitempty"`
     // Observability fields
     ServiceName string  `gorm:"size:255;index" json:"service_name,omitempty"`
     Environment string  `gorm:"size:50;index" json:"environment,omitempty"`
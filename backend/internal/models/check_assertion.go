@@ -0,0 +1,52 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// AssertionType selects what an HTTP check assertion inspects
+type AssertionType string
+
+const (
+	AssertionStatusCode      AssertionType = "status_code"       // StatusCodes lists the accepted codes
+	AssertionBodyContains    AssertionType = "body_contains"     // Value must appear in the body
+	AssertionBodyNotContains AssertionType = "body_not_contains" // Value must not appear in the body
+	AssertionBodyRegex       AssertionType = "body_regex"        // Value is a regexp the body must match
+	AssertionJSONPathEquals  AssertionType = "json_path_equals"  // Target is a JSONPath whose value must equal Value
+	AssertionJSONPathExists  AssertionType = "json_path_exists"  // Target is a JSONPath that must resolve
+	AssertionHeaderEquals    AssertionType = "header_equals"     // Target is a header name whose value must equal Value
+	AssertionResponseTime    AssertionType = "response_time"     // MaxMs is the exclusive upper bound
+)
+
+// Assertion is one condition an HTTP check response must satisfy
+type Assertion struct {
+	Type        AssertionType `json:"type"`
+	Target      string        `json:"target,omitempty"`
+	Value       string        `json:"value,omitempty"`
+	StatusCodes []int         `json:"status_codes,omitempty"`
+	MaxMs       int64         `json:"max_ms,omitempty"`
+}
+
+// CheckAssertions is a JSONB list of assertions
+type CheckAssertions []Assertion
+
+func (a CheckAssertions) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+func (a *CheckAssertions) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, a)
+}
@@ -7,11 +7,19 @@ import (
 type CheckResult struct {
     ID        uint      `gorm:"primarykey" json:"id"`
     CreatedAt time.Time `json:"created_at" gorm:"index:idx_check_results_check_created,priority:2,sort:desc"`
-    CheckID        uint   `gorm:"not null;index:idx_check_results_check_created,priority:1" json:"check_id"`
-    StatusCode     int    `gorm:"index" json:"status_code"`
-    ResponseTimeMs int64  `json:"response_time_ms"`
-    Success        bool   `json:"success"`
-    ErrorMessage   string `gorm:"size:1024" json:"error_message,omitempty"`
+    CheckID        uint       `gorm:"not null;index:idx_check_results_check_created,priority:1" json:"check_id"`
+    StatusCode     int        `gorm:"index" json:"status_code"`
+    ResponseTimeMs int64      `json:"response_time_ms"`
+    ConnectTimeMs  *int64     `json:"connect_time_ms,omitempty"` // TCP handshake latency, when measured
+    Success        bool       `json:"success"`
+    State          CheckState `gorm:"size:20" json:"state,omitempty"` // Check state after this result
+    ErrorMessage   string     `gorm:"size:1024" json:"error_message,omitempty"`
+    InMaintenance  bool       `gorm:"not null;default:false" json:"in_maintenance,omitempty"` // Stored during a maintenance window; excluded from uptime
+    BlockedBy      *uint      `json:"blocked_by,omitempty"`                                   // DOWN dependency a failure was attributed to
+    // Multi-step checks: timing and outcome of each step that ran, and the
+    // 1-based number of the step that failed
+    StepResults StepResults `gorm:"type:jsonb" json:"step_results,omitempty"`
+    FailedStep  *int        `json:"failed_step,omitempty"`
     // Observability fields (denormalized for efficient querying)
     OrgID       uint    `gorm:"index" json:"org_id"`
     ServiceName string  `gorm:"size:255;index" json:"service_name,omitempty"`
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+)
+
+// StepVariableRef matches a {{name}} variable reference in a step
+var StepVariableRef = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// ExtractorType selects where a step extractor reads its value from
+type ExtractorType string
+
+const (
+	ExtractJSONPath ExtractorType = "json_path" // Path is a JSONPath into the body
+	ExtractHeader   ExtractorType = "header"    // Path is a response header name
+	ExtractRegex    ExtractorType = "regex"     // Path is a regexp; its first group (or whole match) is used
+)
+
+// StepExtractor saves part of a step's response as a variable for later steps
+type StepExtractor struct {
+	Name string        `json:"name"`
+	Type ExtractorType `json:"type"`
+	Path string        `json:"path"`
+}
+
+// CheckStep is one request of a multi-step check. URL, headers and body may
+// reference variables as {{name}}.
+type CheckStep struct {
+	Name       string            `json:"name"`
+	Method     string            `json:"method,omitempty"` // Empty means GET
+	URL        string            `json:"url"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Assertions []Assertion       `json:"assertions,omitempty"`
+	Extract    []StepExtractor   `json:"extract,omitempty"`
+}
+
+// CheckSteps is a JSONB list of steps
+type CheckSteps []CheckStep
+
+func (s CheckSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *CheckSteps) Scan(value interface{}) error {
+	if value == nil {
+		*s = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// StepResult is how one step of a multi-step run went
+type StepResult struct {
+	Name           string `json:"name"`
+	StatusCode     int    `json:"status_code"`
+	ResponseTimeMs int64  `json:"response_time_ms"`
+	Success        bool   `json:"success"`
+	Error          string `json:"error,omitempty"`
+}
+
+// StepResults is a JSONB list of step results
+type StepResults []StepResult
+
+func (r StepResults) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+func (r *StepResults) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, r)
+}
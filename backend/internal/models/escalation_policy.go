@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// EscalationTargetType is who an escalation level notifies
+type EscalationTargetType string
+
+const (
+	EscalationTargetEmail    EscalationTargetType = "email"    // Address
+	EscalationTargetWebhook  EscalationTargetType = "webhook"  // Address is the URL
+	EscalationTargetUser     EscalationTargetType = "user"     // UserID's email
+	EscalationTargetAdmins   EscalationTargetType = "admins"   // Every owner and admin of the org
+	EscalationTargetDefaults EscalationTargetType = "defaults" // The org's notification settings
+	EscalationTargetOnCall   EscalationTargetType = "on_call"  // Whoever is on call for ScheduleID
+)
+
+// EscalationTarget is one recipient of an escalation level
+type EscalationTarget struct {
+	Type       EscalationTargetType `json:"type"`
+	Address    string               `json:"address,omitempty"`
+	UserID     *uint                `json:"user_id,omitempty"`
+	ScheduleID *uint                `json:"schedule_id,omitempty"`
+}
+
+// EscalationLevel notifies its targets DelayMinutes after the previous
+// level did, or after the incident triggered for the first level
+type EscalationLevel struct {
+	DelayMinutes int                `json:"delay_minutes"`
+	Targets      []EscalationTarget `json:"targets"`
+}
+
+type EscalationLevels []EscalationLevel
+
+func (l EscalationLevels) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *EscalationLevels) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// EscalationPolicy decides who hears about a check's incidents, and when,
+// until someone acknowledges. Levels notify in order; after the last one,
+// the policy starts over from the first level every RepeatIntervalMinutes
+// (0 never repeats), at most MaxRepeats times (0 until acknowledged).
+type EscalationPolicy struct {
+	ID                    uint             `gorm:"primarykey" json:"id"`
+	CreatedAt             time.Time        `json:"created_at"`
+	UpdatedAt             time.Time        `json:"updated_at"`
+	OrgID                 uint             `gorm:"not null;index" json:"org_id"`
+	Name                  string           `gorm:"size:255;not null" json:"name"`
+	Levels                EscalationLevels `gorm:"type:jsonb;not null" json:"levels"`
+	RepeatIntervalMinutes int              `gorm:"not null;default:0" json:"repeat_interval_minutes"`
+	MaxRepeats            int              `gorm:"not null;default:0" json:"max_repeats"`
+	CreatedByID           *uint            `json:"created_by_id,omitempty"`
+}
+
+// NextEscalation is when an incident that just notified level (counting
+// from 0) should notify again, or nil when the policy is exhausted after
+// repeats completed cycles
+func (p EscalationPolicy) NextEscalation(level, repeats int, now time.Time) *time.Time {
+	var next time.Time
+	switch {
+	case level+1 < len(p.Levels):
+		next = now.Add(time.Duration(p.Levels[level+1].DelayMinutes) * time.Minute)
+	case p.RepeatIntervalMinutes > 0 && (p.MaxRepeats == 0 || repeats < p.MaxRepeats):
+		next = now.Add(time.Duration(p.RepeatIntervalMinutes) * time.Minute)
+	default:
+		return nil
+	}
+	return &next
+}
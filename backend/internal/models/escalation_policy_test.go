@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEscalationPolicyNextEscalation(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	levels := EscalationLevels{
+		{DelayMinutes: 0},
+		{DelayMinutes: 15},
+		{DelayMinutes: 30},
+	}
+	tests := []struct {
+		name    string
+		policy  EscalationPolicy
+		level   int
+		repeats int
+		want    time.Duration // -1 for no further escalation
+	}{
+		{"first level waits for the second's delay", EscalationPolicy{Levels: levels}, 0, 0, 15 * time.Minute},
+		{"middle level waits for the next's delay", EscalationPolicy{Levels: levels}, 1, 0, 30 * time.Minute},
+		{"last level without repeats stops", EscalationPolicy{Levels: levels}, 2, 0, -1},
+		{"last level repeats", EscalationPolicy{Levels: levels, RepeatIntervalMinutes: 60}, 2, 0, time.Hour},
+		{"repeats until acknowledged", EscalationPolicy{Levels: levels, RepeatIntervalMinutes: 60}, 2, 50, time.Hour},
+		{"repeats below the cap", EscalationPolicy{Levels: levels, RepeatIntervalMinutes: 60, MaxRepeats: 2}, 2, 1, time.Hour},
+		{"max repeats reached", EscalationPolicy{Levels: levels, RepeatIntervalMinutes: 60, MaxRepeats: 2}, 2, 2, -1},
+		{"cap only applies after the last level", EscalationPolicy{Levels: levels, RepeatIntervalMinutes: 60, MaxRepeats: 2}, 0, 2, 15 * time.Minute},
+		{"single level repeats", EscalationPolicy{Levels: levels[:1], RepeatIntervalMinutes: 10}, 0, 0, 10 * time.Minute},
+		// The policy was shortened while the incident sat at level 3
+		{"past the end of a shortened policy stops", EscalationPolicy{Levels: levels[:2]}, 2, 0, -1},
+		{"past the end of a shortened policy repeats", EscalationPolicy{Levels: levels[:2], RepeatIntervalMinutes: 60}, 2, 0, time.Hour},
+		{"past the end after max repeats stops", EscalationPolicy{Levels: levels[:2], RepeatIntervalMinutes: 60, MaxRepeats: 1}, 4, 1, -1},
+	}
+	for _, tt := range tests {
+		got := tt.policy.NextEscalation(tt.level, tt.repeats, now)
+		switch {
+		case tt.want < 0 && got != nil:
+			t.Errorf("%s: got %v, want no further escalation", tt.name, *got)
+		case tt.want >= 0 && got == nil:
+			t.Errorf("%s: got no further escalation, want %v", tt.name, now.Add(tt.want))
+		case tt.want >= 0 && !got.Equal(now.Add(tt.want)):
+			t.Errorf("%s: got %v, want %v", tt.name, *got, now.Add(tt.want))
+		}
+	}
+}
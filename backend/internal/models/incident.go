@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+)
+
+// IncidentState is where an incident is in its lifecycle
+type IncidentState string
+
+const (
+	IncidentStateTriggered    IncidentState = "triggered"
+	IncidentStateAcknowledged IncidentState = "acknowledged" // Someone is on it; repeats stop notifying
+	IncidentStateResolved     IncidentState = "resolved"
+)
+
+// Incident groups the alerts of one outage of a check: the DOWN or DEGRADED
+// alert that opened it, any alerts raised while it stayed open, and the
+// RECOVERY that resolved it. A check has at most one open incident.
+type Incident struct {
+	ID               uint          `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	OrgID            uint          `gorm:"not null;index:idx_incidents_org_state" json:"org_id"`
+	CheckID          uint          `gorm:"not null;index" json:"check_id"`
+	State            IncidentState `gorm:"size:20;not null;index:idx_incidents_org_state" json:"state"`
+	Title            string        `gorm:"size:255;not null" json:"title"`
+	TriggeredAt      time.Time     `gorm:"not null" json:"triggered_at"`
+	AcknowledgedAt   *time.Time    `json:"acknowledged_at,omitempty"`
+	AcknowledgedByID *uint         `json:"acknowledged_by_id,omitempty"`
+	ResolvedAt       *time.Time    `json:"resolved_at,omitempty"`
+	ResolvedByID     *uint         `json:"resolved_by_id,omitempty"` // Nil when the check recovered on its own
+	AssigneeID       *uint         `json:"assignee_id,omitempty"`
+	AlertCount       int           `gorm:"not null;default:0" json:"alert_count"`
+	LastNotifiedAt   time.Time     `json:"last_notified_at"` // Drives repeat notifications while triggered
+	// Escalation, for checks with a policy: levels notified in the current
+	// cycle, cycles completed, and when the next level is due (nil once the
+	// policy is exhausted). Only triggered incidents escalate.
+	EscalationPolicyID *uint      `json:"escalation_policy_id,omitempty"`
+	EscalationLevel    int        `gorm:"not null;default:0" json:"escalation_level"`
+	EscalationRepeats  int        `gorm:"not null;default:0" json:"escalation_repeats"`
+	NextEscalationAt   *time.Time `gorm:"index" json:"next_escalation_at,omitempty"`
+	// Relations
+	Check Check `gorm:"foreignKey:CheckID" json:"-"`
+}
+
+// IsOpen reports whether the incident is not yet resolved
+func (i Incident) IsOpen() bool {
+	return i.State != IncidentStateResolved
+}
+
+// TimeToAcknowledge is the incident's contribution to MTTA, if acknowledged
+func (i Incident) TimeToAcknowledge() *time.Duration {
+	if i.AcknowledgedAt == nil {
+		return nil
+	}
+	d := i.AcknowledgedAt.Sub(i.TriggeredAt)
+	return &d
+}
+
+// TimeToResolve is the incident's contribution to MTTR, if resolved
+func (i Incident) TimeToResolve() *time.Duration {
+	if i.ResolvedAt == nil {
+		return nil
+	}
+	d := i.ResolvedAt.Sub(i.TriggeredAt)
+	return &d
+}
+
+// IncidentEventType is an entry in an incident's timeline
+type IncidentEventType string
+
+const (
+	IncidentEventTriggered    IncidentEventType = "triggered"
+	IncidentEventAlert        IncidentEventType = "alert" // Another alert while open
+	IncidentEventRenotified   IncidentEventType = "renotified"
+	IncidentEventEscalated    IncidentEventType = "escalated"
+	IncidentEventAcknowledged IncidentEventType = "acknowledged"
+	IncidentEventAssigned     IncidentEventType = "assigned"
+	IncidentEventUnassigned   IncidentEventType = "unassigned"
+	IncidentEventComment      IncidentEventType = "comment"
+	IncidentEventResolved     IncidentEventType = "resolved"
+)
+
+// IncidentEvent is one entry in an incident's timeline: a state change, an
+// alert, an assignment or a comment. UserID is nil for system events.
+type IncidentEvent struct {
+	ID         uint              `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time         `gorm:"index:idx_incident_events_incident_created" json:"created_at"`
+	IncidentID uint              `gorm:"not null;index:idx_incident_events_incident_created" json:"incident_id"`
+	Type       IncidentEventType `gorm:"size:20;not null" json:"type"`
+	UserID     *uint             `json:"user_id,omitempty"`
+	AlertID    *uint             `json:"alert_id,omitempty"`
+	AssigneeID *uint             `json:"assignee_id,omitempty"`
+	Body       string            `gorm:"type:text" json:"body,omitempty"` // Comment text or a note on the change
+}
@@ -29,6 +29,28 @@ func (j *JSONMap) Scan(value interface{}) error {
     return json.Unmarshal(bytes, j)
 }
 
+// StringMap is a JSONB object with string values
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+    if m == nil {
+        return nil, nil
+    }
+    return json.Marshal(m)
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+    if value == nil {
+        *m = nil
+        return nil
+    }
+    bytes, ok := value.([]byte)
+    if !ok {
+        return errors.New("type assertion to []byte failed")
+    }
+    return json.Unmarshal(bytes, m)
+}
+
 // LogEvent is the legacy simple log model (kept for backward compatibility)
 type LogEvent struct {
     ID        uint      `gorm:"primarykey" json:"id"`
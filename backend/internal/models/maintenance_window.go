@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/schedule"
+)
+
+// MaintenanceScope selects which checks a maintenance window covers
+type MaintenanceScope string
+
+const (
+	MaintenanceScopeOrg    MaintenanceScope = "org"    // Every check in the org
+	MaintenanceScopeChecks MaintenanceScope = "checks" // The checks in CheckIDs
+	MaintenanceScopeTags   MaintenanceScope = "tags"   // Checks carrying all of Tags
+)
+
+// MaintenanceWindow suppresses alerts for its checks while active. A window
+// without a Schedule is one-off and runs from StartsAt to EndsAt; a
+// recurring window opens at each Schedule occurrence (cron or RRULE, in
+// Timezone) for DurationMinutes, with StartsAt/EndsAt optionally bounding
+// the recurrence.
+type MaintenanceWindow struct {
+	ID              uint             `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	OrgID           uint             `gorm:"not null;index" json:"org_id"`
+	Name            string           `gorm:"size:255;not null" json:"name"`
+	Scope           MaintenanceScope `gorm:"size:20;not null" json:"scope"`
+	CheckIDs        pq.Int64Array    `gorm:"type:bigint[]" json:"check_ids,omitempty"`
+	Tags            StringMap        `gorm:"type:jsonb" json:"tags,omitempty"`
+	StartsAt        *time.Time       `json:"starts_at,omitempty"`
+	EndsAt          *time.Time       `json:"ends_at,omitempty"`
+	Schedule        string           `gorm:"size:255" json:"schedule,omitempty"`
+	DurationMinutes int              `gorm:"not null;default:0" json:"duration_minutes,omitempty"`
+	Timezone        string           `gorm:"size:64" json:"timezone,omitempty"`
+	CreatedByID     *uint            `json:"created_by_id,omitempty"`
+}
+
+// AppliesTo reports whether the window's scope covers the check
+func (w MaintenanceWindow) AppliesTo(check Check) bool {
+	switch w.Scope {
+	case MaintenanceScopeOrg:
+		return true
+	case MaintenanceScopeChecks:
+		for _, id := range w.CheckIDs {
+			if uint(id) == check.ID {
+				return true
+			}
+		}
+	case MaintenanceScopeTags:
+		if len(w.Tags) == 0 {
+			return false
+		}
+		for key, want := range w.Tags {
+			got, ok := check.Tags[key]
+			if !ok || fmt.Sprint(got) != want {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ActiveAt reports whether the window is open at t
+func (w MaintenanceWindow) ActiveAt(t time.Time) bool {
+	if w.StartsAt != nil && t.Before(*w.StartsAt) {
+		return false
+	}
+	if w.EndsAt != nil && !t.Before(*w.EndsAt) {
+		return false
+	}
+	if w.Schedule == "" {
+		return w.StartsAt != nil && w.EndsAt != nil
+	}
+
+	sched, err := schedule.Parse(w.Schedule)
+	if err != nil {
+		return false
+	}
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	// Open if an occurrence started within the last DurationMinutes
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	start := sched.Next(t.In(loc).Add(-duration))
+	return !start.IsZero() && !start.After(t)
+}
@@ -13,6 +13,8 @@ type NotificationSettings struct {
     OrgID           uint           `gorm:"uniqueIndex;not null" json:"org_id"`
     EmailRecipients pq.StringArray `gorm:"type:text[]" json:"email_recipients"`
     WebhookURL      *string        `gorm:"size:2048" json:"webhook_url,omitempty"`
+    // Whoever is on call for these schedules is emailed as well
+    OnCallScheduleIDs pq.Int64Array `gorm:"type:bigint[]" json:"on_call_schedule_ids"`
     // Relations
     Organization Organization `gorm:"foreignKey:OrgID" json:"organization,omitempty"`
 }
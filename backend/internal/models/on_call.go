@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/schedule"
+)
+
+// OnCallSchedule rotates on-call duty through org members in order, handing
+// off daily or weekly at HandoffTime (and on HandoffWeekday for weekly
+// rotations) in Timezone. The first member's shift starts at the first
+// handoff at or after StartsAt.
+type OnCallSchedule struct {
+	ID             uint                    `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+	OrgID          uint                    `gorm:"not null;index" json:"org_id"`
+	Name           string                  `gorm:"size:255;not null" json:"name"`
+	Rotation       schedule.RotationPeriod `gorm:"size:20;not null" json:"rotation"`
+	HandoffTime    string                  `gorm:"size:5;not null" json:"handoff_time"`       // HH:MM
+	HandoffWeekday int                     `gorm:"not null;default:0" json:"handoff_weekday"` // 0 = Sunday; weekly only
+	Timezone       string                  `gorm:"size:64;not null" json:"timezone"`
+	StartsAt       time.Time               `json:"starts_at"`
+	MemberIDs      pq.Int64Array           `gorm:"type:bigint[]" json:"member_ids"` // User IDs in rotation order
+	CreatedByID    *uint                   `json:"created_by_id,omitempty"`
+}
+
+// OnCallRotation returns the schedule's rotation, or an error if its handoff
+// time or time zone is invalid
+func (s OnCallSchedule) OnCallRotation() (schedule.Rotation, error) {
+	hour, minute, err := schedule.ParseHandoff(s.HandoffTime)
+	if err != nil {
+		return schedule.Rotation{}, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return schedule.Rotation{}, err
+	}
+	return schedule.Rotation{
+		Period:   s.Rotation,
+		Hour:     hour,
+		Minute:   minute,
+		Weekday:  time.Weekday(s.HandoffWeekday),
+		Location: loc,
+		Start:    s.StartsAt,
+	}, nil
+}
+
+// OnCallOverride puts UserID on call for a schedule from StartsAt to EndsAt
+// in place of whoever the rotation says. The newest override wins where
+// overrides overlap.
+type OnCallOverride struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ScheduleID  uint      `gorm:"not null;index:idx_on_call_overrides_schedule_ends" json:"schedule_id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	StartsAt    time.Time `gorm:"not null" json:"starts_at"`
+	EndsAt      time.Time `gorm:"not null;index:idx_on_call_overrides_schedule_ends" json:"ends_at"`
+	Note        string    `gorm:"size:255" json:"note,omitempty"`
+	CreatedByID *uint     `json:"created_by_id,omitempty"`
+}
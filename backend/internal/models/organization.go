@@ -34,6 +34,12 @@ type Organization struct {
 	ExtraLogBytes int64 `gorm:"default:0" json:"-"`
 	ExtraChecks   int   `gorm:"default:0" json:"-"`
 
+	// Data retention, maintained by the retention worker.
+	// A plan downgrade that shortens retention only takes effect once the
+	// grace period ends, so a lapsed payment doesn't instantly wipe history.
+	AppliedRetentionDays int        `gorm:"default:0" json:"-"`
+	RetentionGraceUntil  *time.Time `json:"-"`
+
 	// Relations
 	Users   []User   `gorm:"foreignKey:OrgID" json:"users,omitempty"`
 	Checks  []Check  `gorm:"foreignKey:OrgID" json:"checks,omitempty"`
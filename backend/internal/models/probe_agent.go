@@ -0,0 +1,54 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// AgentTokenPrefix starts every probe agent token
+const AgentTokenPrefix = "lha_"
+
+// ProbeAgent is a lighthouse-agent process that runs the org's checks from
+// its region. It authenticates with a token whose SHA-256 is stored.
+type ProbeAgent struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	OrgID       uint       `gorm:"not null;index" json:"org_id"`
+	Name        string     `gorm:"size:255;not null" json:"name"`
+	Region      string     `gorm:"size:50;not null;index" json:"region"`
+	TokenHash   string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	TokenPrefix string     `gorm:"size:16" json:"token_prefix"`
+	Version     string     `gorm:"size:50" json:"version,omitempty"`
+	Hostname    string     `gorm:"size:255" json:"hostname,omitempty"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+	CreatedByID *uint      `json:"created_by_id,omitempty"`
+}
+
+// CheckRegionRun schedules a check in one of its regions: when it is next
+// due there and which agent holds the lease on the current run
+type CheckRegionRun struct {
+	CheckID        uint       `gorm:"primaryKey;autoIncrement:false"`
+	Region         string     `gorm:"primaryKey;size:50"`
+	NextRunAt      *time.Time `gorm:"index"`
+	LeaseExpiresAt *time.Time
+	LeasedBy       *uint // ProbeAgent ID
+}
+
+// GenerateAgentToken returns a new agent token and the hash to store
+func GenerateAgentToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = AgentTokenPrefix + hex.EncodeToString(b)
+	return token, HashAgentToken(token), nil
+}
+
+// HashAgentToken is the stored form of an agent token
+func HashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// RetentionRun records the rows purged for one org by one retention sweep.
+// Sweeps that purge nothing are not recorded.
+type RetentionRun struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	OrgID         uint      `gorm:"not null;index" json:"org_id"`
+	Plan          Plan      `gorm:"size:20" json:"plan"`
+	RetentionDays int       `gorm:"not null" json:"retention_days"` // Retention actually applied (may exceed the plan's during a downgrade grace period)
+	Cutoff        time.Time `gorm:"not null" json:"cutoff"`
+
+	LogEntriesPurged int64 `gorm:"not null;default:0" json:"log_entries_purged"`
+	LogEventsPurged  int64 `gorm:"not null;default:0" json:"log_events_purged"`
+	TraceSpansPurged int64 `gorm:"not null;default:0" json:"trace_spans_purged"`
+
+	// False when the per-run batch cap was hit; the next sweep continues
+	Complete   bool   `gorm:"not null;default:true" json:"complete"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `gorm:"size:1024" json:"error,omitempty"`
+}
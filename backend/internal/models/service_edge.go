@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// ServiceEdge is a materialized caller -> callee relationship between two
+// services, derived from parent/child span pairs and bucketed by hour.
+// Rows are recomputed by the service map worker, never written by handlers.
+type ServiceEdge struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// One row per org, hour, environment and service pair
+	OrgID         uint      `gorm:"not null;uniqueIndex:idx_service_edges_bucket,priority:1" json:"org_id"`
+	BucketStart   time.Time `gorm:"not null;uniqueIndex:idx_service_edges_bucket,priority:2" json:"bucket_start"`
+	Environment   string    `gorm:"size:50;not null;default:'';uniqueIndex:idx_service_edges_bucket,priority:3" json:"environment,omitempty"`
+	SourceService string    `gorm:"size:255;not null;uniqueIndex:idx_service_edges_bucket,priority:4" json:"source_service"`
+	TargetService string    `gorm:"size:255;not null;uniqueIndex:idx_service_edges_bucket,priority:5" json:"target_service"`
+
+	// Measured on the callee (child) span
+	RequestCount int64 `gorm:"not null;default:0" json:"request_count"`
+	ErrorCount   int64 `gorm:"not null;default:0" json:"error_count"`
+	P50Ms        int   `gorm:"not null;default:0" json:"p50_ms"`
+	P95Ms        int   `gorm:"not null;default:0" json:"p95_ms"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// SpanRollupBucketSeconds is the granularity of span rollups (5 minutes)
+const SpanRollupBucketSeconds = 300
+
+// SpanRollup pre-aggregates rate, errors and duration per service and
+// operation so RED metrics don't have to scan trace_spans.
+// Rows are recomputed by the span rollup worker, never written by handlers.
+type SpanRollup struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// One row per org, bucket, environment, service and operation
+	OrgID       uint      `gorm:"not null;uniqueIndex:idx_span_rollups_bucket,priority:1" json:"org_id"`
+	BucketStart time.Time `gorm:"not null;uniqueIndex:idx_span_rollups_bucket,priority:2" json:"bucket_start"`
+	Environment string    `gorm:"size:50;not null;default:'';uniqueIndex:idx_span_rollups_bucket,priority:3" json:"environment,omitempty"`
+	ServiceName string    `gorm:"size:255;not null;uniqueIndex:idx_span_rollups_bucket,priority:4" json:"service_name"`
+	Operation   string    `gorm:"size:512;not null;uniqueIndex:idx_span_rollups_bucket,priority:5" json:"operation"`
+
+	RequestCount  int64 `gorm:"not null;default:0" json:"request_count"`
+	ErrorCount    int64 `gorm:"not null;default:0" json:"error_count"`
+	DurationSumMs int64 `gorm:"not null;default:0" json:"duration_sum_ms"`
+	P50Ms         int   `gorm:"not null;default:0" json:"p50_ms"`
+	P95Ms         int   `gorm:"not null;default:0" json:"p95_ms"`
+	P99Ms         int   `gorm:"not null;default:0" json:"p99_ms"`
+	MaxMs         int   `gorm:"not null;default:0" json:"max_ms"`
+}
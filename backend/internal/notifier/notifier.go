@@ -3,14 +3,17 @@ package notifier
 import (
     "bytes"
     "encoding/json"
+    "errors"
     "fmt"
     "log"
     "net/http"
     "net/smtp"
+    "slices"
     "time"
 
     "github.com/oFuterman/light-house/internal/config"
     "github.com/oFuterman/light-house/internal/models"
+    "github.com/oFuterman/light-house/internal/oncall"
     "github.com/sendgrid/sendgrid-go"
     "github.com/sendgrid/sendgrid-go/helpers/mail"
     "gorm.io/gorm"
@@ -30,9 +33,41 @@ type WebhookPayload struct {
     Event        models.AlertType `json:"event"`
     StatusCode   int              `json:"status_code"`
     ErrorMessage string           `json:"error_message,omitempty"`
+    IncidentID   *uint            `json:"incident_id,omitempty"`
     Timestamp    time.Time        `json:"timestamp"`
 }
 
+// Recipients are the addresses a notification goes to
+type Recipients struct {
+    Emails   []string
+    Webhooks []string
+}
+
+// IsEmpty reports whether there is nobody to notify
+func (r Recipients) IsEmpty() bool {
+    return len(r.Emails) == 0 && len(r.Webhooks) == 0
+}
+
+// SettingsRecipients returns the recipients configured in the org's
+// notification settings, including whoever is on call at t for its
+// on-call schedules
+func SettingsRecipients(db *gorm.DB, settings models.NotificationSettings, t time.Time) Recipients {
+    r := Recipients{Emails: append([]string{}, settings.EmailRecipients...)}
+    if settings.WebhookURL != nil && *settings.WebhookURL != "" {
+        r.Webhooks = []string{*settings.WebhookURL}
+    }
+    onCall, err := oncall.Emails(db, settings.OrgID, settings.OnCallScheduleIDs, t)
+    if err != nil {
+        log.Printf("Error resolving on-call recipients for org %d: %v", settings.OrgID, err)
+    }
+    for _, email := range onCall {
+        if !slices.Contains(r.Emails, email) {
+            r.Emails = append(r.Emails, email)
+        }
+    }
+    return r
+}
+
 // SendAllNotifications loads settings and sends all configured notifications
 func SendAllNotifications(db *gorm.DB, alert models.Alert, check models.Check) error {
     var settings models.NotificationSettings
@@ -44,32 +79,45 @@ func SendAllNotifications(db *gorm.DB, alert models.Alert, check models.Check) e
         }
         return fmt.Errorf("failed to load notification settings: %w", err)
     }
-    var emailErr, webhookErr error
+    return Send(alert, check, SettingsRecipients(db, settings, time.Now()))
+}
+
+// Send delivers an alert to every recipient. It fails only if every
+// channel that was tried failed.
+func Send(alert models.Alert, check models.Check, to Recipients) error {
+    var errs []error
+    tried := 0
     // Send emails if recipients configured
-    if len(settings.EmailRecipients) > 0 {
-        emailErr = sendEmailAlert(settings, alert, check)
-        if emailErr != nil {
-            log.Printf("Email alert failed for check %d: %v", check.ID, emailErr)
+    if len(to.Emails) > 0 {
+        tried++
+        if err := sendEmailAlert(to.Emails, alert, check); err != nil {
+            log.Printf("Email alert failed for check %d: %v", check.ID, err)
+            errs = append(errs, fmt.Errorf("email: %w", err))
         }
     }
-    // Send webhook if URL configured
-    if settings.WebhookURL != nil && *settings.WebhookURL != "" {
-        webhookErr = sendWebhookAlert(*settings.WebhookURL, alert, check)
-        if webhookErr != nil {
-            log.Printf("Webhook alert failed for check %d: %v", check.ID, webhookErr)
+    // Send webhooks if URLs configured
+    for _, url := range to.Webhooks {
+        tried++
+        if err := sendWebhookAlert(url, alert, check); err != nil {
+            log.Printf("Webhook alert failed for check %d: %v", check.ID, err)
+            errs = append(errs, fmt.Errorf("webhook: %w", err))
         }
     }
-    // Return error only if both failed (and both were configured)
-    if emailErr != nil && webhookErr != nil {
-        return fmt.Errorf("all notifications failed: email=%v, webhook=%v", emailErr, webhookErr)
+    // Return error only if all failed
+    if tried > 0 && len(errs) == tried {
+        return fmt.Errorf("all notifications failed: %w", errors.Join(errs...))
     }
     return nil
 }
 
 // sendEmailAlert sends email to all recipients via SendGrid (prod) or SMTP/Mailpit (dev)
-func sendEmailAlert(settings models.NotificationSettings, alert models.Alert, check models.Check) error {
+func sendEmailAlert(recipients []string, alert models.Alert, check models.Check) error {
     subject := fmt.Sprintf("[%s] %s is %s", alert.AlertType, check.Name, alert.AlertType)
     body := fmt.Sprintf("%s is %s", check.Name, alert.AlertType)
+    if alert.AlertType == models.AlertTypeCertExpiring {
+        subject = fmt.Sprintf("[%s] TLS certificate for %s expires soon", alert.AlertType, check.Name)
+        body = fmt.Sprintf("The TLS certificate for %s expires soon", check.Name)
+    }
     if alert.StatusCode > 0 {
         body = fmt.Sprintf("%s (%d)", body, alert.StatusCode)
     }
@@ -82,15 +130,15 @@ func sendEmailAlert(settings models.NotificationSettings, alert models.Alert, ch
         if cfg.SendGridKey == "" {
             return fmt.Errorf("SendGrid API key required in production")
         }
-        return sendViaSendGrid(settings.EmailRecipients, subject, body)
+        return sendViaSendGrid(recipients, subject, body)
     }
     // Development: use SMTP (Mailpit)
     if cfg.SMTPHost != "" {
-        return sendViaSMTP(settings.EmailRecipients, subject, body)
+        return sendViaSMTP(recipients, subject, body)
     }
     // Fallback: try SendGrid if configured even in dev
     if cfg.SendGridKey != "" {
-        return sendViaSendGrid(settings.EmailRecipients, subject, body)
+        return sendViaSendGrid(recipients, subject, body)
     }
     return fmt.Errorf("no email provider configured (set SMTP_HOST for dev or SENDGRID_API_KEY)")
 }
@@ -142,6 +190,7 @@ func sendWebhookAlert(webhookURL string, alert models.Alert, check models.Check)
         Event:        alert.AlertType,
         StatusCode:   alert.StatusCode,
         ErrorMessage: alert.ErrorMessage,
+        IncidentID:   alert.IncidentID,
         Timestamp:    alert.CreatedAt,
     }
     jsonData, err := json.Marshal(payload)
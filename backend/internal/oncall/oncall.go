@@ -0,0 +1,100 @@
+// Package oncall answers who is on call for a schedule at a given time,
+// combining the schedule's rotation with its overrides. Notification
+// settings and escalation policies resolve on-call targets through here.
+package oncall
+
+import (
+	"errors"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// Shift is who is on call for a schedule and for how long
+type Shift struct {
+	ScheduleID uint       `json:"schedule_id"`
+	UserID     *uint      `json:"user_id"` // Nil when nobody is on call
+	Email      string     `json:"email,omitempty"`
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"` // Next handoff, or the next override taking over
+	OverrideID *uint      `json:"override_id,omitempty"`
+}
+
+// At returns the shift of the schedule in progress at t. An override
+// covering t takes precedence over the rotation. A user who has since left
+// the org leaves the shift uncovered.
+func At(db *gorm.DB, s models.OnCallSchedule, t time.Time) (Shift, error) {
+	shift := Shift{ScheduleID: s.ID}
+
+	var override models.OnCallOverride
+	err := db.Where("schedule_id = ? AND starts_at <= ? AND ends_at > ?", s.ID, t, t).
+		Order("created_at DESC, id DESC").
+		First(&override).Error
+	switch {
+	case err == nil:
+		shift.UserID = &override.UserID
+		shift.StartsAt, shift.EndsAt = &override.StartsAt, &override.EndsAt
+		shift.OverrideID = &override.ID
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return shift, err
+	default:
+		rotation, err := s.OnCallRotation()
+		if err != nil {
+			return shift, err
+		}
+		n, start, end, ok := rotation.Shift(t)
+		if !ok || len(s.MemberIDs) == 0 {
+			return shift, nil
+		}
+		userID := uint(s.MemberIDs[n%len(s.MemberIDs)])
+		shift.UserID = &userID
+		shift.StartsAt, shift.EndsAt = &start, &end
+
+		// An override starting before the handoff ends the shift early
+		var next models.OnCallOverride
+		err = db.Where("schedule_id = ? AND starts_at > ? AND starts_at < ?", s.ID, t, end).
+			Order("starts_at ASC").
+			First(&next).Error
+		if err == nil {
+			shift.EndsAt = &next.StartsAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return shift, err
+		}
+	}
+
+	var user models.User
+	err = db.Select("id", "email").Where("id = ? AND org_id = ?", *shift.UserID, s.OrgID).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		shift.UserID = nil
+		return shift, nil
+	}
+	if err != nil {
+		return shift, err
+	}
+	shift.Email = user.Email
+	return shift, nil
+}
+
+// Emails returns the addresses of whoever is on call at t for each of the
+// org's schedules listed. Unknown schedules and uncovered shifts are skipped.
+func Emails(db *gorm.DB, orgID uint, scheduleIDs []int64, t time.Time) ([]string, error) {
+	if len(scheduleIDs) == 0 {
+		return nil, nil
+	}
+	var schedules []models.OnCallSchedule
+	if err := db.Where("org_id = ? AND id IN ?", orgID, scheduleIDs).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, s := range schedules {
+		shift, err := At(db, s, t)
+		if err != nil {
+			return emails, err
+		}
+		if shift.Email != "" {
+			emails = append(emails, shift.Email)
+		}
+	}
+	return emails, nil
+}
@@ -0,0 +1,180 @@
+// Package otlp decodes OTLP/HTTP export requests and maps them onto the
+// LogEntry and TraceSpan models.
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxDecodedBodyBytes caps the size of a request body after decompression
+const MaxDecodedBodyBytes = 16 * 1024 * 1024
+
+// Content types defined by the OTLP/HTTP specification
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// Encoding is the wire encoding of an OTLP/HTTP request
+type Encoding int
+
+const (
+	EncodingProtobuf Encoding = iota
+	EncodingJSON
+)
+
+// ContentType returns the Content-Type header value for the encoding
+func (e Encoding) ContentType() string {
+	if e == EncodingJSON {
+		return ContentTypeJSON
+	}
+	return ContentTypeProtobuf
+}
+
+// ErrUnsupportedContentType is returned for anything other than protobuf or JSON
+var ErrUnsupportedContentType = errors.New("unsupported content type (use application/x-protobuf or application/json)")
+
+// EncodingFromContentType resolves the request encoding from its Content-Type header
+func EncodingFromContentType(contentType string) (Encoding, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, ErrUnsupportedContentType
+	}
+	switch mediaType {
+	case ContentTypeProtobuf:
+		return EncodingProtobuf, nil
+	case ContentTypeJSON:
+		return EncodingJSON, nil
+	}
+	return 0, ErrUnsupportedContentType
+}
+
+// ReadBody undoes the Content-Encoding of a raw request body.
+// Only gzip (and identity) are supported, and the decoded size is capped.
+func ReadBody(raw []byte, contentEncoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return raw, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer zr.Close()
+		body, err := io.ReadAll(io.LimitReader(zr, MaxDecodedBodyBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		if len(body) > MaxDecodedBodyBytes {
+			return nil, fmt.Errorf("decompressed body exceeds %d bytes", MaxDecodedBodyBytes)
+		}
+		return body, nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+}
+
+// DecodeTraces parses an ExportTraceServiceRequest in the given encoding
+func DecodeTraces(body []byte, enc Encoding) (*coltracepb.ExportTraceServiceRequest, error) {
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if err := unmarshal(body, enc, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// DecodeLogs parses an ExportLogsServiceRequest in the given encoding
+func DecodeLogs(body []byte, enc Encoding) (*collogspb.ExportLogsServiceRequest, error) {
+	req := &collogspb.ExportLogsServiceRequest{}
+	if err := unmarshal(body, enc, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// MarshalResponse encodes an export response in the same encoding as the request
+func MarshalResponse(msg proto.Message, enc Encoding) ([]byte, error) {
+	if enc == EncodingJSON {
+		return protojson.Marshal(msg)
+	}
+	return proto.Marshal(msg)
+}
+
+func unmarshal(body []byte, enc Encoding, msg proto.Message) error {
+	if enc == EncodingProtobuf {
+		return proto.Unmarshal(body, msg)
+	}
+	// OTLP/JSON deviates from the canonical protobuf JSON mapping: trace and
+	// span IDs are hex strings rather than base64. Rewrite them first so
+	// protojson can do the rest.
+	fixed, err := hexIDsToBase64(body)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(fixed, msg)
+}
+
+// idFields are the OTLP/JSON keys whose values are hex-encoded bytes
+var idFields = map[string]bool{
+	"traceId":        true,
+	"spanId":         true,
+	"parentSpanId":   true,
+	"trace_id":       true,
+	"span_id":        true,
+	"parent_span_id": true,
+}
+
+func hexIDsToBase64(body []byte) ([]byte, error) {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber() // keep 64-bit nanosecond timestamps exact
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := rewriteIDs(doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func rewriteIDs(node interface{}) error {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if s, ok := child.(string); ok && idFields[key] {
+				if s == "" {
+					continue
+				}
+				raw, err := hex.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("%s: invalid hex ID %q", key, s)
+				}
+				v[key] = base64.StdEncoding.EncodeToString(raw)
+				continue
+			}
+			if err := rewriteIDs(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if err := rewriteIDs(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oFuterman/light-house/internal/models"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Resource attributes promoted to dedicated columns instead of Tags
+const (
+	attrServiceName       = "service.name"
+	attrDeploymentEnv     = "deployment.environment"
+	attrDeploymentEnvName = "deployment.environment.name"
+	attrCloudRegion       = "cloud.region"
+)
+
+// defaultServiceName is what the OTel SDKs report when service.name is unset
+const defaultServiceName = "unknown_service"
+
+// Column size limits from the models, applied so one long value can't fail a batch
+const (
+	maxServiceNameLen = 255
+	maxEnvironmentLen = 50
+	maxOperationLen   = 512
+	maxMessageLen     = 8192
+)
+
+// resourceColumns holds resource attributes mapped onto model columns
+type resourceColumns struct {
+	ServiceName string
+	Environment string
+	Region      string
+	Tags        models.JSONMap
+}
+
+// Rejected summarises items that could not be mapped, for OTLP partial success
+type Rejected struct {
+	Count   int64
+	Message string
+}
+
+func (r *Rejected) add(format string, args ...interface{}) {
+	r.Count++
+	if r.Message == "" {
+		r.Message = fmt.Sprintf(format, args...)
+	}
+}
+
+// SpansFromRequest maps every span in the request onto a TraceSpan for orgID.
+// Spans with malformed IDs are skipped and counted in the returned Rejected.
+func SpansFromRequest(req *coltracepb.ExportTraceServiceRequest, orgID uint) ([]models.TraceSpan, Rejected) {
+	var spans []models.TraceSpan
+	var rejected Rejected
+	for _, rs := range req.GetResourceSpans() {
+		res := mapResource(rs.GetResource().GetAttributes())
+		for _, ss := range rs.GetScopeSpans() {
+			scopeName := ss.GetScope().GetName()
+			for _, span := range ss.GetSpans() {
+				traceID, ok := validID(span.GetTraceId(), 16)
+				if !ok {
+					rejected.add("span %q: invalid trace ID", span.GetName())
+					continue
+				}
+				spanID, ok := validID(span.GetSpanId(), 8)
+				if !ok {
+					rejected.add("span %q: invalid span ID", span.GetName())
+					continue
+				}
+				parentID := ""
+				if len(span.GetParentSpanId()) > 0 {
+					if parentID, ok = validID(span.GetParentSpanId(), 8); !ok || parentID == spanID {
+						rejected.add("span %q: invalid parent span ID", span.GetName())
+						continue
+					}
+				}
+
+				tags := mergeTags(res.Tags, span.GetAttributes())
+				if scopeName != "" {
+					tags["otel.scope.name"] = scopeName
+				}
+				if kind := spanKindName(span.GetKind()); kind != "" {
+					tags["span.kind"] = kind
+				}
+				if msg := span.GetStatus().GetMessage(); msg != "" {
+					tags["status.message"] = msg
+				}
+
+				start := time.Unix(0, int64(span.GetStartTimeUnixNano()))
+				durationMs := 0
+				if end := span.GetEndTimeUnixNano(); end > span.GetStartTimeUnixNano() {
+					durationMs = int((end - span.GetStartTimeUnixNano()) / uint64(time.Millisecond))
+				}
+
+				spans = append(spans, models.TraceSpan{
+					OrgID:        orgID,
+					ServiceName:  res.ServiceName,
+					Environment:  res.Environment,
+					Operation:    truncate(span.GetName(), maxOperationLen),
+					Status:       spanStatus(span.GetStatus().GetCode()),
+					DurationMs:   durationMs,
+					StartTime:    start,
+					TraceID:      traceID,
+					SpanID:       spanID,
+					ParentSpanID: parentID,
+					Tags:         tags,
+				})
+			}
+		}
+	}
+	return spans, rejected
+}
+
+// LogsFromRequest maps every log record in the request onto a LogEntry for orgID.
+// Records with malformed trace context are skipped and counted in Rejected.
+func LogsFromRequest(req *collogspb.ExportLogsServiceRequest, orgID uint) ([]models.LogEntry, Rejected) {
+	var entries []models.LogEntry
+	var rejected Rejected
+	now := time.Now()
+	for _, rl := range req.GetResourceLogs() {
+		res := mapResource(rl.GetResource().GetAttributes())
+		for _, sl := range rl.GetScopeLogs() {
+			scopeName := sl.GetScope().GetName()
+			for _, rec := range sl.GetLogRecords() {
+				traceID, spanID := "", ""
+				if len(rec.GetTraceId()) > 0 {
+					id, ok := validID(rec.GetTraceId(), 16)
+					if !ok {
+						rejected.add("log record: invalid trace ID")
+						continue
+					}
+					traceID = id
+				}
+				if len(rec.GetSpanId()) > 0 {
+					id, ok := validID(rec.GetSpanId(), 8)
+					if !ok {
+						rejected.add("log record: invalid span ID")
+						continue
+					}
+					spanID = id
+				}
+
+				ts := now
+				if t := rec.GetTimeUnixNano(); t > 0 {
+					ts = time.Unix(0, int64(t))
+				} else if t := rec.GetObservedTimeUnixNano(); t > 0 {
+					ts = time.Unix(0, int64(t))
+				}
+
+				tags := mergeTags(res.Tags, rec.GetAttributes())
+				if scopeName != "" {
+					tags["otel.scope.name"] = scopeName
+				}
+
+				entries = append(entries, models.LogEntry{
+					OrgID:       orgID,
+					ServiceName: res.ServiceName,
+					Environment: res.Environment,
+					Region:      res.Region,
+					Level:       logLevel(rec.GetSeverityText(), rec.GetSeverityNumber()),
+					Message:     truncate(bodyString(rec.GetBody()), maxMessageLen),
+					Timestamp:   ts,
+					TraceID:     traceID,
+					SpanID:      spanID,
+					Tags:        tags,
+				})
+			}
+		}
+	}
+	return entries, rejected
+}
+
+// mapResource pulls well-known resource attributes into columns and keeps the rest as tags
+func mapResource(attrs []*commonpb.KeyValue) resourceColumns {
+	res := resourceColumns{
+		ServiceName: defaultServiceName,
+		Tags:        models.JSONMap{},
+	}
+	for _, kv := range attrs {
+		value := anyValue(kv.GetValue())
+		switch kv.GetKey() {
+		case attrServiceName:
+			if s, ok := value.(string); ok && strings.TrimSpace(s) != "" {
+				res.ServiceName = truncate(strings.TrimSpace(s), maxServiceNameLen)
+			}
+		case attrDeploymentEnv, attrDeploymentEnvName:
+			if s, ok := value.(string); ok {
+				res.Environment = truncate(strings.TrimSpace(s), maxEnvironmentLen)
+			}
+		case attrCloudRegion:
+			if s, ok := value.(string); ok {
+				res.Region = truncate(strings.TrimSpace(s), maxEnvironmentLen)
+			}
+		default:
+			res.Tags[kv.GetKey()] = value
+		}
+	}
+	return res
+}
+
+// mergeTags copies the resource tags and overlays item-level attributes
+func mergeTags(resourceTags models.JSONMap, attrs []*commonpb.KeyValue) models.JSONMap {
+	tags := make(models.JSONMap, len(resourceTags)+len(attrs))
+	for k, v := range resourceTags {
+		tags[k] = v
+	}
+	for _, kv := range attrs {
+		tags[kv.GetKey()] = anyValue(kv.GetValue())
+	}
+	return tags
+}
+
+// anyValue converts an OTLP AnyValue into a JSON-friendly Go value
+func anyValue(v *commonpb.AnyValue) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch val := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return val.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return val.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return val.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return val.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(val.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := val.ArrayValue.GetValues()
+		out := make([]interface{}, len(values))
+		for i, item := range values {
+			out[i] = anyValue(item)
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		out := make(map[string]interface{}, len(val.KvlistValue.GetValues()))
+		for _, kv := range val.KvlistValue.GetValues() {
+			out[kv.GetKey()] = anyValue(kv.GetValue())
+		}
+		return out
+	}
+	return nil
+}
+
+// bodyString renders a log body as the stored message
+func bodyString(v *commonpb.AnyValue) string {
+	value := anyValue(v)
+	switch val := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// validID hex-encodes an ID of the expected byte length, rejecting all-zero IDs
+func validID(id []byte, length int) (string, bool) {
+	if len(id) != length {
+		return "", false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return hex.EncodeToString(id), true
+		}
+	}
+	return "", false
+}
+
+// spanStatus maps the OTLP status code onto the stored span status.
+// UNSET means the instrumentation saw no error, so it is stored as OK.
+func spanStatus(code tracepb.Status_StatusCode) string {
+	if code == tracepb.Status_STATUS_CODE_ERROR {
+		return models.SpanStatusError
+	}
+	return models.SpanStatusOK
+}
+
+func spanKindName(kind tracepb.Span_SpanKind) string {
+	switch kind {
+	case tracepb.Span_SPAN_KIND_INTERNAL:
+		return "internal"
+	case tracepb.Span_SPAN_KIND_SERVER:
+		return "server"
+	case tracepb.Span_SPAN_KIND_CLIENT:
+		return "client"
+	case tracepb.Span_SPAN_KIND_PRODUCER:
+		return "producer"
+	case tracepb.Span_SPAN_KIND_CONSUMER:
+		return "consumer"
+	}
+	return ""
+}
+
+// logLevel prefers a recognised severity text and falls back to the severity number ranges
+func logLevel(text string, number logspb.SeverityNumber) string {
+	switch level := strings.ToUpper(strings.TrimSpace(text)); level {
+	case "TRACE", "DEBUG", "INFO", "WARN", "WARNING", "ERROR", "FATAL":
+		return level
+	}
+	switch {
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "FATAL"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "ERROR"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "WARN"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "INFO"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "DEBUG"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "TRACE"
+	}
+	return "INFO"
+}
+
+// truncate cuts s to at most max bytes without splitting a UTF-8 sequence
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
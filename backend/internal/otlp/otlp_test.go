@@ -0,0 +1,122 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+const traceJSON = `{
+  "resourceSpans": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "checkout"}},
+      {"key": "deployment.environment", "value": {"stringValue": "prod"}},
+      {"key": "host.name", "value": {"stringValue": "web-1"}}
+    ]},
+    "scopeSpans": [{
+      "scope": {"name": "otelhttp"},
+      "spans": [{
+        "traceId": "5b8efff798038103d269b633813fc60c",
+        "spanId": "eee19b7ec3c1b174",
+        "parentSpanId": "eee19b7ec3c1b173",
+        "name": "GET /cart",
+        "kind": 2,
+        "startTimeUnixNano": "1544712660000000000",
+        "endTimeUnixNano": "1544712661250000000",
+        "attributes": [{"key": "http.status_code", "value": {"intValue": "500"}}],
+        "status": {"code": 2}
+      }, {
+        "traceId": "00000000000000000000000000000000",
+        "spanId": "eee19b7ec3c1b175",
+        "name": "bad"
+      }]
+    }]
+  }]
+}`
+
+func TestDecodeTracesJSON(t *testing.T) {
+	req, err := DecodeTraces([]byte(traceJSON), EncodingJSON)
+	if err != nil {
+		t.Fatalf("DecodeTraces: %v", err)
+	}
+	spans, rejected := SpansFromRequest(req, 7)
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if rejected.Count != 1 {
+		t.Errorf("rejected = %d, want 1", rejected.Count)
+	}
+
+	s := spans[0]
+	if s.OrgID != 7 || s.ServiceName != "checkout" || s.Environment != "prod" {
+		t.Errorf("unexpected columns: org=%d service=%q env=%q", s.OrgID, s.ServiceName, s.Environment)
+	}
+	if s.TraceID != "5b8efff798038103d269b633813fc60c" || s.SpanID != "eee19b7ec3c1b174" || s.ParentSpanID != "eee19b7ec3c1b173" {
+		t.Errorf("IDs not round-tripped as hex: %q %q %q", s.TraceID, s.SpanID, s.ParentSpanID)
+	}
+	if s.Status != models.SpanStatusError {
+		t.Errorf("Status = %q, want %q", s.Status, models.SpanStatusError)
+	}
+	if s.DurationMs != 1250 {
+		t.Errorf("DurationMs = %d, want 1250", s.DurationMs)
+	}
+	if s.Tags["host.name"] != "web-1" || s.Tags["span.kind"] != "server" || s.Tags["http.status_code"] != int64(500) {
+		t.Errorf("unexpected tags: %v", s.Tags)
+	}
+	if _, ok := s.Tags["service.name"]; ok {
+		t.Errorf("service.name should be promoted to a column, not kept in tags")
+	}
+}
+
+func TestDecodeLogsSeverity(t *testing.T) {
+	body := `{"resourceLogs": [{"scopeLogs": [{"logRecords": [
+		{"severityNumber": 17, "body": {"stringValue": "boom"}},
+		{"severityText": "warn", "body": {"kvlistValue": {"values": [{"key": "a", "value": {"boolValue": true}}]}}}
+	]}]}]}`
+	req, err := DecodeLogs([]byte(body), EncodingJSON)
+	if err != nil {
+		t.Fatalf("DecodeLogs: %v", err)
+	}
+	entries, rejected := LogsFromRequest(req, 1)
+	if len(entries) != 2 || rejected.Count != 0 {
+		t.Fatalf("got %d entries, %d rejected", len(entries), rejected.Count)
+	}
+	if entries[0].Level != "ERROR" || entries[0].Message != "boom" {
+		t.Errorf("entry 0 = %q %q", entries[0].Level, entries[0].Message)
+	}
+	if entries[1].Level != "WARN" || entries[1].Message != `{"a":true}` {
+		t.Errorf("entry 1 = %q %q", entries[1].Level, entries[1].Message)
+	}
+	if entries[0].ServiceName != defaultServiceName {
+		t.Errorf("ServiceName = %q, want %q", entries[0].ServiceName, defaultServiceName)
+	}
+}
+
+func TestReadBodyGzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello"))
+	zw.Close()
+
+	got, err := ReadBody(buf.Bytes(), "gzip")
+	if err != nil || string(got) != "hello" {
+		t.Errorf("ReadBody(gzip) = %q, %v", got, err)
+	}
+	if _, err := ReadBody([]byte("x"), "br"); err == nil {
+		t.Errorf("ReadBody(br) should fail")
+	}
+}
+
+func TestEncodingFromContentType(t *testing.T) {
+	if enc, err := EncodingFromContentType("application/json; charset=utf-8"); err != nil || enc != EncodingJSON {
+		t.Errorf("json: got %v, %v", enc, err)
+	}
+	if enc, err := EncodingFromContentType("application/x-protobuf"); err != nil || enc != EncodingProtobuf {
+		t.Errorf("protobuf: got %v, %v", enc, err)
+	}
+	if _, err := EncodingFromContentType("text/plain"); err == nil {
+		t.Errorf("text/plain should be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+const dnsProbeTimeout = 10 * time.Second
+
+// probeDNS resolves the check's hostname for its record type, using the
+// configured resolver if any. It is up when the lookup returns at least one
+// record and every expected value is among the answers.
+func probeDNS(check models.Check) Outcome {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsProbeTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	answers, err := lookupDNS(ctx, dnsResolver(check.DNSResolver), check.DNSRecordType, check.URL)
+	outcome := Outcome{
+		StatusCode:     StatusDown,
+		ResponseTimeMs: time.Since(startTime).Milliseconds(),
+	}
+	if err != nil {
+		outcome.ErrorMessage = fmt.Sprintf("%s lookup failed: %v", check.DNSRecordType, err)
+		return outcome
+	}
+	if len(answers) == 0 {
+		outcome.ErrorMessage = fmt.Sprintf("no %s records for %s", check.DNSRecordType, check.URL)
+		return outcome
+	}
+	if missing := missingDNSValues(check.DNSRecordType, check.DNSExpected, answers); len(missing) > 0 {
+		outcome.ErrorMessage = fmt.Sprintf("expected %s not in answer %s",
+			strings.Join(missing, ", "), truncateBanner([]byte(strings.Join(answers, ", "))))
+		return outcome
+	}
+
+	outcome.StatusCode = StatusUp
+	outcome.Success = true
+	return outcome
+}
+
+// dnsResolver returns the system resolver, or one that sends every query to
+// address (host:port)
+func dnsResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// lookupDNS returns the answers in the same normalized form as the
+// expected values stored on the check
+func lookupDNS(ctx context.Context, r *net.Resolver, recordType, host string) ([]string, error) {
+	var answers []string
+	switch recordType {
+	case "AAAA":
+		ips, err := r.LookupIP(ctx, "ip6", host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, normalizeDNSName(cname))
+	case "MX":
+		mxs, err := r.LookupMX(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, fmt.Sprintf("%d %s", mx.Pref, normalizeDNSName(mx.Host)))
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		answers = txts
+	case "NS":
+		nss, err := r.LookupNS(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, normalizeDNSName(ns.Host))
+		}
+	default: // A
+		ips, err := r.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	}
+	return answers, nil
+}
+
+// missingDNSValues returns the expected values absent from the answers.
+// An expected MX value may omit the preference ("mail.example.com").
+func missingDNSValues(recordType string, expected, answers []string) []string {
+	present := make(map[string]bool, len(answers)*2)
+	for _, a := range answers {
+		present[a] = true
+		if recordType == "MX" {
+			if _, host, ok := strings.Cut(a, " "); ok {
+				present[host] = true
+			}
+		}
+	}
+	var missing []string
+	for _, e := range expected {
+		if !present[e] {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+func normalizeDNSName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
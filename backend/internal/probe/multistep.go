@@ -0,0 +1,178 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/assertion"
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+// probeMultiStep runs the check's steps in order, stopping at the first
+// failure. Steps share a cookie jar and a variable set seeded from the
+// check's secret variables and extended by each step's extractors. Steps
+// still running at deadline fail, as do those not started by then.
+func probeMultiStep(check models.Check, sec Secrets, deadline time.Time) Outcome {
+	outcome := Outcome{StatusCode: StatusDown}
+	vars := make(map[string]string, len(sec.Variables))
+	for name, value := range sec.Variables {
+		vars[name] = value
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	jar, _ := cookiejar.New(nil)
+	client := newHTTPClient(check, nil, jar)
+	for i, step := range check.Steps {
+		result, statusCode, errMsg := runStep(ctx, client, sec, step, vars)
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("Step %d", i+1)
+		}
+		outcome.Steps = append(outcome.Steps, result)
+		outcome.ResponseTimeMs += result.ResponseTimeMs
+		outcome.StatusCode = statusCode
+		if errMsg != "" {
+			failed := i + 1
+			outcome.FailedStep = &failed
+			outcome.ErrorMessage = truncateErrorMessage(fmt.Sprintf("step %d (%s): %s", failed, result.Name, errMsg))
+			return outcome
+		}
+	}
+	outcome.Success = true
+	return outcome
+}
+
+// runStep sends one step's request, applies its assertions and stores its
+// extracted variables in vars
+func runStep(ctx context.Context, client *http.Client, sec Secrets, step models.CheckStep, vars map[string]string) (models.StepResult, int, string) {
+	result := models.StepResult{Name: step.Name}
+	fail := func(statusCode int, format string, args ...interface{}) (models.StepResult, int, string) {
+		result.StatusCode = statusCode
+		result.Error = fmt.Sprintf(format, args...)
+		return result, statusCode, result.Error
+	}
+
+	if ctx.Err() != nil {
+		return fail(StatusDown, "run exceeded %s", MaxRunDuration)
+	}
+	req, err := buildStepRequest(sec, step, vars)
+	if err != nil {
+		return fail(StatusDown, "%v", err)
+	}
+	req = req.WithContext(ctx)
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	result.ResponseTimeMs = time.Since(startTime).Milliseconds()
+	if err != nil {
+		return fail(StatusDown, "%v", err)
+	}
+	defer resp.Body.Close()
+
+	success, body, errMsg := checkHTTPResponse(resp, step.Assertions, result.ResponseTimeMs, len(step.Extract) > 0)
+	if !success {
+		if errMsg == "" {
+			errMsg = fmt.Sprintf("status %d", resp.StatusCode)
+		}
+		return fail(resp.StatusCode, "%s", errMsg)
+	}
+	for _, ex := range step.Extract {
+		value, err := extractValue(ex, resp, body)
+		if err != nil {
+			return fail(resp.StatusCode, "extracting %s: %v", ex.Name, err)
+		}
+		vars[ex.Name] = value
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.Success = true
+	return result, resp.StatusCode, ""
+}
+
+// buildStepRequest renders a step's templates into a request. The check's
+// secret headers go on every step.
+func buildStepRequest(sec Secrets, step models.CheckStep, vars map[string]string) (*http.Request, error) {
+	url, err := renderTemplate(step.URL, vars)
+	if err != nil {
+		return nil, err
+	}
+	method := step.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if step.Body != "" {
+		rendered, err := renderTemplate(step.Body, vars)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(rendered)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range sec.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range step.Headers {
+		rendered, err := renderTemplate(value, vars)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, rendered)
+	}
+	applyHostHeader(req)
+	return req, nil
+}
+
+// renderTemplate replaces {{name}} references with their values
+func renderTemplate(s string, vars map[string]string) (string, error) {
+	var missing string
+	rendered := models.StepVariableRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := models.StepVariableRef.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("variable %q is not defined", missing)
+	}
+	return rendered, nil
+}
+
+// extractValue reads an extractor's value from a step response
+func extractValue(ex models.StepExtractor, resp *http.Response, body []byte) (string, error) {
+	switch ex.Type {
+	case models.ExtractJSONPath:
+		return assertion.ExtractJSONPath(body, ex.Path)
+	case models.ExtractHeader:
+		value := resp.Header.Get(ex.Path)
+		if value == "" {
+			return "", fmt.Errorf("header %s not set", ex.Path)
+		}
+		return value, nil
+	case models.ExtractRegex:
+		re, err := regexp.Compile(ex.Path)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindSubmatch(body)
+		if match == nil {
+			return "", fmt.Errorf("/%s/ did not match", ex.Path)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}
+	return "", fmt.Errorf("unknown extractor type %q", ex.Type)
+}
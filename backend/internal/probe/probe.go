@@ -0,0 +1,300 @@
+package probe
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/assertion"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/secrets"
+)
+
+// Probes that don't speak HTTP report these status codes so results, the
+// summary's uptime math and alerting treat every check type alike
+const (
+	StatusUp   = http.StatusOK
+	StatusDown = 0
+)
+
+// MaxRunDuration bounds one RunWithRetries call, every attempt and step
+// included. Schedulers lease a check for longer than this, so a run in
+// progress never loses its lease to another runner.
+const MaxRunDuration = 10 * time.Minute
+
+// Outcome is what a probe observed, before it is stored as a CheckResult
+type Outcome struct {
+	StatusCode     int                 `json:"status_code"`
+	Success        bool                `json:"success"`
+	ResponseTimeMs int64               `json:"response_time_ms"`
+	ConnectTimeMs  *int64              `json:"connect_time_ms,omitempty"`
+	ErrorMessage   string              `json:"error_message,omitempty"`
+	Cert           *CertInfo           `json:"cert,omitempty"`  // HTTPS only
+	Steps          []models.StepResult `json:"steps,omitempty"` // Multi-step only
+	FailedStep     *int                `json:"failed_step,omitempty"`
+}
+
+// Secrets are a check's credentials in plain text. The server opens them
+// from the check's encrypted columns; remote agents receive them with the
+// check since they don't hold the encryption key.
+type Secrets struct {
+	AuthSecret string            `json:"auth_secret,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Variables  map[string]string `json:"variables,omitempty"`
+}
+
+// OpenSecrets decrypts the check's auth secret, secret headers and secret
+// variables
+func OpenSecrets(check models.Check) (Secrets, error) {
+	var sec Secrets
+	var err error
+	if check.AuthType != models.CheckAuthNone {
+		if sec.AuthSecret, err = secrets.Decrypt(check.AuthSecret); err != nil {
+			return sec, fmt.Errorf("decrypting auth secret: %v", err)
+		}
+	}
+	if sec.Headers, err = decryptSecretMap(check.SecretHeaders); err != nil {
+		return sec, fmt.Errorf("secret headers: %v", err)
+	}
+	if sec.Variables, err = decryptSecretMap(check.SecretVariables); err != nil {
+		return sec, fmt.Errorf("secret variables: %v", err)
+	}
+	return sec, nil
+}
+
+// Run probes the check with the probe for its type. A nil sec opens the
+// check's own encrypted secrets.
+func Run(check models.Check, sec *Secrets) Outcome {
+	return run(check, sec, time.Now().Add(MaxRunDuration))
+}
+
+// run is Run with multi-step checks cut off at deadline
+func run(check models.Check, sec *Secrets, deadline time.Time) Outcome {
+	if sec == nil {
+		opened, err := OpenSecrets(check)
+		if err != nil {
+			log.Printf("Check %d (%s) failed: %v", check.ID, check.Name, err)
+			return Outcome{StatusCode: StatusDown, ErrorMessage: err.Error()}
+		}
+		sec = &opened
+	}
+
+	var outcome Outcome
+	switch check.Type {
+	case models.CheckTypeTCP:
+		outcome = probeTCP(check)
+	case models.CheckTypeDNS:
+		outcome = probeDNS(check)
+	case models.CheckTypeMultiStep:
+		outcome = probeMultiStep(check, *sec, deadline)
+	default:
+		outcome = probeHTTP(check, *sec)
+	}
+
+	if outcome.ErrorMessage != "" {
+		log.Printf("Check %d (%s) failed: %s", check.ID, check.Name, outcome.ErrorMessage)
+	} else if outcome.Success {
+		log.Printf("Check %d (%s) succeeded: %d in %dms", check.ID, check.Name, outcome.StatusCode, outcome.ResponseTimeMs)
+	} else {
+		log.Printf("Check %d (%s) returned: %d in %dms", check.ID, check.Name, outcome.StatusCode, outcome.ResponseTimeMs)
+	}
+	return outcome
+}
+
+// RunWithRetries runs the check, re-probing up to check.Retries times on
+// failure. Fast retries: a failure that clears on an immediate re-probe is
+// a blip and only the final attempt counts. The whole call stays within
+// MaxRunDuration: retries stop once there isn't time for another request.
+func RunWithRetries(check models.Check, sec *Secrets) Outcome {
+	deadline := time.Now().Add(MaxRunDuration)
+	outcome := run(check, sec, deadline)
+	for attempt := 1; attempt <= check.Retries && !outcome.Success; attempt++ {
+		if time.Until(deadline) < requestTimeout(check) {
+			log.Printf("Check %d (%s) out of time after %d of %d retries", check.ID, check.Name, attempt-1, check.Retries)
+			break
+		}
+		log.Printf("Check %d (%s) retry %d/%d", check.ID, check.Name, attempt, check.Retries)
+		outcome = run(check, sec, deadline)
+	}
+	return outcome
+}
+
+// probeHTTP sends the check's request; any 2xx response is up unless the
+// check's assertions say otherwise. For https targets the peer certificate
+// chain is captured as well.
+func probeHTTP(check models.Check, sec Secrets) Outcome {
+	req, err := buildHTTPRequest(check, sec)
+	if err != nil {
+		return Outcome{StatusCode: StatusDown, ErrorMessage: err.Error()}
+	}
+
+	startTime := time.Now()
+	capture := &certCapture{host: req.URL.Hostname()}
+	client := newHTTPClient(check, capture.tlsConfig(), nil)
+	resp, err := client.Do(req)
+	outcome := Outcome{
+		ResponseTimeMs: time.Since(startTime).Milliseconds(),
+		Cert:           capture.result(),
+	}
+	if err != nil {
+		outcome.StatusCode = StatusDown
+		outcome.ErrorMessage = err.Error()
+		return outcome
+	}
+	defer resp.Body.Close()
+	outcome.StatusCode = resp.StatusCode
+	outcome.Success, _, outcome.ErrorMessage = checkHTTPResponse(resp, check.Assertions, outcome.ResponseTimeMs, false)
+	return outcome
+}
+
+// requestTimeout is how long one HTTP request of the check may take
+func requestTimeout(check models.Check) time.Duration {
+	timeout := check.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = models.DefaultCheckTimeoutSeconds
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// newHTTPClient builds a client with the check's timeout and redirect limit
+func newHTTPClient(check models.Check, tlsConfig *tls.Config, jar http.CookieJar) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.DisableKeepAlives = true
+	maxRedirects := models.DefaultCheckMaxRedirects
+	if check.MaxRedirects != nil {
+		maxRedirects = *check.MaxRedirects
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   requestTimeout(check),
+		Jar:       jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Past the limit, the redirect itself is the response
+			if len(via) > maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+// checkHTTPResponse applies the default 2xx rule (unless a status_code
+// assertion replaces it) and the assertions to a response. The body is
+// read when an assertion needs it or keepBody is set, and returned.
+func checkHTTPResponse(resp *http.Response, assertions []models.Assertion, responseTimeMs int64, keepBody bool) (bool, []byte, string) {
+	statusOK := isStatusUp(resp.StatusCode)
+	if assertion.HasStatusAssertion(assertions) {
+		statusOK = true // The status_code assertion decides instead
+	}
+
+	observed := assertion.Response{
+		StatusCode:     resp.StatusCode,
+		Header:         resp.Header,
+		ResponseTimeMs: responseTimeMs,
+	}
+	if keepBody || assertion.NeedsBody(assertions) {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, assertion.MaxBodyBytes+1))
+		if err != nil {
+			return false, nil, fmt.Sprintf("reading body: %v", err)
+		}
+		if len(body) > assertion.MaxBodyBytes {
+			body = body[:assertion.MaxBodyBytes]
+			observed.BodyTruncated = true
+		}
+		observed.Body = body
+	}
+	if len(assertions) == 0 {
+		return statusOK, observed.Body, ""
+	}
+
+	failures := assertion.Evaluate(assertions, observed)
+	if statusOK && len(failures) == 0 {
+		return true, observed.Body, ""
+	}
+	var messages []string
+	if !statusOK {
+		messages = append(messages, fmt.Sprintf("status %d is not 2xx", resp.StatusCode))
+	}
+	for _, f := range failures {
+		messages = append(messages, f.String())
+	}
+	return false, observed.Body, truncateErrorMessage(strings.Join(messages, "; "))
+}
+
+// buildHTTPRequest builds the check's request with its method, body,
+// headers and credentials
+func buildHTTPRequest(check models.Check, sec Secrets) (*http.Request, error) {
+	method := check.HTTPMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if check.HTTPBody != "" {
+		body = strings.NewReader(check.HTTPBody)
+	}
+	req, err := http.NewRequest(method, check.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range check.HTTPHeaders {
+		req.Header.Set(name, value)
+	}
+	for name, value := range sec.Headers {
+		req.Header.Set(name, value)
+	}
+	applyHostHeader(req)
+
+	switch check.AuthType {
+	case models.CheckAuthBasic:
+		req.SetBasicAuth(check.AuthUsername, sec.AuthSecret)
+	case models.CheckAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+sec.AuthSecret)
+	}
+	return req, nil
+}
+
+// applyHostHeader moves a Host header to req.Host, since Go ignores it in
+// the header map
+func applyHostHeader(req *http.Request) {
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+}
+
+// decryptSecretMap opens an encrypted JSON object of strings
+func decryptSecretMap(sealed string) (map[string]string, error) {
+	if sealed == "" {
+		return nil, nil
+	}
+	plain, err := secrets.Decrypt(sealed)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if err := json.Unmarshal([]byte(plain), &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// isStatusUp returns true if status code indicates UP (2xx)
+func isStatusUp(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// truncateErrorMessage keeps a message within CheckResult.ErrorMessage
+func truncateErrorMessage(msg string) string {
+	const max = 1024
+	if len(msg) > max {
+		return strings.ToValidUTF8(msg[:max-3], "") + "..."
+	}
+	return msg
+}
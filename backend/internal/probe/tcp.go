@@ -0,0 +1,73 @@
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+const (
+	tcpProbeTimeout = 10 * time.Second
+	tcpMaxReadBytes = 4096 // Enough for any banner we'd match against
+)
+
+// probeTCP connects to the check's host:port. With TCPSend it writes the
+// payload after connecting; with TCPExpect it reads until the response
+// contains the expected substring, the read limit, or the deadline.
+func probeTCP(check models.Check) Outcome {
+	startTime := time.Now()
+	outcome := Outcome{StatusCode: StatusDown}
+	fail := func(format string, args ...interface{}) Outcome {
+		outcome.ResponseTimeMs = time.Since(startTime).Milliseconds()
+		outcome.ErrorMessage = fmt.Sprintf(format, args...)
+		return outcome
+	}
+
+	conn, err := net.DialTimeout("tcp", check.URL, tcpProbeTimeout)
+	connectMs := time.Since(startTime).Milliseconds()
+	if err != nil {
+		return fail("connect failed: %v", err)
+	}
+	defer conn.Close()
+	outcome.ConnectTimeMs = &connectMs
+	conn.SetDeadline(startTime.Add(tcpProbeTimeout))
+
+	if check.TCPSend != "" {
+		if _, err := conn.Write([]byte(check.TCPSend)); err != nil {
+			return fail("send failed: %v", err)
+		}
+	}
+
+	if check.TCPExpect != "" {
+		expect := []byte(check.TCPExpect)
+		buf := make([]byte, 0, tcpMaxReadBytes)
+		chunk := make([]byte, 512)
+		for !bytes.Contains(buf, expect) {
+			if len(buf) >= tcpMaxReadBytes {
+				return fail("expected %q not found in first %d bytes", check.TCPExpect, tcpMaxReadBytes)
+			}
+			n, err := conn.Read(chunk)
+			buf = append(buf, chunk[:n]...)
+			if err != nil && !bytes.Contains(buf, expect) {
+				return fail("expected %q, got %q (%v)", check.TCPExpect, truncateBanner(buf), err)
+			}
+		}
+	}
+
+	outcome.StatusCode = StatusUp
+	outcome.Success = true
+	outcome.ResponseTimeMs = time.Since(startTime).Milliseconds()
+	return outcome
+}
+
+// truncateBanner keeps error messages within CheckResult.ErrorMessage
+func truncateBanner(b []byte) string {
+	const max = 200
+	if len(b) > max {
+		return string(b[:max]) + "..."
+	}
+	return string(b)
+}
@@ -0,0 +1,95 @@
+package probe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CertInfo is the peer certificate chain seen during an HTTPS check
+type CertInfo struct {
+	ExpiresAt     time.Time `json:"expires_at"` // Earliest NotAfter across the chain
+	Issuer        string    `json:"issuer"`
+	Subject       string    `json:"subject"`
+	SANs          []string  `json:"sans"`
+	HostnameValid bool      `json:"hostname_valid"`
+	ChainValid    bool      `json:"chain_valid"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// certCapture records the first TLS handshake of a check run. Redirects may
+// open more connections to other hosts; only the check's own target counts.
+type certCapture struct {
+	host string // Check target host, for handshakes without SNI (IP targets)
+	mu   sync.Mutex
+	info *CertInfo
+}
+
+// tlsConfig verifies certificates itself instead of letting crypto/tls do
+// it, so the chain is captured even when verification fails. A failed
+// verification still aborts the handshake, exactly as the default would.
+func (c *certCapture) tlsConfig() *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				cs.ServerName = c.host
+			}
+			info, err := verifyPeerChain(cs)
+			c.mu.Lock()
+			if c.info == nil {
+				c.info = info
+			}
+			c.mu.Unlock()
+			return err
+		},
+	}
+}
+
+func (c *certCapture) result() *CertInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// verifyPeerChain performs the standard chain and hostname verification
+// and describes the chain regardless of the outcome
+func verifyPeerChain(cs tls.ConnectionState) (*CertInfo, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return nil, errors.New("tls: server presented no certificates")
+	}
+	leaf := cs.PeerCertificates[0]
+	info := &CertInfo{
+		ExpiresAt: leaf.NotAfter,
+		Issuer:    leaf.Issuer.String(),
+		Subject:   leaf.Subject.String(),
+		SANs:      append([]string{}, leaf.DNSNames...),
+	}
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+		if cert.NotAfter.Before(info.ExpiresAt) {
+			info.ExpiresAt = cert.NotAfter
+		}
+	}
+
+	hostErr := leaf.VerifyHostname(cs.ServerName)
+	info.HostnameValid = hostErr == nil
+	_, chainErr := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates})
+	info.ChainValid = chainErr == nil
+
+	switch {
+	case chainErr != nil:
+		info.Error = chainErr.Error()
+		return info, chainErr
+	case hostErr != nil:
+		info.Error = hostErr.Error()
+		return info, hostErr
+	}
+	return info, nil
+}
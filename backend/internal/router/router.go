@@ -33,6 +33,20 @@ func Setup(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 	// Health check
 	app.Get("/health", handlers.HealthCheck)
 
+	// OTLP/HTTP receivers at the spec's default paths so SDK exporters can
+	// point OTEL_EXPORTER_OTLP_ENDPOINT at the API host directly
+	otlpRoutes := app.Group("/v1")
+	otlpRoutes.Post("/traces",
+		middleware.RateLimitByAPIKey(1000, time.Minute),
+		middleware.APIKeyAuthWithScope(db, models.ScopeTracesWrite, models.ScopeAll),
+		handlers.OTLPTraces(db),
+	)
+	otlpRoutes.Post("/logs",
+		middleware.RateLimitByAPIKey(1000, time.Minute),
+		middleware.APIKeyAuthWithScope(db, models.ScopeLogsWrite, models.ScopeAll),
+		handlers.OTLPLogs(db),
+	)
+
 	// API v1
 	v1 := app.Group("/api/v1")
 
@@ -62,6 +76,28 @@ func Setup(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 		handlers.IngestLog(db),
 	)
 
+	// Trace ingestion route (API key auth - must be registered before protected group)
+	v1.Post("/traces",
+		middleware.RateLimitByAPIKey(1000, time.Minute), // 1000 req/min per org
+		middleware.APIKeyAuthWithScope(db, models.ScopeTracesWrite, models.ScopeAll),
+		handlers.IngestTraces(db),
+	)
+
+	// Heartbeat pings (public, the token in the URL identifies the check)
+	v1.Get("/heartbeat/:token", middleware.RateLimitHeartbeat(), handlers.HeartbeatPing(db, handlers.HeartbeatSuccess))
+	v1.Post("/heartbeat/:token", middleware.RateLimitHeartbeat(), handlers.HeartbeatPing(db, handlers.HeartbeatSuccess))
+	v1.Get("/heartbeat/:token/start", middleware.RateLimitHeartbeat(), handlers.HeartbeatPing(db, handlers.HeartbeatStart))
+	v1.Post("/heartbeat/:token/start", middleware.RateLimitHeartbeat(), handlers.HeartbeatPing(db, handlers.HeartbeatStart))
+	v1.Get("/heartbeat/:token/fail", middleware.RateLimitHeartbeat(), handlers.HeartbeatPing(db, handlers.HeartbeatFail))
+	v1.Post("/heartbeat/:token/fail", middleware.RateLimitHeartbeat(), handlers.HeartbeatPing(db, handlers.HeartbeatFail))
+
+	// Probe agent API (public, the agent token identifies the agent). The
+	// auth is per route: a group middleware on /agent would also match /agents.
+	agentAuth := middleware.AgentAuth(db)
+	v1.Post("/agent/register", agentAuth, handlers.AgentRegister(db))
+	v1.Post("/agent/claim", agentAuth, handlers.AgentClaim(db))
+	v1.Post("/agent/results", agentAuth, handlers.AgentPushResults(db))
+
 	// Stripe webhook (public, verified by signature - must be registered before protected group)
 	v1.Post("/billing/webhook", handlers.HandleStripeWebhook(db))
 
@@ -110,9 +146,56 @@ func Setup(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 	checks.Get("/:id/summary", handlers.GetCheckSummary(db))
 	checks.Get("/:id/alerts", handlers.GetCheckAlerts(db))
 
+	// Maintenance windows (suppress alerts while open)
+	maintenance := protected.Group("/maintenance-windows")
+	maintenance.Get("/", handlers.ListMaintenanceWindows(db))
+	maintenance.Post("/", handlers.CreateMaintenanceWindow(db))
+	maintenance.Get("/:id", handlers.GetMaintenanceWindow(db))
+	maintenance.Put("/:id", handlers.UpdateMaintenanceWindow(db))
+	maintenance.Delete("/:id", handlers.DeleteMaintenanceWindow(db))
+
+	// Probe agents (admin only for create/delete)
+	agents := protected.Group("/agents")
+	agents.Get("/", handlers.ListAgents(db))
+	agents.Post("/", middleware.RequireAdmin(), handlers.CreateAgent(db))
+	agents.Delete("/:id", middleware.RequireAdmin(), handlers.DeleteAgent(db))
+
+	// TLS certificates seen by HTTPS checks
+	protected.Get("/certificates", handlers.ListCertificates(db))
+
 	// Alert routes (org-wide)
 	protected.Get("/alerts", handlers.GetOrgAlerts(db))
 
+	// Incidents (DOWN/DEGRADED alerts grouped until recovery)
+	incidents := protected.Group("/incidents")
+	incidents.Get("/", handlers.ListIncidents(db))
+	incidents.Get("/stats", handlers.GetIncidentStats(db))
+	incidents.Get("/:id", handlers.GetIncident(db))
+	incidents.Post("/:id/acknowledge", handlers.AcknowledgeIncident(db))
+	incidents.Post("/:id/resolve", handlers.ResolveIncident(db))
+	incidents.Put("/:id/assignee", handlers.AssignIncident(db))
+	incidents.Post("/:id/comments", handlers.AddIncidentComment(db))
+
+	// Escalation policies (admin only for create/update/delete)
+	escalation := protected.Group("/escalation-policies")
+	escalation.Get("/", handlers.ListEscalationPolicies(db))
+	escalation.Get("/:id", handlers.GetEscalationPolicy(db))
+	escalation.Post("/", middleware.RequireAdmin(), handlers.CreateEscalationPolicy(db))
+	escalation.Put("/:id", middleware.RequireAdmin(), handlers.UpdateEscalationPolicy(db))
+	escalation.Delete("/:id", middleware.RequireAdmin(), handlers.DeleteEscalationPolicy(db))
+
+	// On-call schedules (admin only for create/update/delete)
+	onCall := protected.Group("/on-call-schedules")
+	onCall.Get("/", handlers.ListOnCallSchedules(db))
+	onCall.Get("/:id", handlers.GetOnCallSchedule(db))
+	onCall.Post("/", middleware.RequireAdmin(), handlers.CreateOnCallSchedule(db))
+	onCall.Put("/:id", middleware.RequireAdmin(), handlers.UpdateOnCallSchedule(db))
+	onCall.Delete("/:id", middleware.RequireAdmin(), handlers.DeleteOnCallSchedule(db))
+	onCall.Get("/:id/on-call", handlers.WhoIsOnCall(db))
+	onCall.Get("/:id/overrides", handlers.ListOnCallOverrides(db))
+	onCall.Post("/:id/overrides", middleware.RequireAdmin(), handlers.CreateOnCallOverride(db))
+	onCall.Delete("/:id/overrides/:override_id", middleware.RequireAdmin(), handlers.DeleteOnCallOverride(db))
+
 	// Notification settings routes (admin only)
 	protected.Get("/notification-settings", handlers.GetNotificationSettings(db))
 	protected.Put("/notification-settings", middleware.RequireAdmin(), handlers.UpdateNotificationSettings(db))
@@ -137,6 +220,11 @@ func Setup(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 	protected.Post("/logs/search", handlers.SearchLogs(db))
 	protected.Get("/logs/facets", handlers.GetLogFacets(db))
 	protected.Post("/traces/search", handlers.SearchTraces(db))
+	protected.Post("/traces/metrics", handlers.GetREDMetrics(db))
+	protected.Get("/traces/:trace_id", handlers.GetTrace(db))
+
+	// Service dependency map (materialized from trace spans)
+	protected.Get("/service-map", handlers.GetServiceMap(db))
 
 	// Debug endpoints (development only - handler checks Environment)
 	debug := protected.Group("/debug")
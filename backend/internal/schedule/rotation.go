@@ -0,0 +1,102 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RotationPeriod is how long each shift of an on-call rotation lasts
+type RotationPeriod string
+
+const (
+	Daily  RotationPeriod = "daily"
+	Weekly RotationPeriod = "weekly"
+)
+
+// Rotation hands the shift to the next person at a fixed local wall-clock
+// time: every day, or every week on Weekday. Shifts are numbered from the
+// first handoff at or after Start. Handoffs follow Location's clock, so a
+// shift spanning a DST change is an hour shorter or longer.
+type Rotation struct {
+	Period   RotationPeriod
+	Hour     int
+	Minute   int
+	Weekday  time.Weekday // Weekly only
+	Location *time.Location
+	Start    time.Time
+}
+
+// ParseHandoff parses a handoff time of day, "HH:MM" on a 24-hour clock
+func ParseHandoff(s string) (hour, minute int, err error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) != 2 {
+		return 0, 0, fmt.Errorf("handoff time %q must be HH:MM", s)
+	}
+	hour, err = strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("handoff time %q must be HH:MM", s)
+	}
+	minute, err = strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("handoff time %q must be HH:MM", s)
+	}
+	return hour, minute, nil
+}
+
+// Shift returns the number of the shift in progress at t (0 for the first)
+// with its start and end. ok is false before the first shift starts.
+func (r Rotation) Shift(t time.Time) (n int, start, end time.Time, ok bool) {
+	first := r.lastHandoff(r.Start)
+	if first.Before(r.Start) {
+		first = r.handoffAfter(first, 1)
+	}
+	start = r.lastHandoff(t)
+	if start.Before(first) {
+		return 0, time.Time{}, time.Time{}, false
+	}
+	n = (dayNumber(start.In(r.loc())) - dayNumber(first.In(r.loc()))) / r.days()
+	return n, start, r.handoffAfter(start, 1), true
+}
+
+// lastHandoff is the latest handoff at or before t
+func (r Rotation) lastHandoff(t time.Time) time.Time {
+	local := t.In(r.loc())
+	y, m, d := local.Date()
+	if r.Period == Weekly {
+		d -= (int(local.Weekday()) - int(r.Weekday) + 7) % 7
+	}
+	h := time.Date(y, m, d, r.Hour, r.Minute, 0, 0, r.loc())
+	if h.After(t) {
+		h = r.handoffAfter(h, -1)
+	}
+	return h
+}
+
+// handoffAfter moves a handoff by n shifts on the local calendar
+func (r Rotation) handoffAfter(h time.Time, n int) time.Time {
+	local := h.In(r.loc())
+	y, m, d := local.Date()
+	return time.Date(y, m, d+n*r.days(), r.Hour, r.Minute, 0, 0, r.loc())
+}
+
+func (r Rotation) days() int {
+	if r.Period == Weekly {
+		return 7
+	}
+	return 1
+}
+
+func (r Rotation) loc() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+	return r.Location
+}
+
+// dayNumber counts calendar days, ignoring the clock and DST
+func dayNumber(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
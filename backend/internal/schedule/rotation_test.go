@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotationShift_Daily(t *testing.T) {
+	r := Rotation{
+		Period: Daily,
+		Hour:   9,
+		Start:  time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), // First handoff is the 3rd at 09:00
+	}
+	tests := []struct {
+		at    time.Time
+		n     int
+		start time.Time
+		ok    bool
+	}{
+		{time.Date(2026, 3, 3, 8, 59, 0, 0, time.UTC), 0, time.Time{}, false},
+		{time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), 0, time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC), 0, time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC), 1, time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 4, 1, 23, 0, 0, 0, time.UTC), 29, time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		n, start, end, ok := r.Shift(tt.at)
+		if ok != tt.ok || n != tt.n || !start.Equal(tt.start) {
+			t.Errorf("Shift(%v) = %d, %v, %v; want %d, %v, %v", tt.at, n, start, ok, tt.n, tt.start, tt.ok)
+		}
+		if ok && !end.Equal(start.Add(24*time.Hour)) {
+			t.Errorf("Shift(%v) ends %v, want a day after %v", tt.at, end, start)
+		}
+	}
+}
+
+func TestRotationShift_Weekly(t *testing.T) {
+	r := Rotation{
+		Period:  Weekly,
+		Hour:    10,
+		Minute:  30,
+		Weekday: time.Monday,
+		Start:   time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC), // A Monday handoff
+	}
+	tests := []struct {
+		at    time.Time
+		n     int
+		start time.Time
+	}{
+		{time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC), 0, time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 9, 10, 29, 0, 0, time.UTC), 0, time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 9, 10, 30, 0, 0, time.UTC), 1, time.Date(2026, 3, 9, 10, 30, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 22, 0, 0, 0, 0, time.UTC), 2, time.Date(2026, 3, 16, 10, 30, 0, 0, time.UTC)}, // Sunday
+	}
+	for _, tt := range tests {
+		n, start, end, ok := r.Shift(tt.at)
+		if !ok || n != tt.n || !start.Equal(tt.start) {
+			t.Errorf("Shift(%v) = %d, %v, %v; want %d, %v", tt.at, n, start, ok, tt.n, tt.start)
+		}
+		if !end.Equal(start.AddDate(0, 0, 7)) {
+			t.Errorf("Shift(%v) ends %v, want a week after %v", tt.at, end, start)
+		}
+	}
+}
+
+func TestRotationShift_DST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata")
+	}
+	r := Rotation{
+		Period:   Daily,
+		Hour:     9,
+		Location: ny,
+		Start:    time.Date(2026, 3, 7, 9, 0, 0, 0, ny),
+	}
+	// Clocks spring forward on March 8; handoffs stay at 09:00 local
+	n, start, end, ok := r.Shift(time.Date(2026, 3, 8, 12, 0, 0, 0, ny))
+	if !ok || n != 1 || !start.Equal(time.Date(2026, 3, 8, 9, 0, 0, 0, ny)) {
+		t.Fatalf("Shift = %d, %v, %v", n, start, ok)
+	}
+	if got := end.Sub(start); got != 24*time.Hour {
+		t.Errorf("shift after the change lasts %v, want 24h", got)
+	}
+	_, start, end, _ = r.Shift(time.Date(2026, 3, 7, 12, 0, 0, 0, ny))
+	if got := end.Sub(start); got != 23*time.Hour {
+		t.Errorf("shift across the change lasts %v, want 23h", got)
+	}
+}
+
+func TestParseHandoff(t *testing.T) {
+	if h, m, err := ParseHandoff("09:30"); err != nil || h != 9 || m != 30 {
+		t.Errorf("ParseHandoff(09:30) = %d, %d, %v", h, m, err)
+	}
+	for _, s := range []string{"", "9", "24:00", "12:60", "12:5", "ab:cd"} {
+		if _, _, err := ParseHandoff(s); err == nil {
+			t.Errorf("ParseHandoff(%q) succeeded, want an error", s)
+		}
+	}
+}
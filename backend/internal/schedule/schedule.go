@@ -0,0 +1,245 @@
+// Package schedule parses recurrence rules for maintenance windows: standard
+// five-field cron expressions and a subset of iCalendar RRULEs, which are
+// translated to the equivalent cron fields. It also numbers the shifts of
+// on-call rotations.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearchYears bounds Next for rules that can never match, like Feb 30
+const maxSearchYears = 5
+
+// Schedule is a parsed recurrence. Each field is a bitset of allowed values.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// Cron matches either day field when both are restricted
+	domAny, dowAny bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// 7 is accepted as Sunday and folded into 0
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+// Parse parses a cron expression ("30 2 * * SAT") or an RRULE
+// ("RRULE:FREQ=WEEKLY;BYDAY=SA;BYHOUR=2;BYMINUTE=30")
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	upper := strings.ToUpper(expr)
+	if strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=") {
+		return parseRRule(upper)
+	}
+	return parseCron(expr)
+}
+
+func parseCron(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week)", expr)
+	}
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domAny = fields[2] == "*" || fields[2] == "?"
+	s.dowAny = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// parseField parses a comma-separated list of values, ranges (a-b) and
+// steps (*/n, a-b/n, a/n) into a bitset
+func parseField(raw string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(raw, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, raw)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, raw)
+			}
+		default:
+			v, err := parseValue(rangePart, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(raw string, f field) (int, error) {
+	if v, ok := f.names[strings.ToUpper(raw)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q (must be %d-%d)", f.name, raw, f.min, f.max)
+	}
+	return v, nil
+}
+
+// rruleDays maps RRULE BYDAY codes to cron day-of-week numbers
+var rruleDays = map[string]int{"SU": 0, "MO": 1, "TU": 2, "WE": 3, "TH": 4, "FR": 5, "SA": 6}
+
+// parseRRule translates the RRULE parts that have a cron equivalent: FREQ
+// (DAILY, WEEKLY or MONTHLY), BYDAY without ordinals, BYMONTHDAY, BYMONTH,
+// BYHOUR and BYMINUTE. INTERVAL, COUNT and UNTIL are not supported; a
+// window's start and end bound the recurrence instead.
+func parseRRule(rule string) (*Schedule, error) {
+	rule = strings.TrimPrefix(rule, "RRULE:")
+	parts := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		parts[kv[0]] = kv[1]
+	}
+
+	cron := map[string]string{"BYMINUTE": "0", "BYHOUR": "0", "BYMONTHDAY": "*", "BYMONTH": "*", "BYDAY": "*"}
+	switch parts["FREQ"] {
+	case "DAILY":
+	case "WEEKLY":
+		if parts["BYDAY"] == "" {
+			return nil, fmt.Errorf("RRULE with FREQ=WEEKLY must set BYDAY")
+		}
+	case "MONTHLY":
+		if parts["BYMONTHDAY"] == "" {
+			return nil, fmt.Errorf("RRULE with FREQ=MONTHLY must set BYMONTHDAY")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported RRULE FREQ %q (must be DAILY, WEEKLY or MONTHLY)", parts["FREQ"])
+	}
+	for key, value := range parts {
+		switch key {
+		case "FREQ":
+		case "INTERVAL":
+			if value != "1" {
+				return nil, fmt.Errorf("RRULE INTERVAL is not supported")
+			}
+		case "BYMINUTE", "BYHOUR", "BYMONTHDAY", "BYMONTH":
+			cron[key] = value
+		case "BYDAY":
+			var days []string
+			for _, code := range strings.Split(value, ",") {
+				d, ok := rruleDays[code]
+				if !ok {
+					return nil, fmt.Errorf("unsupported RRULE BYDAY %q", code)
+				}
+				days = append(days, strconv.Itoa(d))
+			}
+			cron[key] = strings.Join(days, ",")
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", key)
+		}
+	}
+	return parseCron(strings.Join([]string{cron["BYMINUTE"], cron["BYHOUR"], cron["BYMONTHDAY"], cron["BYMONTH"], cron["BYDAY"]}, " "))
+}
+
+// Next returns the first matching minute strictly after t, in t's location,
+// or the zero time if there is none within a few years
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+	for t.Before(limit) {
+		y, mo, d := t.Date()
+		switch {
+		case !has(s.month, int(mo)):
+			t = time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			next := time.Date(y, mo, d, t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// DST fall-back repeats the hour; step past it
+				next = t.Add(time.Hour).Truncate(time.Minute)
+			}
+			t = next
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule that a restricted day-of-month and a
+// restricted day-of-week match if either does
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domOK := has(s.dom, t.Day())
+	dowOK := has(s.dow, int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,77 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 3, 4, 10, 15, 30, 0, time.UTC) // Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/20 * * * *", time.Date(2026, 3, 4, 10, 20, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC)},
+		{"30 1 * * SAT", time.Date(2026, 3, 7, 1, 30, 0, 0, time.UTC)},
+		{"0 0 1 */3 *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2026, 3, 4, 13, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)}, // Friday or the 13th
+		{"0 4 * * 7", time.Date(2026, 3, 8, 4, 0, 0, 0, time.UTC)},
+		{"RRULE:FREQ=WEEKLY;BYDAY=SA,SU;BYHOUR=3;BYMINUTE=30", time.Date(2026, 3, 7, 3, 30, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYMONTHDAY=15", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"FREQ=DAILY;BYHOUR=10;BYMINUTE=15", time.Date(2026, 3, 5, 10, 15, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNext_Location(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tzdata")
+	}
+	s, _ := Parse("0 2 * * *")
+	got := s.Next(time.Date(2026, 1, 10, 12, 0, 0, 0, ny))
+	if want := time.Date(2026, 1, 11, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got.UTC(), want)
+	}
+}
+
+func TestNext_Never(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next = %v, want zero", got)
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+		"RRULE:FREQ=HOURLY",
+		"RRULE:FREQ=WEEKLY",
+		"RRULE:FREQ=DAILY;INTERVAL=2",
+		"RRULE:FREQ=WEEKLY;BYDAY=1MO",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = nil error", expr)
+		}
+	}
+}
@@ -88,11 +88,13 @@ var AllowedSortDirs = map[string]bool{
 // Resource-specific allowed fields
 var ChecksAllowedFields = map[string]bool{
     "name":         true,
+    "type":         true,
     "url":          true,
     "service_name": true,
     "environment":  true,
     "region":       true,
     "status_code":  true,
+    "state":        true,
     "is_active":    true,
     "created_at":   true,
     "updated_at":   true,
@@ -105,7 +107,12 @@ var CheckResultsAllowedFields = map[string]bool{
     "region":           true,
     "status_code":      true,
     "response_time_ms": true,
+    "connect_time_ms":  true,
     "success":          true,
+    "failed_step":      true,
+    "state":            true,
+    "in_maintenance":   true,
+    "blocked_by":       true,
     "trace_id":         true,
     "created_at":       true,
 }
@@ -0,0 +1,87 @@
+// Package secrets encrypts credentials stored in the database (check auth
+// secrets and secret headers) with AES-256-GCM
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Ciphertexts are tagged with the format version so the scheme can change later
+const prefix = "v1:"
+
+var aead cipher.AEAD
+
+// ErrNotInitialized is returned when Init has not been called
+var ErrNotInitialized = errors.New("secrets: encryption key not initialized")
+
+// Init sets the encryption key. A base64 value that decodes to 32 bytes is
+// used as-is; any other value is hashed with SHA-256 into a key.
+func Init(key string) error {
+	if key == "" {
+		return errors.New("secrets: encryption key is empty")
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		sum := sha256.Sum256([]byte(key))
+		raw = sum[:]
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	aead = gcm
+	return nil
+}
+
+// Encrypt seals plaintext under a random nonce. The empty string stays empty
+// so unset secrets need no special casing.
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	if aead == nil {
+		return "", ErrNotInitialized
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("secrets: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt
+func Decrypt(ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	if aead == nil {
+		return "", ErrNotInitialized
+	}
+	encoded, ok := strings.CutPrefix(ciphertext, prefix)
+	if !ok {
+		return "", errors.New("secrets: unknown ciphertext format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("secrets: malformed ciphertext")
+	}
+	nonce, body := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, body, nil)
+	if err != nil {
+		return "", errors.New("secrets: decryption failed (wrong key?)")
+	}
+	return string(plaintext), nil
+}
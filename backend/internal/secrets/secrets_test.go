@@ -0,0 +1,35 @@
+package secrets
+
+import "testing"
+
+func TestRoundTrip(t *testing.T) {
+	if err := Init("test-key"); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Encrypt("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed == "hunter2" || sealed[:len(prefix)] != prefix {
+		t.Fatalf("Encrypt returned %q", sealed)
+	}
+	again, _ := Encrypt("hunter2")
+	if again == sealed {
+		t.Error("nonce reused: equal plaintexts encrypted identically")
+	}
+	plain, err := Decrypt(sealed)
+	if err != nil || plain != "hunter2" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+
+	if empty, err := Encrypt(""); empty != "" || err != nil {
+		t.Errorf("Encrypt(\"\") = %q, %v", empty, err)
+	}
+
+	if err := Init("other-key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decrypt(sealed); err == nil {
+		t.Error("Decrypt with the wrong key succeeded")
+	}
+}
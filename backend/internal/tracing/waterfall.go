@@ -0,0 +1,207 @@
+// Package tracing assembles stored spans into trace-level views.
+package tracing
+
+import (
+	"sort"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+// WaterfallSpan is a span positioned within its trace
+type WaterfallSpan struct {
+	ID            uint             `json:"id"`
+	ServiceName   string           `json:"service_name"`
+	Environment   string           `json:"environment,omitempty"`
+	Operation     string           `json:"operation"`
+	Status        string           `json:"status"`
+	DurationMs    int              `json:"duration_ms"`
+	StartTime     time.Time        `json:"start_time"`
+	SpanID        string           `json:"span_id"`
+	ParentSpanID  string           `json:"parent_span_id,omitempty"`
+	Tags          models.JSONMap   `json:"tags,omitempty"`
+	Depth         int              `json:"depth"`
+	OffsetMs      int64            `json:"offset_ms"`    // Start relative to the trace start
+	SelfTimeMs    int64            `json:"self_time_ms"` // Duration not covered by child spans
+	MissingParent bool             `json:"missing_parent,omitempty"`
+	Children      []*WaterfallSpan `json:"children,omitempty"`
+}
+
+// Waterfall is a full trace assembled into a parent/child tree
+type Waterfall struct {
+	TraceID       string           `json:"trace_id"`
+	RootService   string           `json:"root_service"`
+	RootOperation string           `json:"root_operation"`
+	StartTime     time.Time        `json:"start_time"`
+	DurationMs    int64            `json:"duration_ms"`
+	SpanCount     int              `json:"span_count"`
+	ErrorCount    int              `json:"error_count"`
+	Services      []string         `json:"services"`
+	Roots         []*WaterfallSpan `json:"roots"`
+}
+
+// BuildWaterfall links spans through ParentSpanID and computes depth, offset
+// and self-time for each. Spans whose parent was never received become extra
+// roots flagged with MissingParent, so a partial trace still renders.
+func BuildWaterfall(traceID string, spans []models.TraceSpan) Waterfall {
+	w := Waterfall{
+		TraceID:   traceID,
+		SpanCount: len(spans),
+		Services:  []string{},
+		Roots:     []*WaterfallSpan{},
+	}
+	if len(spans) == 0 {
+		return w
+	}
+
+	sorted := make([]models.TraceSpan, len(spans))
+	copy(sorted, spans)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	traceStart := sorted[0].StartTime
+	var traceEnd time.Time
+	nodes := make([]*WaterfallSpan, len(sorted))
+	bySpanID := make(map[string]*WaterfallSpan, len(sorted))
+	services := make(map[string]bool)
+	for i, s := range sorted {
+		node := &WaterfallSpan{
+			ID:           s.ID,
+			ServiceName:  s.ServiceName,
+			Environment:  s.Environment,
+			Operation:    s.Operation,
+			Status:       s.Status,
+			DurationMs:   s.DurationMs,
+			StartTime:    s.StartTime,
+			SpanID:       s.SpanID,
+			ParentSpanID: s.ParentSpanID,
+			Tags:         s.Tags,
+			OffsetMs:     s.StartTime.Sub(traceStart).Milliseconds(),
+		}
+		nodes[i] = node
+		// Keep the first copy if a span was delivered twice
+		if _, dup := bySpanID[s.SpanID]; !dup {
+			bySpanID[s.SpanID] = node
+		}
+		if end := spanEnd(s.StartTime, s.DurationMs); end.After(traceEnd) {
+			traceEnd = end
+		}
+		if s.Status == models.SpanStatusError {
+			w.ErrorCount++
+		}
+		if !services[s.ServiceName] {
+			services[s.ServiceName] = true
+			w.Services = append(w.Services, s.ServiceName)
+		}
+	}
+	w.StartTime = traceStart
+	w.DurationMs = traceEnd.Sub(traceStart).Milliseconds()
+
+	var trueRoots, orphans []*WaterfallSpan
+	for _, node := range nodes {
+		if node.ParentSpanID == "" {
+			trueRoots = append(trueRoots, node)
+			continue
+		}
+		parent, ok := bySpanID[node.ParentSpanID]
+		if !ok || parent == node {
+			node.MissingParent = true
+			orphans = append(orphans, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	w.Roots = append(trueRoots, orphans...)
+
+	visited := make(map[*WaterfallSpan]bool, len(nodes))
+	for _, root := range w.Roots {
+		assignDepth(root, 0, visited)
+	}
+	// Spans caught in a parent cycle are unreachable from any root; surface
+	// them as roots rather than dropping them
+	for _, node := range nodes {
+		if !visited[node] {
+			node.MissingParent = true
+			w.Roots = append(w.Roots, node)
+			assignDepth(node, 0, visited)
+		}
+	}
+
+	for _, node := range nodes {
+		node.SelfTimeMs = selfTime(node)
+	}
+
+	if len(w.Roots) > 0 {
+		w.RootService = w.Roots[0].ServiceName
+		w.RootOperation = w.Roots[0].Operation
+	}
+	return w
+}
+
+// assignDepth walks the subtree, stopping at nodes already seen so that
+// corrupt parent links cannot recurse forever
+func assignDepth(node *WaterfallSpan, depth int, visited map[*WaterfallSpan]bool) {
+	if visited[node] {
+		return
+	}
+	visited[node] = true
+	node.Depth = depth
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		if visited[child] {
+			continue
+		}
+		kept = append(kept, child)
+		assignDepth(child, depth+1, visited)
+	}
+	node.Children = kept
+}
+
+// selfTime is the span's duration minus the union of its children's
+// intervals, clipped to the span itself
+func selfTime(node *WaterfallSpan) int64 {
+	start := node.StartTime
+	end := spanEnd(node.StartTime, node.DurationMs)
+	total := end.Sub(start)
+	if len(node.Children) == 0 {
+		return total.Milliseconds()
+	}
+
+	type interval struct{ from, to time.Time }
+	intervals := make([]interval, 0, len(node.Children))
+	for _, child := range node.Children {
+		from := child.StartTime
+		to := spanEnd(child.StartTime, child.DurationMs)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			intervals = append(intervals, interval{from, to})
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].from.Before(intervals[j].from) })
+
+	var covered time.Duration
+	var curFrom, curTo time.Time
+	for i, iv := range intervals {
+		if i == 0 || iv.from.After(curTo) {
+			covered += curTo.Sub(curFrom)
+			curFrom, curTo = iv.from, iv.to
+			continue
+		}
+		if iv.to.After(curTo) {
+			curTo = iv.to
+		}
+	}
+	covered += curTo.Sub(curFrom)
+
+	return (total - covered).Milliseconds()
+}
+
+func spanEnd(start time.Time, durationMs int) time.Time {
+	return start.Add(time.Duration(durationMs) * time.Millisecond)
+}
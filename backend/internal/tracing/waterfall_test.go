@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+func span(id, parent, service string, offsetMs, durationMs int, status string) models.TraceSpan {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return models.TraceSpan{
+		ServiceName:  service,
+		Operation:    "op-" + id,
+		Status:       status,
+		DurationMs:   durationMs,
+		StartTime:    base.Add(time.Duration(offsetMs) * time.Millisecond),
+		SpanID:       id,
+		ParentSpanID: parent,
+	}
+}
+
+func TestBuildWaterfall_Tree(t *testing.T) {
+	spans := []models.TraceSpan{
+		// Deliberately out of order
+		span("c", "a", "db", 20, 30, models.SpanStatusOK),
+		span("a", "", "gateway", 0, 100, models.SpanStatusOK),
+		span("b", "a", "api", 10, 40, models.SpanStatusError),
+		span("d", "b", "cache", 15, 5, models.SpanStatusOK),
+	}
+	w := BuildWaterfall("t1", spans)
+
+	if w.RootService != "gateway" || w.DurationMs != 100 || w.ErrorCount != 1 || w.SpanCount != 4 {
+		t.Fatalf("summary = root %q dur %d errors %d count %d", w.RootService, w.DurationMs, w.ErrorCount, w.SpanCount)
+	}
+	if len(w.Roots) != 1 {
+		t.Fatalf("got %d roots, want 1", len(w.Roots))
+	}
+	root := w.Roots[0]
+	if len(root.Children) != 2 || root.Children[0].SpanID != "b" || root.Children[1].SpanID != "c" {
+		t.Fatalf("root children not ordered by start time: %+v", root.Children)
+	}
+	b := root.Children[0]
+	if b.Depth != 1 || b.OffsetMs != 10 || b.Children[0].Depth != 2 {
+		t.Errorf("b depth %d offset %d, d depth %d", b.Depth, b.OffsetMs, b.Children[0].Depth)
+	}
+	// b (10-50) and c (20-50) overlap, so together they cover 40ms of a's 100ms
+	if root.SelfTimeMs != 60 {
+		t.Errorf("root self time = %d, want 60", root.SelfTimeMs)
+	}
+	if b.SelfTimeMs != 35 {
+		t.Errorf("b self time = %d, want 35", b.SelfTimeMs)
+	}
+}
+
+func TestBuildWaterfall_MissingParentAndCycle(t *testing.T) {
+	spans := []models.TraceSpan{
+		span("a", "", "web", 0, 10, models.SpanStatusOK),
+		span("x", "gone", "worker", 5, 10, models.SpanStatusOK),
+		span("p", "q", "loop", 6, 1, models.SpanStatusOK),
+		span("q", "p", "loop", 7, 1, models.SpanStatusOK),
+	}
+	w := BuildWaterfall("t2", spans)
+
+	if w.RootService != "web" {
+		t.Errorf("RootService = %q, want web", w.RootService)
+	}
+	if len(w.Roots) != 3 {
+		t.Fatalf("got %d roots, want 3 (true root, orphan, cycle entry)", len(w.Roots))
+	}
+	if !w.Roots[1].MissingParent || w.Roots[1].SpanID != "x" {
+		t.Errorf("orphan not flagged: %+v", w.Roots[1])
+	}
+	cycle := w.Roots[2]
+	if len(cycle.Children) != 1 || len(cycle.Children[0].Children) != 0 {
+		t.Errorf("cycle was not broken: %+v", cycle)
+	}
+}
+
+func TestBuildWaterfall_Empty(t *testing.T) {
+	w := BuildWaterfall("t3", nil)
+	if w.SpanCount != 0 || w.Roots == nil {
+		t.Errorf("empty waterfall = %+v", w)
+	}
+}
@@ -1,12 +1,15 @@
 package worker
 
 import (
+    "context"
+    "fmt"
     "log"
-    "net/http"
+    "sort"
     "time"
 
     "github.com/oFuterman/light-house/internal/models"
     "github.com/oFuterman/light-house/internal/notifier"
+    "github.com/oFuterman/light-house/internal/probe"
     "gorm.io/gorm"
 )
 
@@ -20,30 +23,41 @@ type AlertMetadata struct {
     OrgID     uint
 }
 
-// StartCheckRunner starts the background worker that runs uptime checks
-func StartCheckRunner(db *gorm.DB) {
-    log.Println("Starting check runner worker...")
-    ticker := time.NewTicker(30 * time.Second)
+// StartCheckRunner starts the background worker that runs uptime checks.
+// Every instance polls for due checks, claims as many as it has free slots
+// and runs them with at most concurrency in flight. It stops claiming when
+// ctx is cancelled; checks already running finish under Drain.
+func StartCheckRunner(ctx context.Context, db *gorm.DB, concurrency int) {
+    log.Printf("Starting check runner worker (%s, concurrency %d)...", instanceID, concurrency)
+    slots := make(chan struct{}, concurrency)
+    ticker := time.NewTicker(schedulerPollInterval)
     defer ticker.Stop()
-    // Run immediately on start, then every 30 seconds
-    runDueChecks(db)
-    for range ticker.C {
-        runDueChecks(db)
+    // Run immediately on start, then on every poll
+    runDueChecks(db, slots)
+    sweepHeartbeats(db)
+    renotifyIncidents(db)
+    runEscalations(db)
+    for {
+        select {
+        case <-ctx.Done():
+            log.Println("Check runner stopped")
+            return
+        case <-ticker.C:
+            runDueChecks(db, slots)
+            sweepHeartbeats(db)
+            renotifyIncidents(db)
+            runEscalations(db)
+        }
     }
 }
 
-// runDueChecks finds and executes all checks that are due
-func runDueChecks(db *gorm.DB) {
-    var checks []models.Check
-    // Find active checks that are due
-    // A check is due if:
-    // - last_checked_at is NULL (never run), OR
-    // - last_checked_at + interval_seconds <= now
-    err := db.Where("is_active = ?", true).
-        Where("last_checked_at IS NULL OR last_checked_at + (interval_seconds * interval '1 second') <= ?", time.Now()).
-        Find(&checks).Error
+// runDueChecks claims due checks for the free slots and runs each in its
+// own goroutine, releasing the check and its slot when done. A check whose
+// probe panics is logged and released like any other.
+func runDueChecks(db *gorm.DB, slots chan struct{}) {
+    checks, err := claimChecks(db, cap(slots)-len(slots), dueCheckCondition, time.Now())
     if err != nil {
-        log.Printf("Error fetching due checks: %v", err)
+        log.Printf("Error claiming due checks: %v", err)
         return
     }
     if len(checks) == 0 {
@@ -51,42 +65,48 @@ func runDueChecks(db *gorm.DB) {
     }
     log.Printf("Running %d due checks", len(checks))
     for _, check := range checks {
-        go runCheck(db, check)
+        // Only this loop fills slots, so a slot counted free is still free
+        slots <- struct{}{}
+        track(func() {
+            defer func() { <-slots }()
+            defer releaseCheck(db, check)
+            runRecovered(fmt.Sprintf("check %d", check.ID), func() { runCheck(db, check) })
+        })
     }
 }
 
-// isStatusUp returns true if status code indicates UP (2xx)
-func isStatusUp(statusCode int) bool {
-    return statusCode >= 200 && statusCode < 300
-}
-
-// shouldTriggerAlert determines if an alert should be created based on status transition and suppression window
-func shouldTriggerAlert(prevStatus *int, newStatusCode int, lastAlertAt *time.Time) (shouldAlert bool, alertType models.AlertType) {
-    newIsUp := isStatusUp(newStatusCode)
-    // Determine previous state (nil = first check, treat as UP to avoid false DOWN alert)
-    prevIsUp := true
-    if prevStatus != nil {
-        prevIsUp = isStatusUp(*prevStatus)
+// shouldTriggerAlert determines if an alert should be created based on state transition and suppression window
+func shouldTriggerAlert(prevState models.CheckState, newState models.CheckState, lastAlertAt *time.Time) (shouldAlert bool, alertType models.AlertType) {
+    // Empty = first check, treat as UP to avoid a false alert
+    if prevState == "" {
+        prevState = models.CheckStateUp
     }
     // No state change = no alert
-    if prevIsUp == newIsUp {
+    if prevState == newState || newState == "" {
         return false, ""
     }
     // Check suppression window
     if lastAlertAt != nil && time.Since(*lastAlertAt) < alertSuppressionWindow {
         return false, ""
     }
-    // Determine alert type based on transition
-    if prevIsUp && !newIsUp {
+    // Determine alert type based on transition. Leaving DOWN is a recovery
+    // even when the check comes back slow.
+    switch {
+    case newState == models.CheckStateDown:
         return true, models.AlertTypeDown
-    }
-    if !prevIsUp && newIsUp {
+    case prevState == models.CheckStateDown:
+        return true, models.AlertTypeRecovery
+    case newState == models.CheckStateDegraded:
+        return true, models.AlertTypeDegraded
+    case prevState == models.CheckStateDegraded:
         return true, models.AlertTypeRecovery
     }
     return false, ""
 }
 
-// createAlert inserts an alert and updates the check's LastAlertAt
+// createAlert inserts an alert, files it under the check's incident and
+// updates the check's LastAlertAt. Returns nil if the alert couldn't be
+// stored or shouldn't notify.
 func createAlert(db *gorm.DB, check models.Check, alertType models.AlertType, statusCode int, errorMsg string) *AlertMetadata {
     now := time.Now()
     alert := models.Alert{
@@ -100,11 +120,18 @@ func createAlert(db *gorm.DB, check models.Check, alertType models.AlertType, st
         log.Printf("Error creating alert for check %d: %v", check.ID, err)
         return nil
     }
-    // Update check's LastAlertAt
-    if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Update("last_alert_at", now).Error; err != nil {
-        log.Printf("Error updating LastAlertAt for check %d: %v", check.ID, err)
+    notify := trackIncident(db, check, &alert)
+    // Update check's LastAlertAt. Only state transitions count toward the
+    // suppression window; certificate alerts must not mute a DOWN alert.
+    if alertType != models.AlertTypeCertExpiring {
+        if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Update("last_alert_at", now).Error; err != nil {
+            log.Printf("Error updating LastAlertAt for check %d: %v", check.ID, err)
+        }
     }
     log.Printf("Alert created: check=%d type=%s status=%d", check.ID, alertType, statusCode)
+    if !notify {
+        return nil
+    }
     return &AlertMetadata{
         Alert:     alert,
         CheckName: check.Name,
@@ -113,67 +140,248 @@ func createAlert(db *gorm.DB, check models.Check, alertType models.AlertType, st
     }
 }
 
-// runCheck executes a single HTTP check and stores the result
+// sendAlertNotifications delivers an alert in the background. Shutdown
+// waits for the send to finish, so a deploy doesn't drop it.
+func sendAlertNotifications(db *gorm.DB, metadata *AlertMetadata, check models.Check) {
+    track(func() {
+        if err := notifier.SendAllNotifications(db, metadata.Alert, check); err != nil {
+            log.Printf("Failed to send notifications for check %d: %v", check.ID, err)
+        }
+    })
+}
+
+// runCheck executes a single check with the probe for its type and stores the result
 func runCheck(db *gorm.DB, check models.Check) {
-    startTime := time.Now()
-    // Create HTTP client with timeout
-    client := &http.Client{
-        Timeout: 30 * time.Second,
-        CheckRedirect: func(req *http.Request, via []*http.Request) error {
-            // Allow up to 10 redirects
-            if len(via) >= 10 {
-                return http.ErrUseLastResponse
-            }
-            return nil
-        },
+    outcome := probe.RunWithRetries(check, nil)
+    if !RecordResult(db, check, resultFromOutcome(outcome)) {
+        return
+    }
+    if outcome.Cert != nil {
+        recordCertificate(db, &check, outcome.Cert)
+    }
+}
+
+// resultFromOutcome converts what a probe observed into a result to store
+func resultFromOutcome(outcome probe.Outcome) models.CheckResult {
+    return models.CheckResult{
+        StatusCode:     outcome.StatusCode,
+        ResponseTimeMs: outcome.ResponseTimeMs,
+        ConnectTimeMs:  outcome.ConnectTimeMs,
+        Success:        outcome.Success,
+        ErrorMessage:   outcome.ErrorMessage,
+        StepResults:    outcome.Steps,
+        FailedStep:     outcome.FailedStep,
     }
-    // Make the request
-    resp, err := client.Get(check.URL)
-    responseTime := time.Since(startTime).Milliseconds()
+}
+
+// RecordResult stores a check result, raises alerts on state
+// changes and updates the check's last status. Probes and heartbeat pings
+// both report through here. Returns false if the result couldn't be stored.
+//
+// During a maintenance window, or for a failure while a dependency is DOWN,
+// the result is stored and flagged but the check's state is held so nothing
+// alerts; a check still failing afterwards goes DOWN and alerts as usual.
+func RecordResult(db *gorm.DB, check models.Check, result models.CheckResult) bool {
+    return recordResult(db, check, result, result.Success)
+}
+
+// recordResult is RecordResult with the success that drives the check's
+// state given separately: for multi-region checks, the quorum's verdict
+// rather than the one region's result being stored.
+func recordResult(db *gorm.DB, check models.Check, result models.CheckResult, success bool) bool {
     now := time.Now()
-    result := models.CheckResult{
-        CheckID:        check.ID,
-        ResponseTimeMs: responseTime,
+    result.CheckID = check.ID
+    judged := result
+    judged.Success = success
+    state, consecutive := confirmState(db, check, judged)
+    hold := false
+    if window := activeMaintenance(db, check, now); window != nil {
+        result.InMaintenance = true
+        hold = true
+        log.Printf("Check %d (%s) in maintenance window %d (%s)", check.ID, check.Name, window.ID, window.Name)
     }
-    var errorMsg string
-    if err != nil {
-        // Request failed
-        result.Success = false
-        result.StatusCode = 0
-        result.ErrorMessage = err.Error()
-        errorMsg = err.Error()
-        log.Printf("Check %d (%s) failed: %v", check.ID, check.Name, err)
-    } else {
-        defer resp.Body.Close()
-        result.StatusCode = resp.StatusCode
-        result.Success = isStatusUp(resp.StatusCode)
-        if result.Success {
-            log.Printf("Check %d (%s) succeeded: %d in %dms", check.ID, check.Name, resp.StatusCode, responseTime)
-        } else {
-            log.Printf("Check %d (%s) returned: %d in %dms", check.ID, check.Name, resp.StatusCode, responseTime)
+    if !success && len(check.DependsOn) > 0 {
+        if parent := downDependency(db, check); parent != nil {
+            result.BlockedBy = &parent.ID
+            hold = true
+            log.Printf("Check %d (%s) failure blocked by dependency %d (%s)", check.ID, check.Name, parent.ID, parent.Name)
         }
     }
+    if hold {
+        state, consecutive = check.State, check.ConsecutiveFailures
+    }
+    result.State = state
     // Store the result
     if err := db.Create(&result).Error; err != nil {
         log.Printf("Error storing result for check %d: %v", check.ID, err)
-        return
+        return false
+    }
+    // Update the check's last status and last_checked_at. The state only
+    // changes if it is still the one this result was judged against: when
+    // regions report at once, the first write wins and alerts, the others
+    // find it moved and leave the alerting to it.
+    updates := map[string]interface{}{
+        "last_status":          result.StatusCode,
+        "state":                state,
+        "consecutive_failures": consecutive,
+        "last_checked_at":      now,
+    }
+    update := db.Model(&models.Check{}).Where("id = ?", check.ID)
+    if check.State == "" {
+        update = update.Where("state IS NULL OR state = ''")
+    } else {
+        update = update.Where("state = ?", check.State)
+    }
+    if res := update.Updates(updates); res.Error != nil {
+        // State unchanged, so the next run retries the transition
+        log.Printf("Error updating check %d status: %v", check.ID, res.Error)
+        return true
+    } else if res.RowsAffected == 0 {
+        log.Printf("Check %d (%s) state changed concurrently, not alerting", check.ID, check.Name)
+        if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Updates(map[string]interface{}{
+            "last_status":     result.StatusCode,
+            "last_checked_at": now,
+        }).Error; err != nil {
+            log.Printf("Error updating check %d status: %v", check.ID, err)
+        }
+        return true
     }
     // Check if we should trigger an alert
-    if shouldAlert, alertType := shouldTriggerAlert(check.LastStatus, result.StatusCode, check.LastAlertAt); shouldAlert {
-        if metadata := createAlert(db, check, alertType, result.StatusCode, errorMsg); metadata != nil {
-            go func() {
-                if err := notifier.SendAllNotifications(db, metadata.Alert, check); err != nil {
-                    log.Printf("Failed to send notifications for check %d: %v", check.ID, err)
-                }
-            }()
+    if shouldAlert, alertType := shouldTriggerAlert(check.State, state, check.LastAlertAt); shouldAlert {
+        if metadata := createAlert(db, check, alertType, result.StatusCode, result.ErrorMessage); metadata != nil {
+            sendAlertNotifications(db, metadata, check)
         }
+    } else if state == models.CheckStateUp && check.State != "" && check.State != state {
+        // A recovery inside the suppression window still ends the incident
+        resolveIncident(db, check, nil)
     }
-    // Update the check's last status and last_checked_at
-    updates := map[string]interface{}{
-        "last_status":     result.StatusCode,
-        "last_checked_at": now,
+    return true
+}
+
+// confirmState derives the check's state from a result about to be stored,
+// loading whatever recent history the check's rules need. Also returns the
+// new consecutive failure count.
+func confirmState(db *gorm.DB, check models.Check, result models.CheckResult) (models.CheckState, int) {
+    var recent []models.CheckResult
+    if n := historyNeeded(check, result); n > 0 {
+        var err error
+        recent, err = recentResults(db, check.ID, n)
+        if err != nil {
+            log.Printf("Error loading recent results for check %d: %v", check.ID, err)
+            // Without history: no DEGRADED, and failures confirm on the streak
+            check.FailureWindow, check.DegradedThresholdMs = 0, 0
+        }
+    }
+    return nextState(check, result, recent)
+}
+
+// historyNeeded is how many stored results, newest first, nextState needs
+// to judge result
+func historyNeeded(check models.Check, result models.CheckResult) int {
+    if result.Success {
+        if check.DegradedThresholdMs > 0 && check.DegradedRuns > 1 {
+            return check.DegradedRuns - 1
+        }
+        return 0
+    }
+    if check.State != models.CheckStateDown && check.FailureWindow > 0 {
+        return check.FailureWindow - 1
+    }
+    return 0
+}
+
+// nextState applies the check's rules to a result and the results stored
+// before it (recent, newest first). A success is UP, or DEGRADED when over
+// the latency threshold; a failure (including a 2xx that failed an
+// assertion) turns the check DOWN only once the check's confirmation rule
+// is met, otherwise the previous state stands. Also returns the new
+// consecutive failure count.
+func nextState(check models.Check, result models.CheckResult, recent []models.CheckResult) (models.CheckState, int) {
+    if result.Success {
+        if isDegraded(check, result, recent) {
+            return models.CheckStateDegraded, 0
+        }
+        return models.CheckStateUp, 0
+    }
+    consecutive := check.ConsecutiveFailures + 1
+    if check.State == models.CheckStateDown {
+        return models.CheckStateDown, consecutive
+    }
+
+    threshold := check.FailureThreshold
+    if threshold < 1 {
+        threshold = 1
+    }
+    confirmed := consecutive >= threshold
+    if check.FailureWindow > 0 {
+        // M of the last K runs: this result plus the K-1 before it
+        if len(recent) > check.FailureWindow-1 {
+            recent = recent[:check.FailureWindow-1]
+        }
+        failures := 1
+        for _, r := range recent {
+            if !r.Success {
+                failures++
+            }
+        }
+        confirmed = failures >= threshold
     }
-    if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Updates(updates).Error; err != nil {
-        log.Printf("Error updating check %d status: %v", check.ID, err)
+
+    if confirmed {
+        return models.CheckStateDown, consecutive
+    }
+    log.Printf("Check %d (%s) failure %d not yet confirmed", check.ID, check.Name, consecutive)
+    return check.State, consecutive
+}
+
+// isDegraded applies the check's latency threshold to a successful result
+// and the DegradedRuns-1 results before it (recent, newest first). Any
+// failure among them means there isn't a run of slow successes yet.
+func isDegraded(check models.Check, result models.CheckResult, recent []models.CheckResult) bool {
+    if check.DegradedThresholdMs <= 0 {
+        return false
+    }
+    runs := check.DegradedRuns
+    if runs < 1 {
+        runs = 1
+    }
+    if len(recent) < runs-1 {
+        return false // Not enough history yet
+    }
+    latencies := []int64{result.ResponseTimeMs}
+    for _, r := range recent[:runs-1] {
+        if !r.Success {
+            return false
+        }
+        latencies = append(latencies, r.ResponseTimeMs)
+    }
+
+    if check.DegradedMode == models.DegradedP95 {
+        sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+        idx := int(float64(len(latencies)) * 0.95)
+        if idx >= len(latencies) {
+            idx = len(latencies) - 1
+        }
+        return latencies[idx] > check.DegradedThresholdMs
+    }
+    for _, ms := range latencies {
+        if ms <= check.DegradedThresholdMs {
+            return false
+        }
+    }
+    return true
+}
+
+// recentResults returns up to n of the check's latest stored results, newest first
+func recentResults(db *gorm.DB, checkID uint, n int) ([]models.CheckResult, error) {
+    var results []models.CheckResult
+    if n <= 0 {
+        return results, nil
     }
+    err := db.Select("success", "response_time_ms").
+        Where("check_id = ?", checkID).
+        Order("created_at DESC").
+        Limit(n).
+        Find(&results).Error
+    return results, err
 }
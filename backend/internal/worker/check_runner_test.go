@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/oFuterman/light-house/internal/models"
+)
+
+// history builds stored results, newest first: true for a success
+func history(successes ...bool) []models.CheckResult {
+	results := make([]models.CheckResult, len(successes))
+	for i, ok := range successes {
+		results[i] = models.CheckResult{Success: ok}
+	}
+	return results
+}
+
+func TestNextState_Consecutive(t *testing.T) {
+	failure := models.CheckResult{Success: false}
+	tests := []struct {
+		name        string
+		state       models.CheckState
+		failures    int
+		threshold   int
+		want        models.CheckState
+		consecutive int
+	}{
+		{"first failure confirms by default", models.CheckStateUp, 0, 0, models.CheckStateDown, 1},
+		{"below threshold keeps state", models.CheckStateUp, 1, 3, models.CheckStateUp, 2},
+		{"exactly threshold confirms", models.CheckStateUp, 2, 3, models.CheckStateDown, 3},
+		{"degraded stays until confirmed", models.CheckStateDegraded, 0, 2, models.CheckStateDegraded, 1},
+		{"already down stays down", models.CheckStateDown, 5, 3, models.CheckStateDown, 6},
+	}
+	for _, tt := range tests {
+		check := models.Check{State: tt.state, ConsecutiveFailures: tt.failures, FailureThreshold: tt.threshold}
+		state, consecutive := nextState(check, failure, nil)
+		if state != tt.want || consecutive != tt.consecutive {
+			t.Errorf("%s: got %s, %d; want %s, %d", tt.name, state, consecutive, tt.want, tt.consecutive)
+		}
+	}
+}
+
+func TestNextState_SuccessResetsStreak(t *testing.T) {
+	check := models.Check{State: models.CheckStateUp, ConsecutiveFailures: 2, FailureThreshold: 3}
+	state, consecutive := nextState(check, models.CheckResult{Success: true}, nil)
+	if state != models.CheckStateUp || consecutive != 0 {
+		t.Fatalf("success: got %s, %d; want UP, 0", state, consecutive)
+	}
+
+	// The next failure starts a new streak instead of confirming
+	check.ConsecutiveFailures = consecutive
+	state, consecutive = nextState(check, models.CheckResult{Success: false}, nil)
+	if state != models.CheckStateUp || consecutive != 1 {
+		t.Errorf("failure after reset: got %s, %d; want UP, 1", state, consecutive)
+	}
+}
+
+func TestNextState_MOfK(t *testing.T) {
+	failure := models.CheckResult{Success: false}
+	tests := []struct {
+		name   string
+		recent []models.CheckResult
+		want   models.CheckState
+	}{
+		// 3 of the last 5 runs, counting this failure
+		{"exactly M of K", history(false, true, false, true), models.CheckStateDown},
+		{"one short of M", history(true, true, false, true), models.CheckStateUp},
+		{"successes in between don't reset", history(true, false, true, false), models.CheckStateDown},
+		{"failures outside the window don't count", history(true, true, true, true, false, false), models.CheckStateUp},
+		{"short history counts what there is", history(false), models.CheckStateUp},
+	}
+	for _, tt := range tests {
+		check := models.Check{State: models.CheckStateUp, FailureThreshold: 3, FailureWindow: 5}
+		if got, _ := nextState(check, failure, tt.recent); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryNeeded(t *testing.T) {
+	check := models.Check{State: models.CheckStateUp, FailureWindow: 5, DegradedThresholdMs: 500, DegradedRuns: 3}
+	if n := historyNeeded(check, models.CheckResult{Success: false}); n != 4 {
+		t.Errorf("failure needs %d results, want 4", n)
+	}
+	if n := historyNeeded(check, models.CheckResult{Success: true}); n != 2 {
+		t.Errorf("success needs %d results, want 2", n)
+	}
+	check.State = models.CheckStateDown
+	if n := historyNeeded(check, models.CheckResult{Success: false}); n != 0 {
+		t.Errorf("failure while down needs %d results, want 0", n)
+	}
+}
+
+// latencies builds stored successes with the given response times, newest first
+func latencies(ms ...int64) []models.CheckResult {
+	results := make([]models.CheckResult, len(ms))
+	for i, v := range ms {
+		results[i] = models.CheckResult{Success: true, ResponseTimeMs: v}
+	}
+	return results
+}
+
+func TestNextState_Degraded(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   models.DegradedMode
+		runs   int
+		ms     int64
+		recent []models.CheckResult
+		want   models.CheckState
+	}{
+		{"single slow run", models.DegradedConsecutive, 1, 501, nil, models.CheckStateDegraded},
+		{"at the threshold is not slow", models.DegradedConsecutive, 1, 500, nil, models.CheckStateUp},
+		{"every run slow", models.DegradedConsecutive, 3, 900, latencies(600, 700), models.CheckStateDegraded},
+		{"one fast run among them", models.DegradedConsecutive, 3, 900, latencies(600, 400), models.CheckStateUp},
+		{"fast run recovers", models.DegradedConsecutive, 3, 100, latencies(600, 700), models.CheckStateUp},
+		{"not enough history", models.DegradedConsecutive, 3, 900, latencies(600), models.CheckStateUp},
+		{"failure among them", models.DegradedConsecutive, 3, 900, append(latencies(600), models.CheckResult{}), models.CheckStateUp},
+		{"runs older than the window ignored", models.DegradedConsecutive, 2, 900, latencies(600, 100), models.CheckStateDegraded},
+		{"p95 over with one fast run", models.DegradedP95, 3, 900, latencies(100, 700), models.CheckStateDegraded},
+		{"p95 under", models.DegradedP95, 3, 100, latencies(200, 300), models.CheckStateUp},
+	}
+	for _, tt := range tests {
+		check := models.Check{
+			State:               models.CheckStateUp,
+			DegradedThresholdMs: 500,
+			DegradedMode:        tt.mode,
+			DegradedRuns:        tt.runs,
+		}
+		result := models.CheckResult{Success: true, ResponseTimeMs: tt.ms}
+		if got, _ := nextState(check, result, tt.recent); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNextState_DegradedTransitions(t *testing.T) {
+	check := models.Check{
+		State:               models.CheckStateUp,
+		DegradedThresholdMs: 500,
+		DegradedMode:        models.DegradedConsecutive,
+		DegradedRuns:        2,
+	}
+	slow := models.CheckResult{Success: true, ResponseTimeMs: 800}
+
+	// UP -> DEGRADED once two runs in a row are slow
+	if got, _ := nextState(check, slow, latencies(700)); got != models.CheckStateDegraded {
+		t.Fatalf("slow runs: got %s, want DEGRADED", got)
+	}
+	check.State = models.CheckStateDegraded
+
+	// An unconfirmed failure leaves it DEGRADED
+	check.FailureThreshold = 2
+	if got, n := nextState(check, models.CheckResult{Success: false}, nil); got != models.CheckStateDegraded || n != 1 {
+		t.Fatalf("unconfirmed failure: got %s, %d; want DEGRADED, 1", got, n)
+	}
+
+	// DEGRADED -> UP on the first fast run
+	if got, _ := nextState(check, models.CheckResult{Success: true, ResponseTimeMs: 200}, latencies(800)); got != models.CheckStateUp {
+		t.Errorf("fast run: got %s, want UP", got)
+	}
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"log"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// downDependency returns the first DOWN check among the check's
+// dependencies and theirs, or nil. A failing parent's own failures are
+// blocked by its parents, so the walk goes all the way up rather than
+// stopping at parents that are still UP. Paused checks keep their last
+// state, so they never count as DOWN; the walk goes on past them.
+func downDependency(db *gorm.DB, check models.Check) *models.Check {
+	seen := map[int64]bool{int64(check.ID): true}
+	pending := []int64(check.DependsOn)
+	for len(pending) > 0 {
+		var ids []int64
+		for _, id := range pending {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		var parents []models.Check
+		err := db.Select("id", "name", "state", "is_active", "depends_on").
+			Where("org_id = ? AND id IN ?", check.OrgID, ids).
+			Find(&parents).Error
+		if err != nil {
+			log.Printf("Error loading dependencies for check %d: %v", check.ID, err)
+			return nil
+		}
+		pending = nil
+		for i := range parents {
+			if parents[i].IsActive && parents[i].State == models.CheckStateDown {
+				return &parents[i]
+			}
+			pending = append(pending, parents[i].DependsOn...)
+		}
+	}
+	return nil
+}
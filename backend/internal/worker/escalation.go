@@ -0,0 +1,312 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/notifier"
+	"github.com/oFuterman/light-house/internal/oncall"
+	"gorm.io/gorm"
+)
+
+const (
+	// An instance holds an incident this long while escalating it. If it
+	// dies first, another instance picks the escalation up afterwards.
+	escalationLease = 5 * time.Minute
+	escalationBatch = 100
+	// How long an escalation due during maintenance waits before retrying
+	maintenanceEscalationDelay = time.Minute
+)
+
+// startEscalation puts a newly opened incident on the check's escalation
+// policy, if it has one: the first level is due after its delay. Returns
+// false when the check has no usable policy.
+func startEscalation(db *gorm.DB, check models.Check, incident *models.Incident) bool {
+	if check.EscalationPolicyID == nil {
+		return false
+	}
+	var policy models.EscalationPolicy
+	if err := db.Where("id = ? AND org_id = ?", *check.EscalationPolicyID, check.OrgID).First(&policy).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Error loading escalation policy %d: %v", *check.EscalationPolicyID, err)
+		}
+		return false
+	}
+	if len(policy.Levels) == 0 {
+		return false
+	}
+	next := incident.TriggeredAt.Add(time.Duration(policy.Levels[0].DelayMinutes) * time.Minute)
+	incident.EscalationPolicyID = &policy.ID
+	incident.NextEscalationAt = &next
+	return true
+}
+
+// runEscalations notifies the next level of every triggered incident whose
+// escalation is due. State lives on the incident, so escalations pick up
+// where they left off after a restart.
+func runEscalations(db *gorm.DB) {
+	now := time.Now()
+	var incidents []models.Incident
+	err := db.Raw(`
+        UPDATE incidents SET next_escalation_at = ?
+        WHERE id IN (
+            SELECT id FROM incidents
+            WHERE state = ? AND next_escalation_at <= ?
+            ORDER BY next_escalation_at ASC
+            LIMIT ?
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING *`,
+		now.Add(escalationLease), models.IncidentStateTriggered, now, escalationBatch).
+		Scan(&incidents).Error
+	if err != nil {
+		log.Printf("Error claiming due escalations: %v", err)
+		return
+	}
+	for _, incident := range incidents {
+		escalate(db, incident, now)
+	}
+}
+
+// escalate notifies the incident's next level and schedules the one after
+func escalate(db *gorm.DB, incident models.Incident, now time.Time) {
+	var policy models.EscalationPolicy
+	if incident.EscalationPolicyID != nil {
+		err := db.First(&policy, *incident.EscalationPolicyID).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			log.Printf("Error loading escalation policy for incident %d: %v", incident.ID, err)
+			return // Retried once the lease expires
+		}
+	}
+	if len(policy.Levels) == 0 {
+		// Policy deleted: hand the incident back to plain repeats
+		stopEscalation(db, incident.ID, true)
+		return
+	}
+	var check models.Check
+	if err := db.First(&check, incident.CheckID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			stopEscalation(db, incident.ID, false)
+			return
+		}
+		log.Printf("Error loading check %d for incident %d: %v", incident.CheckID, incident.ID, err)
+		return
+	}
+	if activeMaintenance(db, check, now) != nil {
+		if err := db.Model(&incident).Update("next_escalation_at", now.Add(maintenanceEscalationDelay)).Error; err != nil {
+			log.Printf("Error postponing escalation of incident %d: %v", incident.ID, err)
+		}
+		return
+	}
+
+	level, repeats := incident.EscalationLevel, incident.EscalationRepeats
+	if level >= len(policy.Levels) {
+		level, repeats = 0, repeats+1 // Start the next cycle
+	}
+
+	alert, created, err := escalationAlert(db, check, incident, level, repeats, len(policy.Levels), now)
+	if err != nil {
+		log.Printf("Error creating escalation alert for incident %d: %v", incident.ID, err)
+		return
+	}
+	recipients := escalationRecipients(db, check.OrgID, policy.Levels[level].Targets)
+
+	updates := map[string]interface{}{
+		"escalation_level":   level + 1,
+		"escalation_repeats": repeats,
+		"next_escalation_at": policy.NextEscalation(level, repeats, now),
+		"last_notified_at":   now,
+	}
+	if created {
+		updates["alert_count"] = gorm.Expr("alert_count + 1")
+	}
+	if err := db.Model(&incident).Updates(updates).Error; err != nil {
+		log.Printf("Error updating escalation of incident %d: %v", incident.ID, err)
+	}
+	addIncidentEvent(db, incident.ID, models.IncidentEventEscalated, &alert.ID,
+		fmt.Sprintf("Level %d of %d: %s", level+1, len(policy.Levels), describeRecipients(recipients)))
+
+	if recipients.IsEmpty() {
+		log.Printf("Escalation level %d of incident %d has no recipients", level+1, incident.ID)
+		return
+	}
+	track(func() {
+		if err := notifier.Send(alert, check, recipients); err != nil {
+			log.Printf("Failed to send escalation for incident %d: %v", incident.ID, err)
+		}
+	})
+}
+
+// escalationAlert returns the alert a level notifies about: the one that
+// opened the incident for the very first level, otherwise a new repeat
+// alert of the incident. Also reports whether it created one.
+func escalationAlert(db *gorm.DB, check models.Check, incident models.Incident, level, repeats, levels int, now time.Time) (models.Alert, bool, error) {
+	var alert models.Alert
+	if level == 0 && repeats == 0 {
+		err := db.Where("incident_id = ?", incident.ID).Order("created_at ASC, id ASC").First(&alert).Error
+		if err == nil {
+			return alert, false, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return alert, false, err
+		}
+	}
+
+	alertType := models.AlertTypeDown
+	if check.State == models.CheckStateDegraded {
+		alertType = models.AlertTypeDegraded
+	}
+	statusCode := 0
+	if check.LastStatus != nil {
+		statusCode = *check.LastStatus
+	}
+	alert = models.Alert{
+		OrgID:      check.OrgID,
+		CheckID:    check.ID,
+		AlertType:  alertType,
+		StatusCode: statusCode,
+		ErrorMessage: fmt.Sprintf("Escalated to level %d of %d, not acknowledged after %s",
+			level+1, levels, now.Sub(incident.TriggeredAt).Round(time.Minute)),
+		IncidentID: &incident.ID,
+	}
+	// Not createAlert: escalations must not restart the suppression window
+	err := db.Create(&alert).Error
+	return alert, err == nil, err
+}
+
+// escalationRecipients resolves a level's targets into addresses
+func escalationRecipients(db *gorm.DB, orgID uint, targets []models.EscalationTarget) notifier.Recipients {
+	var r notifier.Recipients
+	for _, target := range targets {
+		switch target.Type {
+		case models.EscalationTargetEmail:
+			r.Emails = append(r.Emails, target.Address)
+		case models.EscalationTargetWebhook:
+			r.Webhooks = append(r.Webhooks, target.Address)
+		case models.EscalationTargetUser:
+			var user models.User
+			if target.UserID == nil {
+				continue
+			}
+			if err := db.Select("email").Where("id = ? AND org_id = ?", *target.UserID, orgID).First(&user).Error; err != nil {
+				log.Printf("Escalation target user %d not found in org %d: %v", *target.UserID, orgID, err)
+				continue
+			}
+			r.Emails = append(r.Emails, user.Email)
+		case models.EscalationTargetOnCall:
+			if target.ScheduleID == nil {
+				continue
+			}
+			emails, err := oncall.Emails(db, orgID, []int64{int64(*target.ScheduleID)}, time.Now())
+			if err != nil {
+				log.Printf("Error resolving on-call schedule %d of org %d: %v", *target.ScheduleID, orgID, err)
+				continue
+			}
+			if len(emails) == 0 {
+				log.Printf("Nobody is on call for schedule %d of org %d", *target.ScheduleID, orgID)
+			}
+			r.Emails = append(r.Emails, emails...)
+		case models.EscalationTargetAdmins:
+			var emails []string
+			if err := db.Model(&models.User{}).
+				Where("org_id = ? AND role IN ?", orgID, []models.Role{models.RoleOwner, models.RoleAdmin}).
+				Pluck("email", &emails).Error; err != nil {
+				log.Printf("Error loading admins of org %d: %v", orgID, err)
+				continue
+			}
+			r.Emails = append(r.Emails, emails...)
+		case models.EscalationTargetDefaults:
+			var settings models.NotificationSettings
+			if err := db.Where("org_id = ?", orgID).First(&settings).Error; err != nil {
+				if err != gorm.ErrRecordNotFound {
+					log.Printf("Error loading notification settings of org %d: %v", orgID, err)
+				}
+				continue
+			}
+			defaults := notifier.SettingsRecipients(db, settings, time.Now())
+			r.Emails = append(r.Emails, defaults.Emails...)
+			r.Webhooks = append(r.Webhooks, defaults.Webhooks...)
+		}
+	}
+	r.Emails = uniqueStrings(r.Emails)
+	r.Webhooks = uniqueStrings(r.Webhooks)
+	return r
+}
+
+// notifyEscalated sends an incident's RECOVERY to every level its policy
+// reached, rather than to the org's notification settings. If the policy
+// is gone, the settings get it after all.
+func notifyEscalated(db *gorm.DB, check models.Check, incident models.Incident, alert models.Alert) {
+	var policy models.EscalationPolicy
+	if err := db.First(&policy, *incident.EscalationPolicyID).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Error loading escalation policy for incident %d: %v", incident.ID, err)
+		}
+		sendAlertNotifications(db, &AlertMetadata{
+			Alert:     alert,
+			CheckName: check.Name,
+			CheckURL:  check.URL,
+			OrgID:     check.OrgID,
+		}, check)
+		return
+	}
+	reached := incident.EscalationLevel
+	if incident.EscalationRepeats > 0 || reached > len(policy.Levels) {
+		reached = len(policy.Levels)
+	}
+	var targets []models.EscalationTarget
+	for _, level := range policy.Levels[:reached] {
+		targets = append(targets, level.Targets...)
+	}
+	recipients := escalationRecipients(db, check.OrgID, targets)
+	if recipients.IsEmpty() {
+		return
+	}
+	track(func() {
+		if err := notifier.Send(alert, check, recipients); err != nil {
+			log.Printf("Failed to send recovery for incident %d: %v", incident.ID, err)
+		}
+	})
+}
+
+// stopEscalation ends an incident's escalation. With fallback the incident
+// gets plain repeat notifications instead.
+func stopEscalation(db *gorm.DB, incidentID uint, fallback bool) {
+	updates := map[string]interface{}{"next_escalation_at": nil}
+	if fallback {
+		updates["escalation_policy_id"] = nil
+	}
+	if err := db.Model(&models.Incident{}).Where("id = ?", incidentID).Updates(updates).Error; err != nil {
+		log.Printf("Error stopping escalation of incident %d: %v", incidentID, err)
+	}
+}
+
+// describeRecipients summarizes recipients for an incident's timeline
+func describeRecipients(r notifier.Recipients) string {
+	if r.IsEmpty() {
+		return "no recipients"
+	}
+	parts := append([]string{}, r.Emails...)
+	if n := len(r.Webhooks); n == 1 {
+		parts = append(parts, "1 webhook")
+	} else if n > 1 {
+		parts = append(parts, fmt.Sprintf("%d webhooks", n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// uniqueStrings drops repeated values, keeping the first of each
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
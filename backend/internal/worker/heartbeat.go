@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/probe"
+	"gorm.io/gorm"
+)
+
+// heartbeatSweepBatch caps the overdue heartbeats one sweep claims
+const heartbeatSweepBatch = 500
+
+// sweepHeartbeats marks heartbeat checks DOWN once no successful ping has
+// arrived within interval + grace. A check that never pinged counts from its
+// creation. Checks already DOWN are skipped so each outage alerts once, and
+// overdue checks are claimed like probed ones so only one instance records
+// the miss.
+func sweepHeartbeats(db *gorm.DB) {
+	checks, err := claimChecks(db, heartbeatSweepBatch,
+		"type = 'heartbeat' AND (state IS NULL OR state <> 'DOWN') AND "+
+			"COALESCE(last_ping_at, created_at) + ((interval_seconds + grace_seconds) * interval '1 second') < ?",
+		time.Now())
+	if err != nil {
+		log.Printf("Error fetching overdue heartbeats: %v", err)
+		return
+	}
+	for _, check := range checks {
+		// Not recorded every tick while in maintenance or blocked by a DOWN
+		// dependency; reassessed once that clears
+		if activeMaintenance(db, check, time.Now()) != nil || downDependency(db, check) != nil {
+			releaseCheck(db, check)
+			continue
+		}
+		since := check.CreatedAt
+		if check.LastPingAt != nil {
+			since = *check.LastPingAt
+		}
+		RecordResult(db, check, models.CheckResult{
+			StatusCode:   probe.StatusDown,
+			ErrorMessage: fmt.Sprintf("no ping received since %s", since.UTC().Format(time.RFC3339)),
+		})
+		releaseCheck(db, check)
+	}
+}
@@ -0,0 +1,217 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// How often a triggered incident notifies again until acknowledged
+	incidentRepeatInterval = 30 * time.Minute
+	incidentRepeatBatch    = 100
+)
+
+// trackIncident files a newly created alert under its check's incident.
+// DOWN and DEGRADED alerts open an incident or join the open one, RECOVERY
+// resolves it. Returns whether the alert should notify the org's settings:
+// only an alert opening an incident does, and not when an escalation
+// policy handles the incident instead. Alerts joining an open incident
+// stay quiet; triggered incidents renotify on their own schedule.
+func trackIncident(db *gorm.DB, check models.Check, alert *models.Alert) bool {
+	switch alert.AlertType {
+	case models.AlertTypeDown, models.AlertTypeDegraded:
+	case models.AlertTypeRecovery:
+		incident := resolveIncident(db, check, alert)
+		if incident != nil && incident.EscalationPolicyID != nil {
+			notifyEscalated(db, check, *incident, *alert)
+			return false
+		}
+		return true
+	default:
+		return true // Certificate alerts stand alone
+	}
+
+	var incident models.Incident
+	err := db.Where("check_id = ? AND state <> ?", check.ID, models.IncidentStateResolved).First(&incident).Error
+	if err == gorm.ErrRecordNotFound {
+		incident = models.Incident{
+			OrgID:          check.OrgID,
+			CheckID:        check.ID,
+			State:          models.IncidentStateTriggered,
+			Title:          fmt.Sprintf("%s is %s", check.Name, alert.AlertType),
+			TriggeredAt:    alert.CreatedAt,
+			LastNotifiedAt: alert.CreatedAt,
+			AlertCount:     1,
+		}
+		startEscalation(db, check, &incident)
+		err = db.Create(&incident).Error
+		if err == nil {
+			// Link right away: a due escalation looks the opening alert up
+			linkAlert(db, alert, incident.ID)
+			addIncidentEvent(db, incident.ID, models.IncidentEventTriggered, &alert.ID, alert.ErrorMessage)
+			log.Printf("Incident %d opened for check %d", incident.ID, check.ID)
+			return incident.EscalationPolicyID == nil
+		}
+		var pgErr *pq.Error
+		if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+			log.Printf("Error opening incident for check %d: %v", check.ID, err)
+			return true
+		}
+		// Another alert opened the check's incident first
+		// (idx_incidents_check_open); join that one instead
+		err = db.Where("check_id = ? AND state <> ?", check.ID, models.IncidentStateResolved).First(&incident).Error
+	}
+	if err != nil {
+		log.Printf("Error loading incident for check %d: %v", check.ID, err)
+		return true
+	}
+
+	if err := db.Model(&incident).Update("alert_count", gorm.Expr("alert_count + 1")).Error; err != nil {
+		log.Printf("Error updating incident %d: %v", incident.ID, err)
+	}
+	linkAlert(db, alert, incident.ID)
+	addIncidentEvent(db, incident.ID, models.IncidentEventAlert, &alert.ID, alert.ErrorMessage)
+	return false
+}
+
+// resolveIncident resolves the check's open incident, if any, because the
+// check recovered. alert is the RECOVERY alert, or nil when it was
+// suppressed. Returns the incident as it was before resolving, or nil.
+func resolveIncident(db *gorm.DB, check models.Check, alert *models.Alert) *models.Incident {
+	var incident models.Incident
+	err := db.Where("check_id = ? AND state <> ?", check.ID, models.IncidentStateResolved).First(&incident).Error
+	if err != nil {
+		if err != gorm.ErrRecordNotFound {
+			log.Printf("Error loading incident for check %d: %v", check.ID, err)
+		}
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"state":       models.IncidentStateResolved,
+		"resolved_at": time.Now(),
+	}
+	if alert != nil {
+		updates["alert_count"] = gorm.Expr("alert_count + 1")
+	}
+	// Someone may have resolved it by hand in the meantime
+	result := db.Model(&models.Incident{}).
+		Where("id = ? AND state <> ?", incident.ID, models.IncidentStateResolved).
+		Updates(updates)
+	if result.Error != nil {
+		log.Printf("Error resolving incident %d: %v", incident.ID, result.Error)
+		return nil
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var alertID *uint
+	if alert != nil {
+		alertID = &alert.ID
+		linkAlert(db, alert, incident.ID)
+	}
+	addIncidentEvent(db, incident.ID, models.IncidentEventResolved, alertID, "Check recovered")
+	log.Printf("Incident %d resolved: check %d recovered", incident.ID, check.ID)
+	return &incident
+}
+
+// renotifyIncidents re-sends notifications for incidents still triggered
+// incidentRepeatInterval after they last notified, unless an escalation
+// policy handles them. Each repeat is stored as an alert of the incident.
+// Instances claim incidents by bumping last_notified_at, so a repeat goes
+// out once.
+func renotifyIncidents(db *gorm.DB) {
+	now := time.Now()
+	var incidents []models.Incident
+	err := db.Raw(`
+        UPDATE incidents SET last_notified_at = ?
+        WHERE id IN (
+            SELECT i.id FROM incidents i
+            JOIN checks c ON c.id = i.check_id
+            WHERE i.state = ? AND i.last_notified_at < ? AND i.escalation_policy_id IS NULL
+              AND c.deleted_at IS NULL AND c.is_active = true AND c.state IN (?, ?)
+            ORDER BY i.last_notified_at ASC
+            LIMIT ?
+            FOR UPDATE OF i SKIP LOCKED
+        )
+        RETURNING *`,
+		now, models.IncidentStateTriggered, now.Add(-incidentRepeatInterval),
+		models.CheckStateDown, models.CheckStateDegraded, incidentRepeatBatch).
+		Scan(&incidents).Error
+	if err != nil {
+		log.Printf("Error claiming incidents to renotify: %v", err)
+		return
+	}
+
+	for _, incident := range incidents {
+		var check models.Check
+		if err := db.First(&check, incident.CheckID).Error; err != nil {
+			log.Printf("Error loading check %d for incident %d: %v", incident.CheckID, incident.ID, err)
+			continue
+		}
+		if activeMaintenance(db, check, now) != nil {
+			continue
+		}
+
+		alertType := models.AlertTypeDown
+		if check.State == models.CheckStateDegraded {
+			alertType = models.AlertTypeDegraded
+		}
+		statusCode := 0
+		if check.LastStatus != nil {
+			statusCode = *check.LastStatus
+		}
+		alert := models.Alert{
+			OrgID:        check.OrgID,
+			CheckID:      check.ID,
+			AlertType:    alertType,
+			StatusCode:   statusCode,
+			ErrorMessage: fmt.Sprintf("Still %s after %s, not acknowledged", check.State, now.Sub(incident.TriggeredAt).Round(time.Minute)),
+			IncidentID:   &incident.ID,
+		}
+		// Not createAlert: a repeat must not restart the suppression window
+		// that would hide the check's recovery
+		if err := db.Create(&alert).Error; err != nil {
+			log.Printf("Error creating repeat alert for incident %d: %v", incident.ID, err)
+			continue
+		}
+		if err := db.Model(&incident).Update("alert_count", gorm.Expr("alert_count + 1")).Error; err != nil {
+			log.Printf("Error updating incident %d: %v", incident.ID, err)
+		}
+		addIncidentEvent(db, incident.ID, models.IncidentEventRenotified, &alert.ID, "")
+		sendAlertNotifications(db, &AlertMetadata{
+			Alert:     alert,
+			CheckName: check.Name,
+			CheckURL:  check.URL,
+			OrgID:     check.OrgID,
+		}, check)
+	}
+}
+
+// linkAlert records which incident an alert belongs to
+func linkAlert(db *gorm.DB, alert *models.Alert, incidentID uint) {
+	alert.IncidentID = &incidentID
+	if err := db.Model(&models.Alert{}).Where("id = ?", alert.ID).Update("incident_id", incidentID).Error; err != nil {
+		log.Printf("Error linking alert %d to incident %d: %v", alert.ID, incidentID, err)
+	}
+}
+
+// addIncidentEvent appends a system entry to an incident's timeline
+func addIncidentEvent(db *gorm.DB, incidentID uint, eventType models.IncidentEventType, alertID *uint, body string) {
+	event := models.IncidentEvent{
+		IncidentID: incidentID,
+		Type:       eventType,
+		AlertID:    alertID,
+		Body:       body,
+	}
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Error adding %s event to incident %d: %v", eventType, incidentID, err)
+	}
+}
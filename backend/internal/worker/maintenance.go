@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"log"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// activeMaintenance returns the first maintenance window covering the check
+// at t, or nil. Windows that ended or haven't started are filtered in SQL;
+// schedules and scopes are matched here.
+func activeMaintenance(db *gorm.DB, check models.Check, t time.Time) *models.MaintenanceWindow {
+	var windows []models.MaintenanceWindow
+	err := db.Where("org_id = ?", check.OrgID).
+		Where("starts_at IS NULL OR starts_at <= ?", t).
+		Where("ends_at IS NULL OR ends_at > ?", t).
+		Find(&windows).Error
+	if err != nil {
+		// Alerting over a deploy beats missing a real outage
+		log.Printf("Error loading maintenance windows for check %d: %v", check.ID, err)
+		return nil
+	}
+	for i := range windows {
+		if windows[i].AppliesTo(check) && windows[i].ActiveAt(t) {
+			return &windows[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/database"
+	"gorm.io/gorm"
+)
+
+// StartPartitionManager runs hourly to keep future partitions ready for
+// log_entries, trace_spans and check_results and to drop expired ones,
+// including expired rows left in their default partitions
+func StartPartitionManager(ctx context.Context, db *gorm.DB) {
+	log.Println("Starting partition manager...")
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	// Run immediately on start, then every hour
+	maintainPartitions(db)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Partition manager stopped")
+			return
+		case <-ticker.C:
+			maintainPartitions(db)
+		}
+	}
+}
+
+func maintainPartitions(db *gorm.DB) {
+	if err := database.MaintainPartitions(db); err != nil {
+		log.Printf("[Partitions] Error maintaining partitions: %v", err)
+	}
+}
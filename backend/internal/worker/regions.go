@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/probe"
+	"gorm.io/gorm"
+)
+
+// ErrLeaseLost means an agent reported a run it no longer holds the lease
+// for: it took longer than checkLeaseDuration and the run was handed out
+// again, or the check was deleted or moved out of the agent's region
+var ErrLeaseLost = errors.New("lease on this check run was lost")
+
+// ClaimRegionalChecks leases up to limit checks due in the agent's region.
+// Each check keeps a separate schedule and lease per region, so agents in
+// different regions run it independently while agents sharing a region
+// split the work between them.
+func ClaimRegionalChecks(db *gorm.DB, agent models.ProbeAgent, limit int) ([]models.Check, error) {
+	var checks []models.Check
+	if limit <= 0 {
+		return checks, nil
+	}
+	// Checks new to the region get a run row, due immediately
+	if err := db.Exec(`
+        INSERT INTO check_region_runs (check_id, region)
+        SELECT id, ? FROM checks
+        WHERE org_id = ? AND deleted_at IS NULL AND ? = ANY(regions)
+        ON CONFLICT DO NOTHING`, agent.Region, agent.OrgID, agent.Region).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var ids []uint
+	err := db.Raw(`
+        UPDATE check_region_runs SET lease_expires_at = ?, leased_by = ?
+        WHERE (check_id, region) IN (
+            SELECT r.check_id, r.region FROM check_region_runs r
+            JOIN checks c ON c.id = r.check_id
+            WHERE r.region = ? AND c.org_id = ? AND c.deleted_at IS NULL AND c.is_active = true
+              AND ? = ANY(c.regions)
+              AND (r.next_run_at IS NULL OR r.next_run_at <= ?)
+              AND (r.lease_expires_at IS NULL OR r.lease_expires_at < ?)
+            ORDER BY r.next_run_at ASC NULLS FIRST
+            LIMIT ?
+            FOR UPDATE OF r SKIP LOCKED
+        )
+        RETURNING check_id`,
+		now.Add(checkLeaseDuration), agent.ID, agent.Region, agent.OrgID, agent.Region, now, now, limit).
+		Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return checks, err
+	}
+	err = db.Where("id IN ?", ids).Find(&checks).Error
+	return checks, err
+}
+
+// RecordAgentResult releases the agent's lease on a check run, schedules
+// the check's next run in the agent's region and records what the agent
+// observed. The check's state follows the regions' quorum, not the single
+// result; when several regions report a transition at once, recordResult
+// lets only the first one alert.
+func RecordAgentResult(db *gorm.DB, agent models.ProbeAgent, checkID uint, outcome probe.Outcome) error {
+	var check models.Check
+	if err := db.Where("id = ? AND org_id = ?", checkID, agent.OrgID).First(&check).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrLeaseLost
+		}
+		return err
+	}
+
+	release := db.Model(&models.CheckRegionRun{}).
+		Where("check_id = ? AND region = ? AND leased_by = ?", check.ID, agent.Region, agent.ID).
+		Updates(map[string]interface{}{
+			"lease_expires_at": nil,
+			"leased_by":        nil,
+			"next_run_at":      nextRunAt(check, time.Now()),
+		})
+	if release.Error != nil {
+		return release.Error
+	}
+	if release.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+
+	result := resultFromOutcome(outcome)
+	result.Region = agent.Region
+	if !recordResult(db, check, result, quorumSuccess(db, check, agent.Region, outcome.Success)) {
+		return errors.New("failed to store result")
+	}
+	if outcome.Cert != nil {
+		recordCertificate(db, &check, outcome.Cert)
+	}
+	return nil
+}
+
+// quorumSuccess decides a run from the latest result of every region: it
+// fails once RegionQuorum regions (0 = a majority) are failing. Regions
+// without a result in the last two intervals don't count either way, so a
+// region whose agents are gone can't hold the check DOWN.
+func quorumSuccess(db *gorm.DB, check models.Check, region string, success bool) bool {
+	quorum := check.RegionQuorum
+	if quorum <= 0 {
+		quorum = len(check.Regions)/2 + 1
+	}
+	failing := 0
+	if !success {
+		failing++
+	}
+
+	var latest []models.CheckResult
+	fresh := time.Now().Add(-2*time.Duration(check.IntervalSeconds)*time.Second - time.Minute)
+	err := db.Raw(`
+        SELECT DISTINCT ON (region) region, success FROM check_results
+        WHERE check_id = ? AND region IN ? AND region <> ? AND created_at > ?
+        ORDER BY region, created_at DESC`,
+		check.ID, []string(check.Regions), region, fresh).Scan(&latest).Error
+	if err != nil {
+		log.Printf("Error loading region results for check %d: %v", check.ID, err)
+		return success
+	}
+	for _, r := range latest {
+		if !r.Success {
+			failing++
+		}
+	}
+	return failing < quorum
+}
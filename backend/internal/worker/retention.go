@@ -0,0 +1,188 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/billing"
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// Retention deletes in bounded batches so a large backlog never holds long
+// locks or one huge transaction. Anything past the cap is picked up by the
+// next sweep.
+const (
+	retentionBatchSize        = 5000
+	retentionMaxBatchesPerRun = 20 // Per table, per org
+	retentionDowngradeGrace   = 7 * 24 * time.Hour
+)
+
+// retentionTable is a table purged by age
+type retentionTable struct {
+	name           string
+	timestampField string
+}
+
+var retentionTables = []retentionTable{
+	{name: "log_entries", timestampField: "timestamp"},
+	{name: "log_events", timestampField: "timestamp"},
+	{name: "trace_spans", timestampField: "start_time"},
+}
+
+// StartRetentionWorker runs hourly to delete logs and spans older than each
+// org's plan retention (PlanConfig.LogRetentionDays). Partitions past every
+// org's retention are dropped wholesale by the partition manager; this
+// worker only trims orgs with shorter retention.
+func StartRetentionWorker(ctx context.Context, db *gorm.DB) {
+	log.Println("Starting retention worker...")
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	// Run immediately on start, then every hour
+	enforceRetention(db)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Retention worker stopped")
+			return
+		case <-ticker.C:
+			enforceRetention(db)
+		}
+	}
+}
+
+// enforceRetention sweeps every org. Idempotent: deletes only match rows
+// past the cutoff, so repeated runs or multiple instances are safe.
+func enforceRetention(db *gorm.DB) {
+	var orgs []models.Organization
+	if err := db.Find(&orgs).Error; err != nil {
+		log.Printf("[Retention] Error fetching organizations: %v", err)
+		return
+	}
+
+	for i := range orgs {
+		org := &orgs[i]
+		days := resolveRetentionDays(db, org, time.Now())
+		if days <= 0 {
+			continue
+		}
+		purgeOrg(db, org, days)
+	}
+}
+
+// resolveRetentionDays returns the retention to apply now and persists any
+// change. Upgrades apply immediately; a shorter retention from a downgrade
+// starts a grace period during which the previous retention is kept.
+func resolveRetentionDays(db *gorm.DB, org *models.Organization, now time.Time) int {
+	plan := billing.EffectivePlan(org)
+	planDays := models.GetPlanConfig(plan).LogRetentionDays
+	applied := org.AppliedRetentionDays
+	graceUntil := org.RetentionGraceUntil
+
+	switch {
+	case applied == 0 || planDays >= applied:
+		// First sweep, upgrade, or unchanged; also cancels a pending downgrade
+		applied = planDays
+		graceUntil = nil
+	case graceUntil == nil:
+		until := now.Add(retentionDowngradeGrace)
+		graceUntil = &until
+		log.Printf("[Retention] Org %d retention shortened to %d days (plan %s); keeping %d days until %s",
+			org.ID, planDays, plan, applied, until.Format(time.RFC3339))
+	case now.After(*graceUntil):
+		log.Printf("[Retention] Org %d grace period over, applying %d-day retention", org.ID, planDays)
+		applied = planDays
+		graceUntil = nil
+	}
+
+	if applied != org.AppliedRetentionDays || !sameTimePtr(graceUntil, org.RetentionGraceUntil) {
+		err := db.Model(&models.Organization{}).Where("id = ?", org.ID).Updates(map[string]interface{}{
+			"applied_retention_days": applied,
+			"retention_grace_until":  graceUntil,
+		}).Error
+		if err != nil {
+			// Don't purge on a shorter window we failed to record
+			log.Printf("[Retention] Error updating retention for org %d: %v", org.ID, err)
+			return org.AppliedRetentionDays
+		}
+		org.AppliedRetentionDays = applied
+		org.RetentionGraceUntil = graceUntil
+	}
+	return applied
+}
+
+// purgeOrg deletes expired rows from each retention table and records the run
+func purgeOrg(db *gorm.DB, org *models.Organization, days int) {
+	start := time.Now()
+	cutoff := start.Add(-time.Duration(days) * 24 * time.Hour)
+	run := models.RetentionRun{
+		OrgID:         org.ID,
+		Plan:          billing.EffectivePlan(org),
+		RetentionDays: days,
+		Cutoff:        cutoff,
+		Complete:      true,
+	}
+
+	for _, table := range retentionTables {
+		purged, complete, err := purgeTable(db, table, org.ID, cutoff)
+		switch table.name {
+		case "log_entries":
+			run.LogEntriesPurged = purged
+		case "log_events":
+			run.LogEventsPurged = purged
+		case "trace_spans":
+			run.TraceSpansPurged = purged
+		}
+		if !complete {
+			run.Complete = false
+		}
+		if err != nil {
+			run.Error = err.Error()
+			log.Printf("[Retention] Error purging %s for org %d: %v", table.name, org.ID, err)
+			break
+		}
+	}
+
+	total := run.LogEntriesPurged + run.LogEventsPurged + run.TraceSpansPurged
+	if total == 0 && run.Error == "" {
+		return
+	}
+	run.DurationMs = time.Since(start).Milliseconds()
+	if err := db.Create(&run).Error; err != nil {
+		log.Printf("[Retention] Error recording run for org %d: %v", org.ID, err)
+	}
+	log.Printf("[Retention] Org %d: purged %d log entries, %d log events, %d spans older than %d days",
+		org.ID, run.LogEntriesPurged, run.LogEventsPurged, run.TraceSpansPurged, days)
+}
+
+// purgeTable deletes up to retentionMaxBatchesPerRun batches of rows older
+// than cutoff. complete is false when rows may remain past the cap.
+func purgeTable(db *gorm.DB, table retentionTable, orgID uint, cutoff time.Time) (purged int64, complete bool, err error) {
+	// Table and column names come from retentionTables, never user input.
+	// The outer cutoff lets Postgres prune partitions that can't match.
+	sql := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s < ? AND id IN (SELECT id FROM %s WHERE org_id = ? AND %s < ? LIMIT ?)",
+		table.name, table.timestampField, table.name, table.timestampField,
+	)
+	for i := 0; i < retentionMaxBatchesPerRun; i++ {
+		result := db.Exec(sql, cutoff, orgID, cutoff, retentionBatchSize)
+		if result.Error != nil {
+			return purged, false, result.Error
+		}
+		purged += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return purged, true, nil
+		}
+	}
+	return purged, false, nil
+}
+
+func sameTimePtr(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/probe"
+	"gorm.io/gorm"
+)
+
+const (
+	// How often due checks are claimed. Each check's NextRunAt carries its
+	// own jitter, so a short poll spreads runs out instead of batching them.
+	schedulerPollInterval = 5 * time.Second
+	// Longer than any run can take (probe.MaxRunDuration, every attempt and
+	// step included) plus time to record the result, so a live run never
+	// loses its lease
+	checkLeaseDuration = probe.MaxRunDuration + 5*time.Minute
+	// Cap on the random delay added to each NextRunAt
+	maxScheduleJitter = 30 * time.Second
+)
+
+// instanceID identifies this process in leases, for debugging stuck checks
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// dueCheckCondition selects checks the server probes itself (not heartbeats,
+// not checks run by agents in their regions) whose next run time has passed
+const dueCheckCondition = "type <> 'heartbeat' AND (regions IS NULL OR regions = '{}') AND (next_run_at IS NULL OR next_run_at <= ?)"
+
+// claimChecks leases up to limit active checks matching condition and
+// returns them. Rows another instance is claiming are skipped rather than
+// waited on, and rows with a live lease are excluded, so concurrent
+// schedulers never run the same check at once.
+func claimChecks(db *gorm.DB, limit int, condition string, args ...interface{}) ([]models.Check, error) {
+	var checks []models.Check
+	if limit <= 0 {
+		return checks, nil
+	}
+	now := time.Now()
+	query := `
+        UPDATE checks SET lease_expires_at = ?, leased_by = ?
+        WHERE id IN (
+            SELECT id FROM checks
+            WHERE deleted_at IS NULL AND is_active = true
+              AND (lease_expires_at IS NULL OR lease_expires_at < ?)
+              AND ` + condition + `
+            ORDER BY next_run_at ASC NULLS FIRST
+            LIMIT ?
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING *`
+	params := append([]interface{}{now.Add(checkLeaseDuration), instanceID, now}, args...)
+	params = append(params, limit)
+	err := db.Raw(query, params...).Scan(&checks).Error
+	return checks, err
+}
+
+// releaseCheck drops this instance's lease on a check and, for probed
+// checks, schedules the next run one interval plus jitter from now
+func releaseCheck(db *gorm.DB, check models.Check) {
+	updates := map[string]interface{}{
+		"lease_expires_at": nil,
+		"leased_by":        "",
+	}
+	if check.Type != models.CheckTypeHeartbeat {
+		updates["next_run_at"] = nextRunAt(check, time.Now())
+	}
+	if err := db.Model(&models.Check{}).
+		Where("id = ? AND leased_by = ?", check.ID, instanceID).
+		Updates(updates).Error; err != nil {
+		log.Printf("Error releasing check %d: %v", check.ID, err)
+	}
+}
+
+// nextRunAt is one interval after from, plus up to a tenth of the interval
+// (at most maxScheduleJitter) so checks created together drift apart
+func nextRunAt(check models.Check, from time.Time) time.Time {
+	interval := time.Duration(check.IntervalSeconds) * time.Second
+	jitter := interval / 10
+	if jitter > maxScheduleJitter {
+		jitter = maxScheduleJitter
+	}
+	next := from.Add(interval)
+	if jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(jitter))))
+	}
+	return next
+}
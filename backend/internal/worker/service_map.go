@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Service map edges are bucketed hourly. Each run recomputes the current and
+// previous bucket so spans that arrive late are still counted.
+const (
+	serviceMapInterval = 5 * time.Minute
+	serviceMapBucket   = time.Hour
+	// How long before the window a parent span may start and still be
+	// joined: parents usually start just before their children, and
+	// bounding them keeps the join to the window's partitions
+	serviceMapParentSkew = 10 * time.Minute
+)
+
+// StartServiceMapWorker periodically materializes service-to-service edges
+// from trace spans into service_edges
+func StartServiceMapWorker(ctx context.Context, db *gorm.DB) {
+	log.Println("Starting service map worker...")
+	ticker := time.NewTicker(serviceMapInterval)
+	defer ticker.Stop()
+
+	// Run immediately on start, then every interval
+	materializeServiceEdges(db)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Service map worker stopped")
+			return
+		case <-ticker.C:
+			materializeServiceEdges(db)
+		}
+	}
+}
+
+// materializeServiceEdges upserts one row per (org, hour, environment,
+// caller, callee) for span pairs that cross a service boundary. Spans
+// ingested more than once count once. Idempotent: re-running a bucket
+// overwrites it with fresh aggregates.
+func materializeServiceEdges(db *gorm.DB) {
+	since := time.Now().UTC().Truncate(serviceMapBucket).Add(-serviceMapBucket)
+	result := db.Exec(`
+		WITH child AS (
+			SELECT DISTINCT ON (org_id, trace_id, span_id)
+				org_id, trace_id, parent_span_id, service_name, environment, status, duration_ms, start_time
+			FROM trace_spans
+			WHERE start_time >= ? AND parent_span_id <> ''
+			ORDER BY org_id, trace_id, span_id, start_time
+		), parent AS (
+			SELECT DISTINCT ON (org_id, trace_id, span_id)
+				org_id, trace_id, span_id, service_name
+			FROM trace_spans
+			WHERE start_time >= ?
+			ORDER BY org_id, trace_id, span_id, start_time
+		)
+		INSERT INTO service_edges (
+			created_at, updated_at, org_id, bucket_start, environment,
+			source_service, target_service, request_count, error_count, p50_ms, p95_ms
+		)
+		SELECT
+			NOW(), NOW(), child.org_id,
+			date_trunc('hour', child.start_time AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+			COALESCE(child.environment, ''),
+			parent.service_name, child.service_name,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE child.status = 'ERROR'),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY child.duration_ms),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY child.duration_ms)
+		FROM child
+		JOIN parent
+			ON parent.org_id = child.org_id
+			AND parent.trace_id = child.trace_id
+			AND parent.span_id = child.parent_span_id
+		WHERE parent.service_name <> child.service_name
+		GROUP BY child.org_id, 4, 5, parent.service_name, child.service_name
+		ON CONFLICT (org_id, bucket_start, environment, source_service, target_service)
+		DO UPDATE SET
+			request_count = EXCLUDED.request_count,
+			error_count = EXCLUDED.error_count,
+			p50_ms = EXCLUDED.p50_ms,
+			p95_ms = EXCLUDED.p95_ms,
+			updated_at = NOW()
+	`, since, since.Add(-serviceMapParentSkew))
+	if result.Error != nil {
+		log.Printf("[ServiceMap] Error materializing edges: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[ServiceMap] Materialized %d edges since %s", result.RowsAffected, since.Format(time.RFC3339))
+	}
+}
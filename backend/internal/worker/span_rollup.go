@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/oFuterman/light-house/internal/models"
+	"gorm.io/gorm"
+)
+
+// Each run recomputes the last few rollup buckets so late-arriving spans
+// are folded in before a bucket goes cold. The first run after startup
+// backfills a full day to cover any downtime.
+const (
+	spanRollupInterval = time.Minute
+	spanRollupLookback = 15 * time.Minute
+	spanRollupBackfill = 24 * time.Hour
+)
+
+// StartSpanRollupWorker periodically refreshes span_rollups from trace spans
+func StartSpanRollupWorker(ctx context.Context, db *gorm.DB) {
+	log.Println("Starting span rollup worker...")
+	ticker := time.NewTicker(spanRollupInterval)
+	defer ticker.Stop()
+
+	// Run immediately on start, then every interval
+	refreshSpanRollups(db, spanRollupBackfill)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Span rollup worker stopped")
+			return
+		case <-ticker.C:
+			refreshSpanRollups(db, spanRollupLookback)
+		}
+	}
+}
+
+// refreshSpanRollups upserts one row per (org, bucket, environment, service,
+// operation). Idempotent: recomputing a bucket overwrites it.
+func refreshSpanRollups(db *gorm.DB, lookback time.Duration) {
+	bucket := time.Duration(models.SpanRollupBucketSeconds) * time.Second
+	since := time.Now().UTC().Truncate(bucket).Add(-lookback)
+	result := db.Exec(`
+		INSERT INTO span_rollups (
+			created_at, updated_at, org_id, bucket_start, environment, service_name, operation,
+			request_count, error_count, duration_sum_ms, p50_ms, p95_ms, p99_ms, max_ms
+		)
+		SELECT
+			NOW(), NOW(), org_id,
+			to_timestamp(floor(extract(epoch FROM start_time) / ?) * ?),
+			COALESCE(environment, ''), service_name, operation,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'ERROR'),
+			SUM(duration_ms),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms),
+			MAX(duration_ms)
+		FROM trace_spans
+		WHERE start_time >= ?
+		GROUP BY org_id, 4, 5, service_name, operation
+		ON CONFLICT (org_id, bucket_start, environment, service_name, operation)
+		DO UPDATE SET
+			request_count = EXCLUDED.request_count,
+			error_count = EXCLUDED.error_count,
+			duration_sum_ms = EXCLUDED.duration_sum_ms,
+			p50_ms = EXCLUDED.p50_ms,
+			p95_ms = EXCLUDED.p95_ms,
+			p99_ms = EXCLUDED.p99_ms,
+			max_ms = EXCLUDED.max_ms,
+			updated_at = NOW()
+	`, models.SpanRollupBucketSeconds, models.SpanRollupBucketSeconds, since)
+	if result.Error != nil {
+		log.Printf("[Rollup] Error refreshing span rollups: %v", result.Error)
+	}
+}
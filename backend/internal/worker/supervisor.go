@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// A worker that panics is restarted after a backoff that doubles up to
+// maxRestartBackoff. One that ran for stableRunDuration before panicking
+// starts over from minRestartBackoff.
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+	stableRunDuration = 5 * time.Minute
+)
+
+// inflight counts work started by the workers or by requests that must
+// finish before the process exits: check runs and notification sends
+var inflight sync.WaitGroup
+
+// track runs fn in its own goroutine, counted by Drain
+func track(fn func()) {
+	inflight.Add(1)
+	go func() {
+		defer inflight.Done()
+		fn()
+	}()
+}
+
+// Drain waits for in-flight check runs and notifications to finish, or for
+// ctx to end. Call it once the workers have stopped starting new ones.
+func Drain(ctx context.Context) error {
+	return waitGroup(ctx, &inflight)
+}
+
+// Supervisor runs the long-lived background workers. Each worker loops
+// until its context is cancelled; a worker that panics is logged and
+// restarted with backoff instead of taking the server down.
+type Supervisor struct {
+	wg sync.WaitGroup
+}
+
+// Go starts a supervised worker
+func (s *Supervisor) Go(ctx context.Context, name string, run func(ctx context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		backoff := minRestartBackoff
+		for {
+			started := time.Now()
+			if !runRecovered(name, func() { run(ctx) }) {
+				return // Returned normally: the context was cancelled
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(started) > stableRunDuration {
+				backoff = minRestartBackoff
+			}
+			log.Printf("Restarting %s in %s", name, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxRestartBackoff {
+				backoff = maxRestartBackoff
+			}
+		}
+	}()
+}
+
+// Wait waits for every worker to return after its context is cancelled,
+// or for ctx to end
+func (s *Supervisor) Wait(ctx context.Context) error {
+	return waitGroup(ctx, &s.wg)
+}
+
+// runRecovered calls fn and reports whether it panicked
+func runRecovered(name string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in %s: %v\n%s", name, r, debug.Stack())
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// waitGroup waits for wg or for ctx to end, whichever is first
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/oFuterman/light-house/internal/models"
+	"github.com/oFuterman/light-house/internal/probe"
+	"gorm.io/gorm"
+)
+
+// recordCertificate stores the captured certificate on the check and raises
+// a CERT_EXPIRING alert when the days left cross a threshold not yet alerted
+// for this certificate. A renewed certificate (new expiry) starts over.
+func recordCertificate(db *gorm.DB, check *models.Check, info *probe.CertInfo) {
+	now := time.Now()
+	alerted := check.CertAlertedDays
+	if check.CertExpiresAt == nil || !check.CertExpiresAt.Equal(info.ExpiresAt) {
+		alerted = nil
+	}
+
+	thresholds := []int64(check.CertAlertDays)
+	if thresholds == nil {
+		thresholds = models.DefaultCertAlertDays
+	}
+	daysLeft := int(info.ExpiresAt.Sub(now).Hours() / 24)
+	crossed := crossedCertThreshold(thresholds, daysLeft)
+
+	var alertDays *int
+	switch {
+	case crossed == nil:
+		alerted = nil
+	case alerted == nil || *crossed < *alerted:
+		// Left unrecorded during maintenance so it alerts once the window closes
+		if activeMaintenance(db, *check, now) == nil {
+			alertDays = crossed
+			alerted = crossed
+		}
+	}
+
+	updates := map[string]interface{}{
+		"cert_expires_at":     info.ExpiresAt,
+		"cert_issuer":         truncateCertField(info.Issuer),
+		"cert_subject":        truncateCertField(info.Subject),
+		"cert_sans":           pq.StringArray(info.SANs),
+		"cert_hostname_valid": info.HostnameValid,
+		"cert_chain_valid":    info.ChainValid,
+		"cert_error":          truncateCertField(info.Error),
+		"cert_checked_at":     now,
+		"cert_alerted_days":   alerted,
+	}
+	if err := db.Model(&models.Check{}).Where("id = ?", check.ID).Updates(updates).Error; err != nil {
+		log.Printf("Error storing certificate for check %d: %v", check.ID, err)
+		return
+	}
+
+	if alertDays != nil {
+		expiry := info.ExpiresAt.UTC().Format("2006-01-02")
+		msg := fmt.Sprintf("certificate expires %s (in %d days)", expiry, daysLeft)
+		if daysLeft < 0 {
+			msg = fmt.Sprintf("certificate expired %s", expiry)
+		}
+		if metadata := createAlert(db, *check, models.AlertTypeCertExpiring, 0, msg); metadata != nil {
+			sendAlertNotifications(db, metadata, *check)
+		}
+	}
+}
+
+// crossedCertThreshold returns the smallest threshold at or above daysLeft,
+// i.e. the most urgent one reached, or nil if none is
+func crossedCertThreshold(thresholds []int64, daysLeft int) *int {
+	sorted := append([]int64{}, thresholds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, t := range sorted {
+		if int64(daysLeft) <= t {
+			v := int(t)
+			return &v
+		}
+	}
+	return nil
+}
+
+func truncateCertField(s string) string {
+	if len(s) > 512 {
+		return s[:512]
+	}
+	return s
+}
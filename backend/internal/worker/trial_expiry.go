@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"context"
 	"log"
 	"time"
 
@@ -12,15 +13,21 @@ import (
 // This is a cleanup sweep — EffectivePlan and GetBilling already handle
 // expired trials at request time. The worker ensures eventual consistency
 // for orgs that never make another request after trial expiry.
-func StartTrialExpiryWorker(db *gorm.DB) {
+func StartTrialExpiryWorker(ctx context.Context, db *gorm.DB) {
 	log.Println("Starting trial expiry worker...")
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
 	// Run immediately on start, then every hour
 	expireTrials(db)
-	for range ticker.C {
-		expireTrials(db)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Trial expiry worker stopped")
+			return
+		case <-ticker.C:
+			expireTrials(db)
+		}
 	}
 }
 